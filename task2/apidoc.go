@@ -0,0 +1,135 @@
+package main
+
+import (
+	"FloatService/config"
+	"FloatService/handlers/handlecalculatev2"
+	"FloatService/handlers/handleevaluation"
+	"FloatService/handlers/handlefloatcalculation"
+	"FloatService/handlers/handleratelimit"
+	"FloatService/openapi"
+	"FloatService/response"
+	"net/http"
+)
+
+/*
+Спецификация OpenAPI всех маршрутов из newRouter.
+Схемы строятся по типам запросов и ответов обработчиков,
+поэтому при изменении типов спецификация обновляется сама,
+а при добавлении маршрута его нужно описать здесь.
+*/
+func newAPIDocument(cfg *config.Config) *openapi.Document {
+	doc := openapi.New(openapi.Info{
+		Title:       "FloatService",
+		Version:     "1.0",
+		Description: "Вычисления над десятичными числами с заданной точностью.",
+	})
+	calculationErrors := []int{http.StatusBadRequest, http.StatusUnprocessableEntity, http.StatusServiceUnavailable}
+	evaluationErrors := []int{http.StatusBadRequest, http.StatusUnprocessableEntity, http.StatusServiceUnavailable}
+	v1 := cfg.APIVersions["v1"].Deprecated
+	v2 := cfg.APIVersions["v2"].Deprecated
+	calculationResults := []any{handlefloatcalculation.Response{}, handlefloatcalculation.ValuesResponse{}}
+	doc.Add(openapi.Route{
+		Method:     http.MethodPost,
+		Path:       "/v1/calculate",
+		Summary:    "Вычисление X = X1 / X2 * X3 и Y = Y1 / Y2 * Y3 или произвольного количества значений",
+		Deprecated: v1,
+		Body:       handlefloatcalculation.Request{},
+		Results:    calculationResults,
+		Errors:     calculationErrors,
+	})
+	doc.Add(openapi.Route{
+		Method:     http.MethodGet,
+		Path:       "/v1/calculate",
+		Summary:    "Вычисление X и Y с параметрами в строке запроса",
+		Deprecated: v1,
+		Query:      handlefloatcalculation.Request{},
+		Results:    []any{handlefloatcalculation.Response{}},
+		Errors:     calculationErrors,
+	})
+	doc.Add(openapi.Route{
+		Method:     http.MethodPost,
+		Path:       "/v1/evaluate",
+		Summary:    "Вычисление произвольного выражения",
+		Deprecated: v1,
+		Body:       handleevaluation.Request{},
+		Results:    []any{handleevaluation.Response{}},
+		Errors:     evaluationErrors,
+	})
+	doc.Add(openapi.Route{
+		Method:     http.MethodPost,
+		Path:       "/v2/calculate",
+		Summary:    "Вычисление произвольного количества значений",
+		Deprecated: v2,
+		Body:       handlecalculatev2.Request{},
+		Results:    []any{handlecalculatev2.Response{}},
+		Errors:     calculationErrors,
+	})
+	doc.Add(openapi.Route{
+		Method:     http.MethodGet,
+		Path:       "/",
+		Summary:    "Устаревший псевдоним POST /v1/calculate с JSON телом в GET запросе",
+		Deprecated: true,
+		Body:       handlefloatcalculation.Request{},
+		Results:    calculationResults,
+		Errors:     calculationErrors,
+	})
+	doc.Add(openapi.Route{
+		Method:     http.MethodPost,
+		Path:       "/evaluate",
+		Summary:    "Устаревший псевдоним POST /v1/evaluate",
+		Deprecated: true,
+		Body:       handleevaluation.Request{},
+		Results:    []any{handleevaluation.Response{}},
+		Errors:     evaluationErrors,
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodGet,
+		Path:    "/openapi.json",
+		Summary: "Эта спецификация",
+	})
+	doc.Add(openapi.Route{
+		Method:  http.MethodGet,
+		Path:    "/docs",
+		Summary: "Страница документации",
+	})
+	doc.Add(openapi.Route{
+		Method:    http.MethodGet,
+		Path:      "/healthz",
+		Summary:   "Проверка живости",
+		Results:   []any{response.Response{}},
+		Unlimited: true,
+	})
+	doc.Add(openapi.Route{
+		Method:    http.MethodGet,
+		Path:      "/readyz",
+		Summary:   "Проверка готовности, при остановке сервера отвечает 503",
+		Results:   []any{response.Response{}},
+		Errors:    []int{http.StatusServiceUnavailable},
+		Unlimited: true,
+	})
+	if cfg.AdminToken != "" {
+		doc.Add(openapi.Route{
+			Method:  http.MethodGet,
+			Path:    "/metrics/concurrency",
+			Summary: "Счётчики ограничения одновременных вычислений, требуется токен администратора",
+			Errors:  []int{http.StatusUnauthorized},
+		})
+		doc.Add(openapi.Route{
+			Method:  http.MethodGet,
+			Path:    "/admin/ratelimit/keys",
+			Summary: "Состояние лимитов клиентов, требуется токен администратора",
+			Query:   handleratelimit.ListRequest{},
+			Results: []any{handleratelimit.Response{}},
+			Errors:  []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+		})
+		doc.Add(openapi.Route{
+			Method:  http.MethodDelete,
+			Path:    "/admin/ratelimit/keys",
+			Summary: "Сброс лимита ключа, требуется токен администратора",
+			Query:   handleratelimit.ResetRequest{},
+			Results: []any{response.Response{}},
+			Errors:  []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError},
+		})
+	}
+	return doc
+}
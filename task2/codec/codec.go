@@ -0,0 +1,289 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/go-chi/render"
+	"github.com/shopspring/decimal"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// поддерживаемые форматы тела запроса и ответа
+const (
+	JSON        = "application/json"
+	Form        = "application/x-www-form-urlencoded"
+	XML         = "application/xml"
+	MessagePack = "application/msgpack"
+	CBOR        = "application/cbor"
+)
+
+// форматы ответа в порядке предпочтения при равных весах в Accept
+var MediaTypes = []string{JSON, XML, MessagePack, CBOR}
+
+// форматы тела запроса
+var RequestMediaTypes = []string{JSON, Form, XML, MessagePack, CBOR}
+
+var ErrUnsupportedMediaType = errors.New("неподдерживаемый формат тела запроса")
+
+// синонимы форматов
+var aliases = map[string]string{
+	"text/xml":                XML,
+	"application/x-msgpack":   MessagePack,
+	"application/vnd.msgpack": MessagePack,
+	"text/plain":              JSON, // прежние клиенты присылали JSON как текст
+}
+
+var (
+	cborEnc cbor.EncMode
+	cborDec cbor.DecMode
+	// декодирование в произвольный тип для проверки в строгом режиме
+	cborStrictDec cbor.DecMode
+)
+
+/*
+decimal.Decimal кодируется строкой во всех форматах, чтобы не терять точность:
+в JSON и XML через MarshalText, в CBOR - через опцию TextMarshaler,
+в MessagePack - через зарегистрированные функции.
+*/
+func init() {
+	var err error
+	cborEnc, err = cbor.EncOptions{
+		BinaryMarshaler: cbor.BinaryMarshalerNone,
+		TextMarshaler:   cbor.TextMarshalerTextString,
+	}.EncMode()
+	if err != nil {
+		panic(err)
+	}
+	cborDec, err = cbor.DecOptions{
+		BinaryUnmarshaler: cbor.BinaryUnmarshalerNone,
+		TextUnmarshaler:   cbor.TextUnmarshalerTextString,
+	}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	cborStrictDec, err = cbor.DecOptions{
+		DupMapKey:      cbor.DupMapKeyEnforcedAPF,
+		DefaultMapType: reflect.TypeOf(map[string]any(nil)),
+	}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	msgpack.Register(decimal.Decimal{},
+		func(e *msgpack.Encoder, v reflect.Value) error {
+			return e.EncodeString(v.Interface().(decimal.Decimal).String())
+		},
+		func(d *msgpack.Decoder, v reflect.Value) error {
+			value, err := d.DecodeInterfaceLoose()
+			if err != nil {
+				return err
+			}
+			var result decimal.Decimal
+			switch value := value.(type) {
+			case string:
+				result, err = decimal.NewFromString(value)
+			case int64:
+				result = decimal.NewFromInt(value)
+			case uint64:
+				result, err = decimal.NewFromString(strconv.FormatUint(value, 10))
+			case float64:
+				// в отличие от JSON, где decimal читает число из текста точно, float64 уже потерял точность, рекомендуется передавать строки
+				result = decimal.NewFromFloat(value)
+			default:
+				err = errors.New("decimal: неподдерживаемый тип значения")
+			}
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(result))
+			return nil
+		},
+	)
+}
+
+// формат тела запроса по Content-Type, пустой Content-Type - JSON
+func RequestType(r *http.Request) string {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return JSON
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	if alias, ok := aliases[mediaType]; ok {
+		return alias
+	}
+	for _, supported := range RequestMediaTypes {
+		if mediaType == supported {
+			return mediaType
+		}
+	}
+	return ""
+}
+
+/*
+Декодирование тела запроса в v по Content-Type.
+В строгом режиме (см. New) неизвестные и повторяющиеся поля возвращаются как *FieldError,
+а тело больше допустимого размера - как *http.MaxBytesError.
+*/
+func Decode(r *http.Request, v any) error {
+	mediaType := RequestType(r)
+	if mediaType == "" {
+		return ErrUnsupportedMediaType
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	strict := Strict(r.Context())
+	t := reflect.TypeOf(v)
+	switch mediaType {
+	case Form:
+		// curl -d по умолчанию отправляет JSON с Content-Type формы
+		if trimmed := bytes.TrimSpace(body); len(trimmed) == 0 || trimmed[0] != '{' {
+			values, err := url.ParseQuery(string(body))
+			if err != nil {
+				return err
+			}
+			if strict {
+				if err := CheckValues(values, v); err != nil {
+					return err
+				}
+			}
+			return DecodeValues(values, v)
+		}
+		fallthrough
+	case JSON:
+		if strict {
+			if err := checkJSON(body, t); err != nil {
+				return err
+			}
+		}
+		return render.DecodeJSON(bytes.NewReader(body), v)
+	case XML:
+		if strict {
+			if err := checkXML(body, t); err != nil {
+				return err
+			}
+		}
+		return xml.Unmarshal(body, v)
+	case MessagePack:
+		if strict {
+			dec := msgpack.NewDecoder(bytes.NewReader(body))
+			dec.SetMapDecoder(decodeMsgpackMap)
+			value, err := dec.DecodeInterface()
+			if err != nil {
+				return err
+			}
+			if err := checkDecoded(value, t); err != nil {
+				return err
+			}
+		}
+		dec := msgpack.NewDecoder(bytes.NewReader(body))
+		dec.SetCustomStructTag("json")
+		return dec.Decode(v)
+	default:
+		if strict {
+			var value any
+			if err := cborStrictDec.Unmarshal(body, &value); err != nil {
+				var duplicate *cbor.DupMapKeyError
+				if errors.As(err, &duplicate) {
+					return &FieldError{Field: fmt.Sprint(duplicate.Key), Rule: RuleDuplicate}
+				}
+				return err
+			}
+			if err := checkDecoded(value, t); err != nil {
+				return err
+			}
+		}
+		return cborDec.Unmarshal(body, v)
+	}
+}
+
+/*
+Формат ответа по заголовку Accept с учётом весов q.
+Если ни один из поддерживаемых форматов не подходит, ответ отправляется в JSON.
+*/
+func Negotiate(accept string) string {
+	type candidate struct {
+		mediaType string
+		q         float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if alias, ok := aliases[mediaType]; ok {
+			mediaType = alias
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		if mediaType == "*/*" || mediaType == "application/*" {
+			mediaType = JSON
+		}
+		for _, supported := range MediaTypes {
+			if mediaType == supported {
+				candidates = append(candidates, candidate{mediaType: mediaType, q: q})
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return JSON
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].mediaType
+}
+
+// отправка v со статусом status в формате, выбранном по заголовку Accept
+func Render(w http.ResponseWriter, r *http.Request, status int, v any) {
+	mediaType := Negotiate(r.Header.Get("Accept"))
+	w.Header().Add("Vary", "Accept")
+	if mediaType == JSON {
+		render.Status(r, status)
+		render.JSON(w, r, v)
+		return
+	}
+	var body []byte
+	var err error
+	switch mediaType {
+	case XML:
+		body, err = xml.Marshal(v)
+		body = append([]byte(xml.Header), body...)
+	case MessagePack:
+		var buf bytes.Buffer
+		enc := msgpack.NewEncoder(&buf)
+		enc.SetCustomStructTag("json")
+		err = enc.Encode(v)
+		body = buf.Bytes()
+	case CBOR:
+		body, err = cborEnc.Marshal(v)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(status)
+	w.Write(body)
+}
@@ -0,0 +1,252 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type testRequest struct {
+	X      decimal.Decimal   `json:"X"`
+	Values []decimal.Decimal `json:"values,omitempty" xml:"values,omitempty"`
+	E      *int32            `json:"E"`
+	Mode   string            `json:"precision_mode" xml:"precision_mode"`
+	Detail bool              `json:"detail" xml:"detail"`
+	Vars   DecimalMap        `json:"variables,omitempty" xml:"variables,omitempty"`
+}
+
+type testResponse struct {
+	Status string            `json:"status" xml:"status"`
+	Values []decimal.Decimal `json:"values" xml:"values"`
+	Groups []IntList         `json:"groups" xml:"groups"`
+}
+
+// значение, которое не представимо в float64 без потерь
+const precise = "0.12345678901234567890123456789"
+
+func TestRequestType(t *testing.T) {
+	cases := []struct {
+		name        string
+		contentType string
+		mediaType   string
+	}{
+		{name: "Без Content-Type", contentType: "", mediaType: JSON},
+		{name: "JSON с кодировкой", contentType: "application/json; charset=utf-8", mediaType: JSON},
+		{name: "Текст", contentType: "text/plain", mediaType: JSON},
+		{name: "Форма", contentType: "application/x-www-form-urlencoded", mediaType: Form},
+		{name: "XML", contentType: "text/xml", mediaType: XML},
+		{name: "MessagePack", contentType: "application/x-msgpack", mediaType: MessagePack},
+		{name: "CBOR", contentType: "application/cbor", mediaType: CBOR},
+		{name: "Неподдерживаемый", contentType: "application/octet-stream", mediaType: ""},
+		{name: "Некорректный", contentType: ";;", mediaType: ""},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.Header.Set("Content-Type", test_case.contentType)
+			require.Equal(t, test_case.mediaType, RequestType(req))
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	cases := []struct {
+		name      string
+		accept    string
+		mediaType string
+	}{
+		{name: "Без Accept", accept: "", mediaType: JSON},
+		{name: "Любой", accept: "*/*", mediaType: JSON},
+		{name: "XML", accept: "application/xml", mediaType: XML},
+		{name: "Вес", accept: "application/json;q=0.5, application/cbor", mediaType: CBOR},
+		{name: "Синоним", accept: "application/vnd.msgpack", mediaType: MessagePack},
+		{name: "Неподдерживаемый", accept: "image/png", mediaType: JSON},
+		{name: "Нулевой вес", accept: "application/xml;q=0, application/msgpack;q=0.1", mediaType: MessagePack},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, test_case.mediaType, Negotiate(test_case.accept))
+		})
+	}
+}
+
+// десятичные числа не теряют точность при декодировании ни в одном формате
+func TestDecode(t *testing.T) {
+	E := int32(5)
+	expected := testRequest{X: decimal.RequireFromString(precise), E: &E, Mode: "significant", Detail: true}
+	msgpackBody, err := msgpack.Marshal(map[string]any{"X": precise, "E": 5, "precision_mode": "significant", "detail": true, "variables": map[string]any{"a": precise, "b": "2"}})
+	require.NoError(t, err)
+	cborBody, err := cbor.Marshal(map[string]any{"X": precise, "E": 5, "precision_mode": "significant", "detail": true, "variables": map[string]any{"a": precise, "b": "2"}})
+	require.NoError(t, err)
+	cases := []struct {
+		name        string
+		contentType string
+		body        []byte
+	}{
+		{
+			name:        "JSON",
+			contentType: JSON,
+			body:        []byte(`{"X":"` + precise + `","E":5,"precision_mode":"significant","detail":true,"variables":{"a":"` + precise + `","b":"2"}}`),
+		},
+		{
+			name:        "JSON с Content-Type формы",
+			contentType: Form,
+			body:        []byte(` {"X":"` + precise + `","E":5,"precision_mode":"significant","detail":true,"variables":{"a":"` + precise + `","b":"2"}}`),
+		},
+		{
+			name:        "Форма",
+			contentType: Form,
+			body:        []byte(`X=` + precise + `&E=5&precision_mode=significant&detail=true&variables[a]=` + precise + `&variables[b]=2`),
+		},
+		{
+			name:        "XML",
+			contentType: XML,
+			body:        []byte(`<Request><X>` + precise + `</X><E>5</E><precision_mode>significant</precision_mode><detail>true</detail><variables><variable name="a">` + precise + `</variable><variable name="b">2</variable></variables></Request>`),
+		},
+		{
+			name:        "MessagePack",
+			contentType: MessagePack,
+			body:        msgpackBody,
+		},
+		{
+			name:        "CBOR",
+			contentType: CBOR,
+			body:        cborBody,
+		},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(test_case.body))
+			req.Header.Set("Content-Type", test_case.contentType)
+			var decoded testRequest
+			require.NoError(t, Decode(req, &decoded))
+			require.Equal(t, precise, decoded.X.String())
+			require.Equal(t, expected.E, decoded.E)
+			require.Equal(t, expected.Mode, decoded.Mode)
+			require.Equal(t, expected.Detail, decoded.Detail)
+			require.Len(t, decoded.Vars, 2)
+			require.Equal(t, precise, decoded.Vars["a"].String())
+			require.Equal(t, "2", decoded.Vars["b"].String())
+		})
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("x"))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	var decoded testRequest
+	require.ErrorIs(t, Decode(req, &decoded), ErrUnsupportedMediaType)
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("X=abc"))
+	req.Header.Set("Content-Type", Form)
+	require.ErrorContains(t, Decode(req, &decoded), "X:")
+
+	// целые числа в MessagePack тоже принимаются
+	body, err := msgpack.Marshal(map[string]any{"X": 7})
+	require.NoError(t, err)
+	req = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", MessagePack)
+	require.NoError(t, Decode(req, &decoded))
+	require.Equal(t, "7", decoded.X.String())
+}
+
+// десятичные числа не теряют точность при кодировании ни в одном формате
+func TestRender(t *testing.T) {
+	resp := testResponse{
+		Status: "OK",
+		Values: []decimal.Decimal{decimal.RequireFromString(precise), decimal.New(15, -1)},
+		Groups: IntLists([][]int{{0, 2}, {1}}),
+	}
+	decoders := map[string]func([]byte, *testResponse) error{
+		XML: func(b []byte, v *testResponse) error { return xml.Unmarshal(b, v) },
+		MessagePack: func(b []byte, v *testResponse) error {
+			dec := msgpack.NewDecoder(bytes.NewReader(b))
+			dec.SetCustomStructTag("json")
+			return dec.Decode(v)
+		},
+		CBOR: func(b []byte, v *testResponse) error { return cborDec.Unmarshal(b, v) },
+	}
+	for mediaType, decode := range decoders {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", mediaType)
+		rr := httptest.NewRecorder()
+		Render(rr, req, http.StatusCreated, resp)
+		require.Equal(t, http.StatusCreated, rr.Code, mediaType)
+		require.Equal(t, mediaType, rr.Header().Get("Content-Type"))
+		var decoded testResponse
+		require.NoError(t, decode(rr.Body.Bytes(), &decoded), mediaType)
+		require.Equal(t, "OK", decoded.Status, mediaType)
+		require.Len(t, decoded.Values, 2, mediaType)
+		require.Equal(t, precise, decoded.Values[0].String(), mediaType)
+		require.Equal(t, resp.Groups, decoded.Groups, mediaType)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rr := httptest.NewRecorder()
+	Render(rr, req, http.StatusOK, resp)
+	require.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	require.JSONEq(t, `{"status":"OK","values":["`+precise+`","1.5"],"groups":[[0,2],[1]]}`, rr.Body.String())
+
+	req.Header.Set("Accept", XML)
+	rr = httptest.NewRecorder()
+	Render(rr, req, http.StatusOK, resp)
+	require.Contains(t, rr.Body.String(), "<groups>0 2</groups><groups>1</groups>")
+}
+
+func TestDecodeValues(t *testing.T) {
+	var decoded testRequest
+	values := url.Values{}
+	values.Set("X", precise)
+	values.Set("E", "0")
+	require.NoError(t, DecodeValues(values, &decoded))
+	require.Equal(t, precise, decoded.X.String())
+	require.Equal(t, int32(0), *decoded.E)
+	require.False(t, decoded.Detail)
+
+	values.Set("E", "99999999999")
+	require.Error(t, DecodeValues(values, &decoded))
+	values.Set("E", "1")
+	values.Set("detail", "maybe")
+	require.Error(t, DecodeValues(values, &decoded))
+	require.Error(t, DecodeValues(values, decoded))
+
+	// элементы карты передаются параметрами вида name[key]
+	values = url.Values{"variables[a]": {"1.5"}, "variables[]": {"2"}, "variables": {"3"}}
+	require.ErrorContains(t, DecodeValues(values, &decoded), "variables:")
+	values.Del("variables")
+	decoded = testRequest{}
+	require.NoError(t, DecodeValues(values, &decoded))
+	require.Equal(t, DecimalMap{"a": decimal.New(15, -1), "": decimal.New(2, 0)}, decoded.Vars)
+	values.Set("variables[b]", "x")
+	require.ErrorContains(t, DecodeValues(values, &decoded), "variables[b]:")
+}
+
+// переменные в XML кодируются списком элементов и декодируются обратно без потерь
+func TestDecimalMap_XML(t *testing.T) {
+	vars := DecimalMap{"b": decimal.New(2, 0), "a": decimal.RequireFromString(precise)}
+	data, err := xml.Marshal(testRequest{Vars: vars})
+	require.NoError(t, err)
+	require.Contains(t, string(data), `<variables><variable name="a">`+precise+`</variable><variable name="b">2</variable></variables>`)
+	var decoded testRequest
+	require.NoError(t, xml.Unmarshal(data, &decoded))
+	require.Equal(t, vars, decoded.Vars)
+
+	// вне строгого режима неизвестные элементы пропускаются
+	require.NoError(t, xml.Unmarshal([]byte(`<Request><variables><var name="x">1</var><variable name="a">1</variable></variables></Request>`), &decoded))
+	require.Equal(t, DecimalMap{"a": decimal.New(1, 0)}, decoded.Vars)
+}
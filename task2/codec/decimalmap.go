@@ -0,0 +1,104 @@
+package codec
+
+import (
+	"encoding/xml"
+	"reflect"
+	"sort"
+
+	"github.com/shopspring/decimal"
+)
+
+/*
+Именованные десятичные числа, которые в XML записываются списком элементов
+<variable name="a">1</variable>: encoding/xml не умеет кодировать карты.
+В форме и строке запроса значения передаются параметрами вида variables[a]=1,
+в остальных форматах это обычный объект.
+*/
+type DecimalMap map[string]decimal.Decimal
+
+var decimalMapType = reflect.TypeOf(DecimalMap(nil))
+
+type decimalMapItem struct {
+	Name  string          `xml:"name,attr"`
+	Value decimal.Decimal `xml:",chardata"`
+}
+
+const decimalMapItemName = "variable"
+
+func (m DecimalMap) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	item := xml.StartElement{Name: xml.Name{Local: decimalMapItemName}}
+	for _, name := range names {
+		if err := e.EncodeElement(decimalMapItem{Name: name, Value: m[name]}, item); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// неизвестные дочерние элементы пропускаются, из повторяющихся имён действует последнее
+func (m *DecimalMap) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	result := DecimalMap{}
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if tok.Name.Local != decimalMapItemName {
+				if err := d.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			var item decimalMapItem
+			if err := d.DecodeElement(&item, &tok); err != nil {
+				return err
+			}
+			result[item.Name] = item.Value
+		case xml.EndElement:
+			*m = result
+			return nil
+		}
+	}
+}
+
+// проверка элементов DecimalMap в строгом режиме
+func checkDecimalMapXML(dec *xml.Decoder, path string) error {
+	seen := make(map[string]bool)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if tok.Name.Local != decimalMapItemName {
+				return &FieldError{Field: joinPath(path, tok.Name.Local), Rule: RuleUnknown}
+			}
+			var name string
+			for _, attr := range tok.Attr {
+				if attr.Name.Local == "name" {
+					name = attr.Value
+				}
+			}
+			if seen[name] {
+				return &FieldError{Field: joinPath(path, name), Rule: RuleDuplicate}
+			}
+			seen[name] = true
+			if err := dec.Skip(); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
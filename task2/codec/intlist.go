@@ -0,0 +1,47 @@
+package codec
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+/*
+Список целых чисел, который в XML записывается одним элементом через пробел.
+Без этого encoding/xml разворачивает вложенные срезы в общий список элементов
+и теряет границы между ними. В остальных форматах это обычный массив.
+*/
+type IntList []int
+
+func IntLists(lists [][]int) []IntList {
+	result := make([]IntList, 0, len(lists))
+	for _, list := range lists {
+		result = append(result, IntList(list))
+	}
+	return result
+}
+
+func (l IntList) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	items := make([]string, 0, len(l))
+	for _, item := range l {
+		items = append(items, strconv.Itoa(item))
+	}
+	return e.EncodeElement(strings.Join(items, " "), start)
+}
+
+func (l *IntList) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var text string
+	if err := d.DecodeElement(&text, &start); err != nil {
+		return err
+	}
+	list := IntList{}
+	for _, field := range strings.Fields(text) {
+		item, err := strconv.Atoi(field)
+		if err != nil {
+			return err
+		}
+		list = append(list, item)
+	}
+	*l = list
+	return nil
+}
@@ -0,0 +1,342 @@
+package codec
+
+import (
+	"FloatService/i18n"
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// правила строгого режима, нарушенные полем
+const (
+	RuleUnknown   = "unknown"
+	RuleDuplicate = "duplicate"
+)
+
+/*
+Поле тела или строки запроса, отклонённое в строгом режиме.
+Field - путь к полю вида Values[0].Factors.
+*/
+type FieldError struct {
+	Field string
+	Rule  string
+}
+
+func (e *FieldError) Error() string {
+	return e.Localize(i18n.Fallback)
+}
+
+func (e *FieldError) Localize(lang string) string {
+	if e.Rule == RuleDuplicate {
+		return i18n.T(lang, i18n.DuplicateField, e.Field)
+	}
+	return i18n.T(lang, i18n.UnknownField, e.Field)
+}
+
+type optionsCtxKey struct{}
+
+type options struct {
+	strict bool
+}
+
+/*
+Ограничение размера тела запроса maxBodySize байтами (0 - без ограничения)
+и включение строгого режима декодирования, в котором неизвестные
+и повторяющиеся поля отклоняются, а имена полей сравниваются с учётом регистра.
+*/
+func New(maxBodySize int64, strict bool) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if maxBodySize > 0 {
+				r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+			}
+			ctx := context.WithValue(r.Context(), optionsCtxKey{}, options{strict: strict})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// включён ли строгий режим декодирования для запроса
+func Strict(ctx context.Context) bool {
+	opts, _ := ctx.Value(optionsCtxKey{}).(options)
+	return opts.strict
+}
+
+/*
+Проверка параметров формы или строки запроса для структуры, на которую указывает v:
+каждый параметр должен соответствовать полю (элементу поля-карты в виде name[key])
+и встречаться один раз.
+*/
+func CheckValues(values url.Values, v any) error {
+	fields := structFields(indirect(reflect.TypeOf(v)), "json")
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := fields[name]; !ok && !isMapParam(fields, name) {
+			return &FieldError{Field: name, Rule: RuleUnknown}
+		}
+		if len(values[name]) > 1 {
+			return &FieldError{Field: name, Rule: RuleDuplicate}
+		}
+	}
+	return nil
+}
+
+// параметр вида name[key] для поля-карты name
+func isMapParam(fields map[string]reflect.Type, param string) bool {
+	name, _, ok := strings.Cut(param, "[")
+	if !ok {
+		return false
+	}
+	field, ok := fields[name]
+	if !ok || indirect(field).Kind() != reflect.Map {
+		return false
+	}
+	_, ok = mapKey(param, name)
+	return ok
+}
+
+// проверка JSON на неизвестные и повторяющиеся поля по типу t
+func checkJSON(data []byte, t reflect.Type) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return checkJSONValue(dec, t, "")
+}
+
+/*
+Проверка очередного значения JSON. Если t равен nil (значение произвольного типа
+или тип с собственным декодированием), проверяются только повторяющиеся ключи.
+*/
+func checkJSONValue(dec *json.Decoder, t reflect.Type, path string) error {
+	t = schemaType(t)
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('{'):
+		var fields map[string]reflect.Type
+		if t != nil && t.Kind() == reflect.Struct {
+			fields = structFields(t, "json")
+		}
+		seen := make(map[string]bool)
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key, _ := tok.(string)
+			fieldPath := joinPath(path, key)
+			if seen[key] {
+				return &FieldError{Field: fieldPath, Rule: RuleDuplicate}
+			}
+			seen[key] = true
+			var elem reflect.Type
+			switch {
+			case fields != nil:
+				var ok bool
+				if elem, ok = fields[key]; !ok {
+					return &FieldError{Field: fieldPath, Rule: RuleUnknown}
+				}
+			case t != nil && t.Kind() == reflect.Map:
+				elem = t.Elem()
+			}
+			if err := checkJSONValue(dec, elem, fieldPath); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+		return err
+	case json.Delim('['):
+		var elem reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elem = t.Elem()
+		}
+		for i := 0; dec.More(); i++ {
+			if err := checkJSONValue(dec, elem, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+		return err
+	}
+	return nil
+}
+
+// проверка XML на неизвестные элементы и повторяющиеся неповторяемые элементы по типу t
+func checkXML(data []byte, t reflect.Type) error {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		// имя корневого элемента не проверяется
+		if _, ok := tok.(xml.StartElement); ok {
+			return checkXMLElement(dec, t, "")
+		}
+	}
+}
+
+func checkXMLElement(dec *xml.Decoder, t reflect.Type, path string) error {
+	if indirect(t) == decimalMapType {
+		return checkDecimalMapXML(dec, path)
+	}
+	t = schemaType(t)
+	var fields map[string]reflect.Type
+	if t != nil && t.Kind() == reflect.Struct {
+		fields = structFields(t, "xml")
+	}
+	counts := make(map[string]int)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if fields == nil {
+				if err := dec.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			name := tok.Name.Local
+			fieldPath := joinPath(path, name)
+			field, ok := fields[name]
+			if !ok {
+				return &FieldError{Field: fieldPath, Rule: RuleUnknown}
+			}
+			// повторяющийся элемент допустим только для срезов
+			if field.Kind() == reflect.Slice && schemaType(field) != nil {
+				fieldPath = fmt.Sprintf("%s[%d]", fieldPath, counts[name])
+				field = field.Elem()
+			} else if counts[name] > 0 {
+				return &FieldError{Field: fieldPath, Rule: RuleDuplicate}
+			}
+			counts[name]++
+			if err := checkXMLElement(dec, field, fieldPath); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	xmlUnmarshalerType  = reflect.TypeOf((*xml.Unmarshaler)(nil)).Elem()
+)
+
+// тип, по которому проверяется значение, или nil для произвольных значений и типов с собственным декодированием
+func schemaType(t reflect.Type) reflect.Type {
+	t = indirect(t)
+	if t == nil || t.Kind() == reflect.Interface {
+		return nil
+	}
+	ptr := reflect.PointerTo(t)
+	if ptr.Implements(jsonUnmarshalerType) || ptr.Implements(xmlUnmarshalerType) || ptr.Implements(textUnmarshalerType) {
+		return nil
+	}
+	return t
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+/*
+Поля структуры t по именам из тега tag (json или xml), имена сравниваются с учётом регистра.
+Поля встроенных структур без имени в теге поднимаются на уровень t, как при декодировании.
+*/
+func structFields(t reflect.Type, tag string) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	if t == nil || t.Kind() != reflect.Struct {
+		return fields
+	}
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if field.Anonymous && name == "" {
+			if ft := indirect(field.Type); ft.Kind() == reflect.Struct {
+				embedded = append(embedded, ft)
+				continue
+			}
+		}
+		// атрибуты и содержимое XML элемента не являются дочерними элементами
+		if !field.IsExported() || name == "-" || (tag == "xml" && opts != "" && opts != "omitempty") {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+	// поля самой структуры имеют приоритет над полями встроенных
+	for _, ft := range embedded {
+		for name, field := range structFields(ft, tag) {
+			if _, ok := fields[name]; !ok {
+				fields[name] = field
+			}
+		}
+	}
+	return fields
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+/*
+Проверка значения, декодированного из MessagePack или CBOR в произвольный тип,
+через его представление в JSON.
+*/
+func checkDecoded(value any, t reflect.Type) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return checkJSON(data, t)
+}
+
+// декодирование карты MessagePack с отклонением повторяющихся ключей
+func decodeMsgpackMap(dec *msgpack.Decoder) (any, error) {
+	n, err := dec.DecodeMapLen()
+	if err != nil || n < 0 {
+		return nil, err
+	}
+	m := make(map[string]any, n)
+	for i := 0; i < n; i++ {
+		key, err := dec.DecodeString()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := m[key]; ok {
+			return nil, &FieldError{Field: key, Rule: RuleDuplicate}
+		}
+		if m[key], err = dec.DecodeInterface(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,218 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type strictOperand struct {
+	Factors []decimal.Decimal `json:"Factors"`
+}
+
+type strictBase struct {
+	Detail bool `json:"detail" xml:"detail"`
+}
+
+type strictRequest struct {
+	strictBase
+	X         decimal.Decimal `json:"X"`
+	E         *int32          `json:"E"`
+	Values    []strictOperand `json:"Values,omitempty"`
+	Variables DecimalMap      `json:"variables,omitempty" xml:"variables,omitempty"`
+}
+
+// запрос с включённым строгим режимом и ограничением размера тела
+func strictRequestOf(contentType string, body []byte, maxBodySize int64) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	var result *http.Request
+	New(maxBodySize, true)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result = r
+	})).ServeHTTP(httptest.NewRecorder(), req)
+	return result
+}
+
+func TestDecode_Strict(t *testing.T) {
+	msgpackUnknown, _ := msgpack.Marshal(map[string]any{"X": "1", "Z": "2"})
+	cborUnknown, _ := cbor.Marshal(map[string]any{"X": "1", "Values": []any{map[string]any{"Factors": []string{"1"}, "Divisors": []string{"2"}}}})
+	// карта с повторяющимся ключом X
+	cborDuplicate := []byte{0xa2, 0x61, 'X', 0x61, '1', 0x61, 'X', 0x61, '2'}
+	cases := []struct {
+		name        string
+		contentType string
+		body        []byte
+		field       *FieldError
+	}{
+		{
+			name:        "Корректный JSON",
+			contentType: JSON,
+			body:        []byte(`{"X":"1","E":5,"detail":true,"Values":[{"Factors":["1"]}],"variables":{"a":"1"}}`),
+		},
+		{
+			name:        "Имя поля в другом регистре",
+			contentType: JSON,
+			body:        []byte(`{"x":"1","E":5}`),
+			field:       &FieldError{Field: "x", Rule: RuleUnknown},
+		},
+		{
+			name:        "Неизвестное вложенное поле",
+			contentType: JSON,
+			body:        []byte(`{"X":"1","Values":[{"Factors":["1"]},{"Factors":["2"],"Divisors":["3"]}]}`),
+			field:       &FieldError{Field: "Values[1].Divisors", Rule: RuleUnknown},
+		},
+		{
+			name:        "Повторяющееся поле",
+			contentType: JSON,
+			body:        []byte(`{"X":"1","E":5,"X":"2"}`),
+			field:       &FieldError{Field: "X", Rule: RuleDuplicate},
+		},
+		{
+			name:        "Повторяющаяся переменная",
+			contentType: JSON,
+			body:        []byte(`{"variables":{"a":"1","a":"2"}}`),
+			field:       &FieldError{Field: "variables.a", Rule: RuleDuplicate},
+		},
+		{
+			name:        "Неизвестный параметр формы",
+			contentType: Form,
+			body:        []byte(`X=1&Z=2`),
+			field:       &FieldError{Field: "Z", Rule: RuleUnknown},
+		},
+		{
+			name:        "Повторяющийся параметр формы",
+			contentType: Form,
+			body:        []byte(`X=1&X=2`),
+			field:       &FieldError{Field: "X", Rule: RuleDuplicate},
+		},
+		{
+			name:        "Неизвестное поле JSON с Content-Type формы",
+			contentType: Form,
+			body:        []byte(`{"X":"1","Y":"2"}`),
+			field:       &FieldError{Field: "Y", Rule: RuleUnknown},
+		},
+		{
+			name:        "Корректный XML",
+			contentType: XML,
+			body:        []byte(`<Request><X>1</X><detail>true</detail><Values><Factors>1</Factors><Factors>2</Factors></Values><Values><Factors>3</Factors></Values></Request>`),
+		},
+		{
+			name:        "Неизвестный элемент XML",
+			contentType: XML,
+			body:        []byte(`<Request><X>1</X><Values><Factor>1</Factor></Values></Request>`),
+			field:       &FieldError{Field: "Values[0].Factor", Rule: RuleUnknown},
+		},
+		{
+			name:        "Повторяющийся элемент XML",
+			contentType: XML,
+			body:        []byte(`<Request><X>1</X><X>2</X></Request>`),
+			field:       &FieldError{Field: "X", Rule: RuleDuplicate},
+		},
+		{
+			name:        "Корректные переменные XML",
+			contentType: XML,
+			body:        []byte(`<Request><variables><variable name="a">1</variable><variable name="b">2</variable></variables></Request>`),
+		},
+		{
+			name:        "Неизвестный элемент переменных XML",
+			contentType: XML,
+			body:        []byte(`<Request><variables><var name="a">1</var></variables></Request>`),
+			field:       &FieldError{Field: "variables.var", Rule: RuleUnknown},
+		},
+		{
+			name:        "Повторяющаяся переменная XML",
+			contentType: XML,
+			body:        []byte(`<Request><variables><variable name="a">1</variable><variable name="a">2</variable></variables></Request>`),
+			field:       &FieldError{Field: "variables.a", Rule: RuleDuplicate},
+		},
+		{
+			name:        "Корректные переменные формы",
+			contentType: Form,
+			body:        []byte(`X=1&variables[a]=1&variables[b]=2`),
+		},
+		{
+			name:        "Повторяющаяся переменная формы",
+			contentType: Form,
+			body:        []byte(`variables[a]=1&variables[a]=2`),
+			field:       &FieldError{Field: "variables[a]", Rule: RuleDuplicate},
+		},
+		{
+			name:        "Неизвестное поле MessagePack",
+			contentType: MessagePack,
+			body:        msgpackUnknown,
+			field:       &FieldError{Field: "Z", Rule: RuleUnknown},
+		},
+		{
+			name:        "Неизвестное поле CBOR",
+			contentType: CBOR,
+			body:        cborUnknown,
+			field:       &FieldError{Field: "Values[0].Divisors", Rule: RuleUnknown},
+		},
+		{
+			name:        "Повторяющееся поле CBOR",
+			contentType: CBOR,
+			body:        cborDuplicate,
+			field:       &FieldError{Field: "X", Rule: RuleDuplicate},
+		},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			var decoded strictRequest
+			err := Decode(strictRequestOf(test_case.contentType, test_case.body, 0), &decoded)
+			if test_case.field == nil {
+				require.NoError(t, err)
+				return
+			}
+			var field *FieldError
+			require.True(t, errors.As(err, &field), "ошибка %v", err)
+			require.Equal(t, test_case.field, field)
+		})
+	}
+}
+
+// без строгого режима неизвестные поля игнорируются, как и раньше
+func TestDecode_NotStrict(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"X":"1","Z":"2","X":"3"}`))
+	var decoded strictRequest
+	require.NoError(t, Decode(req, &decoded))
+	require.Equal(t, "3", decoded.X.String())
+}
+
+func TestDecode_BodyTooLarge(t *testing.T) {
+	body := []byte(`{"X":"` + strings.Repeat("1", 100) + `"}`)
+	for _, contentType := range RequestMediaTypes {
+		var decoded strictRequest
+		err := Decode(strictRequestOf(contentType, body, 64), &decoded)
+		var tooLarge *http.MaxBytesError
+		require.True(t, errors.As(err, &tooLarge), contentType)
+		require.Equal(t, int64(64), tooLarge.Limit)
+	}
+	var decoded strictRequest
+	require.NoError(t, Decode(strictRequestOf(JSON, body, int64(len(body))), &decoded))
+}
+
+func TestCheckValues(t *testing.T) {
+	var req strictRequest
+	require.NoError(t, CheckValues(url.Values{"X": {"1"}, "E": {"2"}, "detail": {"true"}}, &req))
+	require.Equal(t, &FieldError{Field: "e", Rule: RuleUnknown}, CheckValues(url.Values{"e": {"1"}}, &req))
+	require.Equal(t, &FieldError{Field: "E", Rule: RuleDuplicate}, CheckValues(url.Values{"E": {"1", "2"}}, &req))
+	require.NoError(t, CheckValues(url.Values{"variables[a]": {"1"}}, &req))
+	require.Equal(t, &FieldError{Field: "X[a]", Rule: RuleUnknown}, CheckValues(url.Values{"X[a]": {"1"}}, &req))
+	require.Equal(t, &FieldError{Field: "variables[a", Rule: RuleUnknown}, CheckValues(url.Values{"variables[a": {"1"}}, &req))
+}
+
+func TestFieldError_Localize(t *testing.T) {
+	require.Equal(t, "Неизвестное поле x1.", (&FieldError{Field: "x1", Rule: RuleUnknown}).Error())
+	require.Equal(t, "Field X1 is specified more than once.", (&FieldError{Field: "X1", Rule: RuleDuplicate}).Localize("en"))
+}
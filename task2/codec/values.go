@@ -0,0 +1,132 @@
+package codec
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+/*
+Заполнение структуры, на которую указывает v, из параметров формы или строки запроса.
+Имена параметров берутся из тегов json, отсутствующие параметры не меняют поля.
+Поддерживаются скалярные поля, типы, реализующие encoding.TextUnmarshaler,
+и карты со строковыми ключами, элементы которых передаются параметрами вида name[key].
+*/
+func DecodeValues(values url.Values, v any) error {
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Pointer || target.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("ожидался указатель на структуру, получен %T", v)
+	}
+	target = target.Elem()
+	t := target.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" {
+			name = field.Name
+		}
+		if name == "-" {
+			continue
+		}
+		if field.Type.Kind() == reflect.Map {
+			if err := setMap(target.Field(i), name, values); err != nil {
+				return err
+			}
+			continue
+		}
+		if !values.Has(name) {
+			continue
+		}
+		if err := setString(target.Field(i), values.Get(name)); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// заполнение карты из параметров вида name[key], без таких параметров карта не меняется
+func setMap(field reflect.Value, name string, values url.Values) error {
+	if field.Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("%s: неподдерживаемый тип %s", name, field.Type())
+	}
+	if values.Has(name) {
+		return fmt.Errorf("%s: ожидались параметры вида %s[ключ]", name, name)
+	}
+	for param := range values {
+		key, ok := mapKey(param, name)
+		if !ok {
+			continue
+		}
+		if field.IsNil() {
+			field.Set(reflect.MakeMap(field.Type()))
+		}
+		elem := reflect.New(field.Type().Elem()).Elem()
+		if err := setString(elem, values.Get(param)); err != nil {
+			return fmt.Errorf("%s: %w", param, err)
+		}
+		field.SetMapIndex(reflect.ValueOf(key).Convert(field.Type().Key()), elem)
+	}
+	return nil
+}
+
+// ключ карты из имени параметра вида name[key]
+func mapKey(param, name string) (string, bool) {
+	key, ok := strings.CutPrefix(param, name+"[")
+	if !ok || !strings.HasSuffix(key, "]") {
+		return "", false
+	}
+	return strings.TrimSuffix(key, "]"), true
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+func setString(field reflect.Value, value string) error {
+	if field.Kind() == reflect.Pointer {
+		elem := reflect.New(field.Type().Elem())
+		if err := setString(elem.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+	if field.Addr().Type().Implements(textUnmarshalerType) {
+		return field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(parsed)
+	default:
+		return fmt.Errorf("неподдерживаемый тип %s", field.Type())
+	}
+	return nil
+}
@@ -1,8 +1,12 @@
 package config
 
 import (
+	"FloatService/i18n"
+	"fmt"
 	"log"
+	"net/netip"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
@@ -10,33 +14,395 @@ import (
 
 // структуры хранения конфигурации
 type Config struct {
-	Env        string `yaml:"env" env-required:"true"`
-	HTTPServer `yaml:"http_server"`
+	Env          string `yaml:"env" env-required:"true"`
+	HTTPServer   `yaml:"http_server"`
+	Calculation  `yaml:"calculation"`
+	Evaluation   `yaml:"evaluation"`
+	Localization `yaml:"localization"`
+	APIVersions  map[string]APIVersion `yaml:"api_versions"`
 }
 
 type HTTPServer struct {
-	Address     string        `yaml:"address" env-required:"true"`
-	Timeout     time.Duration `yaml:"timeout" env-default:"5s"`
-	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"60s"`
-	StopTimeout time.Duration `yaml:"stop_timeout" env-default:"1ms"`
-	RateLimit   `yaml:"rate limit"`
+	Address        string        `yaml:"address" env-required:"true"`
+	Timeout        time.Duration `yaml:"timeout" env-default:"5s"`
+	IdleTimeout    time.Duration `yaml:"idle_timeout" env-default:"60s"`
+	StopTimeout    time.Duration `yaml:"stop_timeout" env-default:"1ms"`
+	ShutdownDelay  time.Duration `yaml:"shutdown_delay" env-default:"5s"` // сколько /readyz отвечает 503 до остановки сервера
+	ComputeTimeout time.Duration `yaml:"compute_timeout" env-default:"3s"`
+	LegacyStatus   bool          `yaml:"legacy_status_codes" env-default:"false"`
+	ProblemJSON    bool          `yaml:"problem_json" env-default:"false"`
+	MaxBodySize    int64         `yaml:"max_body_size" env-default:"1048576"` // в байтах, 0 - без ограничения
+	StrictDecoding bool          `yaml:"strict_decoding" env-default:"false"`
+	AdminToken     string        `yaml:"admin_token" env:"ADMIN_TOKEN"` // без токена маршруты /admin не подключаются
+	RateLimit      `yaml:"rate limit"`
+	Concurrency    `yaml:"concurrency"`
+	IPFilter       `yaml:"ip_filter"`
 }
 
+/*
+Списки адресов и подсетей CIDR клиентов (с учётом доверенных прокси RateLimit.TrustedProxies).
+Запросы из Deny отклоняются со статусом 403, а запросы из Allow не ограничиваются лимитом запросов.
+Если адрес входит в подсети обоих списков, действует более узкая подсеть, а при равных - Deny.
+*/
+type IPFilter struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+}
+
+// разбор списков Allow и Deny
+func (f IPFilter) Prefixes() (allow, deny []netip.Prefix, err error) {
+	if allow, err = parsePrefixes(f.Allow); err != nil {
+		return nil, nil, fmt.Errorf("некорректный адрес в allow: %w", err)
+	}
+	if deny, err = parsePrefixes(f.Deny); err != nil {
+		return nil, nil, fmt.Errorf("некорректный адрес в deny: %w", err)
+	}
+	return allow, deny, nil
+}
+
+/*
+Ограничение одновременных вычислений: выполняется не больше MaxInFlight запросов
+(0 - без ограничения), ещё до QueueSize запросов ждут освобождения места не дольше QueueTimeout,
+а остальные отклоняются со статусом 503 и заголовком Retry-After, равным RetryAfter.
+*/
+type Concurrency struct {
+	MaxInFlight  int           `yaml:"max_in_flight"`
+	QueueSize    int           `yaml:"queue_size"`
+	QueueTimeout time.Duration `yaml:"queue_timeout" env-default:"1s"`
+	RetryAfter   time.Duration `yaml:"retry_after" env-default:"1s"`
+}
+
+func (c Concurrency) validate() error {
+	if c.MaxInFlight < 0 || c.QueueSize < 0 {
+		return fmt.Errorf("отрицательный max_in_flight или queue_size")
+	}
+	if c.QueueTimeout <= 0 || c.RetryAfter <= 0 {
+		return fmt.Errorf("неположительный queue_timeout или retry_after")
+	}
+	return nil
+}
+
+/*
+Лимит запросов отдельно для каждого ключа клиента.
+Mode - режим лимита (ModeEnabled, ModeDisabled, ModeDenyAll).
+Limit запросов за Interval - устойчивая скорость, Burst - сколько запросов
+можно сделать подряд после простоя (по умолчанию Limit).
+KeyBy - стратегии ключа (KeyByIP, KeyByAPIKey, KeyByHeaderPrefix+<имя заголовка>),
+несколько стратегий объединяются в составной ключ.
+KnownAPIKeys - API ключи, которые стратегия KeyByAPIKey принимает наряду с ключами APIKeys.
+TrustedProxies - адреса или подсети CIDR прокси, которым доверяется
+передача IP клиента в X-Forwarded-For и X-Real-IP.
+CalculationCost - дополнительный вес запросов вычислений.
+Если заданы тарифы Plans, вместо Limit, Interval и Burst действуют лимиты тарифа:
+из APIKeys по API ключу клиента, а для остальных клиентов - DefaultPlan.
+RouteCosts - сколько запросов расходует запрос к маршруту вида "POST /v2/calculate" (по умолчанию 1).
+QuotaStore - файл, в котором хранятся израсходованные дневные квоты,
+QuotaFlushInterval - как часто счётчики квот из памяти записываются в этот файл.
+*/
 type RateLimit struct {
-	Limit    int           `yaml:"limit" env-default:"100"`
-	Interval time.Duration `yaml:"interval" env-default:"60s"`
-	Msg      string        `yaml:"msg" env-default:"Слишком много запросов."`
+	Mode               string            `yaml:"mode" env-default:"enabled"`
+	Limit              int               `yaml:"limit" env-default:"100"`
+	Interval           time.Duration     `yaml:"interval" env-default:"60s"`
+	Burst              int               `yaml:"burst"`
+	Msg                string            `yaml:"msg"` // если не задано, сообщение переводится на язык клиента
+	KeyBy              []string          `yaml:"key_by" env-default:"ip"`
+	TrustedProxies     []string          `yaml:"trusted_proxies"`
+	APIKeyHeader       string            `yaml:"api_key_header" env-default:"X-API-Key"`
+	KnownAPIKeys       []string          `yaml:"known_api_keys"`
+	Redis              RateLimitRedis    `yaml:"redis"`
+	Plans              map[string]Plan   `yaml:"plans"`
+	DefaultPlan        string            `yaml:"default_plan"`
+	APIKeys            map[string]string `yaml:"api_keys"` // API ключ -> тариф
+	RouteCosts         map[string]int    `yaml:"route_costs"`
+	QuotaStore         string            `yaml:"quota_store" env-default:"quotas.db"`
+	QuotaFlushInterval time.Duration     `yaml:"quota_flush_interval" env-default:"5s"`
+	CalculationCost    `yaml:"calculation_cost"`
+}
+
+/*
+Вес запроса вычислений сверх стоимости маршрута: по одному запросу за каждые
+PrecisionStep знаков точности E и за каждые DigitsStep цифр всех операндов,
+но не больше MaxExtra. Нулевой шаг отключает слагаемое, а нулевой MaxExtra - весь вес,
+отрицательная точность (округление до десятков, сотен и т. д.) не учитывается.
+*/
+type CalculationCost struct {
+	PrecisionStep int `yaml:"precision_step"`
+	DigitsStep    int `yaml:"digits_step"`
+	MaxExtra      int `yaml:"max_extra"`
+}
+
+// вес запроса с точностью precision и операндами из digits цифр
+func (c CalculationCost) Extra(precision int32, digits int) int {
+	extra := 0
+	if c.PrecisionStep > 0 {
+		extra += max(int(precision), 0) / c.PrecisionStep
+	}
+	if c.DigitsStep > 0 {
+		extra += digits / c.DigitsStep
+	}
+	return min(extra, c.MaxExtra)
+}
+
+// лимиты тарифа в секунду, минуту и сутки (UTC), 0 - без ограничения
+type Plan struct {
+	PerSecond int `yaml:"per_second"`
+	PerMinute int `yaml:"per_minute"`
+	PerDay    int `yaml:"per_day"`
+}
+
+// стоимость запроса к маршруту method path
+func (rl RateLimit) RouteCost(method, path string) int {
+	if cost, ok := rl.RouteCosts[method+" "+path]; ok {
+		return cost
+	}
+	return 1
+}
+
+/*
+Общее хранилище лимитов для нескольких экземпляров сервиса, совместимое с протоколом Redis.
+Без адреса лимит хранится в памяти процесса. Если хранилище недоступно,
+действует локальный лимит, а хранилище проверяется снова через RetryInterval.
+*/
+type RateLimitRedis struct {
+	Address       string        `yaml:"address"`
+	Password      string        `yaml:"password" env:"RATE_LIMIT_REDIS_PASSWORD"`
+	DB            int           `yaml:"db"`
+	Prefix        string        `yaml:"prefix" env-default:"floatservice:ratelimit:"`
+	Timeout       time.Duration `yaml:"timeout" env-default:"100ms"`
+	RetryInterval time.Duration `yaml:"retry_interval" env-default:"5s"`
+}
+
+// режимы лимита запросов
+const (
+	ModeEnabled  = "enabled"  // запросы ограничиваются лимитом или тарифами
+	ModeDisabled = "disabled" // запросы не ограничиваются
+	ModeDenyAll  = "deny_all" // все запросы отклоняются со статусом 429
+)
+
+/*
+Наименьший интервал между запросами при устойчивой скорости: хранилище лимита Redis
+считает время в микросекундах, и меньший интервал округлился бы до нуля.
+*/
+const minEmission = time.Microsecond
+
+// стратегии ключа лимита запросов
+const (
+	KeyByIP           = "ip"      // IP клиента с учётом доверенных прокси
+	KeyByAPIKey       = "api_key" // известный API ключ из заголовка APIKeyHeader
+	KeyByHeaderPrefix = "header:" // header:<имя> - значение произвольного заголовка вместе с IP клиента, в пределах лимита IP
+)
+
+// разбор TrustedProxies, отдельный адрес считается подсетью из одного адреса
+func (rl RateLimit) TrustedPrefixes() ([]netip.Prefix, error) {
+	prefixes, err := parsePrefixes(rl.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный адрес прокси: %w", err)
+	}
+	return prefixes, nil
+}
+
+// разбор адресов и подсетей CIDR, отдельный адрес считается подсетью из одного адреса
+func parsePrefixes(list []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		if addr, err := netip.ParseAddr(s); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("%q", s)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func (rl RateLimit) validate() error {
+	switch rl.Mode {
+	case ModeEnabled:
+	case ModeDisabled, ModeDenyAll:
+		return rl.validateInactive()
+	default:
+		return fmt.Errorf("неизвестный режим %q, допустимы %s, %s и %s", rl.Mode, ModeEnabled, ModeDisabled, ModeDenyAll)
+	}
+	if rl.Limit <= 0 || rl.Interval <= 0 {
+		return fmt.Errorf("лимит %d за %v должен быть положительным, для отказа всем запросам используйте mode: %s, для отключения лимита - mode: %s",
+			rl.Limit, rl.Interval, ModeDenyAll, ModeDisabled)
+	}
+	if rl.Interval/time.Duration(rl.Limit) < minEmission {
+		return fmt.Errorf("лимит %d за %v больше одного запроса в %v", rl.Limit, rl.Interval, minEmission)
+	}
+	if rl.Burst < 0 {
+		return fmt.Errorf("отрицательный burst %d", rl.Burst)
+	}
+	if rl.QuotaFlushInterval <= 0 {
+		return fmt.Errorf("интервал записи квот %v должен быть положительным", rl.QuotaFlushInterval)
+	}
+	if err := rl.validatePlans(); err != nil {
+		return err
+	}
+	for _, key := range rl.KeyBy {
+		header, isHeader := strings.CutPrefix(key, KeyByHeaderPrefix)
+		if key != KeyByIP && key != KeyByAPIKey && (!isHeader || header == "") {
+			return fmt.Errorf("неизвестная стратегия ключа %q", key)
+		}
+		if key == KeyByAPIKey && len(rl.KnownAPIKeys) == 0 && len(rl.APIKeys) == 0 {
+			return fmt.Errorf("для стратегии ключа %s не заданы known_api_keys или api_keys", KeyByAPIKey)
+		}
+	}
+	for _, key := range rl.KnownAPIKeys {
+		if key == "" {
+			return fmt.Errorf("пустой ключ в known_api_keys")
+		}
+	}
+	_, err := rl.TrustedPrefixes()
+	return err
+}
+
+// в режимах без подсчёта запросов параметры лимита ни на что не влияют, поэтому их задание - ошибка
+func (rl RateLimit) validateInactive() error {
+	if rl.Burst != 0 || rl.Redis.Address != "" || len(rl.Plans) > 0 || rl.DefaultPlan != "" ||
+		len(rl.APIKeys) > 0 || len(rl.KnownAPIKeys) > 0 || len(rl.RouteCosts) > 0 || rl.CalculationCost != (CalculationCost{}) {
+		return fmt.Errorf("в режиме %s не используются burst, redis, plans, default_plan, api_keys, known_api_keys, route_costs и calculation_cost", rl.Mode)
+	}
+	return nil
+}
+
+/*
+проверка тарифов и стоимостей маршрутов: самый дорогой запрос
+(маршрут с наибольшей стоимостью и наибольшим весом вычислений) должен укладываться в каждый лимит
+*/
+func (rl RateLimit) validatePlans() error {
+	if rl.PrecisionStep < 0 || rl.DigitsStep < 0 || rl.MaxExtra < 0 {
+		return fmt.Errorf("отрицательный параметр веса вычислений")
+	}
+	maxCost := 1
+	for route, cost := range rl.RouteCosts {
+		method, path, _ := strings.Cut(route, " ")
+		if method == "" || !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("маршрут %q должен иметь вид \"POST /v2/calculate\"", route)
+		}
+		if cost < 1 {
+			return fmt.Errorf("стоимость маршрута %q должна быть положительной", route)
+		}
+		maxCost = max(maxCost, cost)
+	}
+	maxCost += rl.MaxExtra
+	if len(rl.Plans) == 0 {
+		if rl.DefaultPlan != "" || len(rl.APIKeys) > 0 {
+			return fmt.Errorf("тарифы для default_plan и api_keys не заданы")
+		}
+		burst := rl.Burst
+		if burst == 0 {
+			burst = rl.Limit
+		}
+		if maxCost > burst {
+			return fmt.Errorf("наибольшая стоимость запроса %d больше burst %d", maxCost, burst)
+		}
+		return nil
+	}
+	if _, ok := rl.Plans[rl.DefaultPlan]; !ok {
+		return fmt.Errorf("тариф по умолчанию %q не задан", rl.DefaultPlan)
+	}
+	for name, plan := range rl.Plans {
+		for _, period := range []struct {
+			limit    int
+			interval time.Duration
+		}{{plan.PerSecond, time.Second}, {plan.PerMinute, time.Minute}, {plan.PerDay, 24 * time.Hour}} {
+			limit := period.limit
+			if limit < 0 {
+				return fmt.Errorf("отрицательный лимит тарифа %q", name)
+			}
+			if limit > 0 && period.interval/time.Duration(limit) < minEmission {
+				return fmt.Errorf("лимит %d за %v тарифа %q больше одного запроса в %v", limit, period.interval, name, minEmission)
+			}
+			if limit > 0 && limit < maxCost {
+				return fmt.Errorf("наибольшая стоимость запроса %d больше лимита %d тарифа %q", maxCost, limit, name)
+			}
+		}
+	}
+	for key, plan := range rl.APIKeys {
+		if key == "" {
+			return fmt.Errorf("пустой API ключ тарифа %q", plan)
+		}
+		// сам ключ в ошибку не выводится
+		if _, ok := rl.Plans[plan]; !ok {
+			return fmt.Errorf("тариф %q API ключа не задан", plan)
+		}
+	}
+	return nil
+}
+
+// ограничения вычислителя выражений
+// ограничения размера чисел для /v1/calculate и /v2/calculate
+type Calculation struct {
+	MaxExponent int32 `yaml:"max_exponent" env-default:"1000"`
+	MaxDigits   int   `yaml:"max_digits" env-default:"2000"`
+}
+
+type Evaluation struct {
+	MaxLength         int   `yaml:"max_length" env-default:"1024"`
+	MaxDepth          int   `yaml:"max_depth" env-default:"32"`
+	MaxOperations     int   `yaml:"max_operations" env-default:"1000"`
+	MaxPrecision      int32 `yaml:"max_precision" env-default:"1000"`
+	DivisionPrecision int32 `yaml:"division_precision" env-default:"32"`
+	MaxExponent       int32 `yaml:"max_exponent" env-default:"1000"`
+	MaxDigits         int   `yaml:"max_digits" env-default:"2000"`
+}
+
+/*
+Политика версии API: устаревшая версия продолжает работать,
+но её ответы содержат заголовки Deprecation, Sunset (если задан)
+и Link на версию-замену (если задана), а вызовы пишутся в лог.
+*/
+type APIVersion struct {
+	Deprecated bool      `yaml:"deprecated"`
+	Sunset     time.Time `yaml:"sunset"` // дата отключения версии в формате RFC 3339
+	Successor  string    `yaml:"successor"`
+}
+
+// языки ответов и логов
+type Localization struct {
+	DefaultLanguage string `yaml:"default_language" env-default:"ru"`
+	LogLanguage     string `yaml:"log_language" env-default:"ru"`
 }
 
 // загрузка конфигурации из файла
 func MustLoad(configPath string) *Config {
+	cfg, err := Load(configPath)
+	if err != nil {
+		log.Panic(err)
+	}
+	return cfg
+}
+
+// чтение и проверка файла конфигурации, в отличие от MustLoad возвращает ошибку
+func Load(configPath string) (*Config, error) {
 	if _, err := os.Stat(configPath); err != nil {
-		log.Panicf("Ошибка открытия файла конфигурации %s", err)
+		return nil, fmt.Errorf("ошибка открытия файла конфигурации: %w", err)
 	}
 	var cfg Config
 	err := cleanenv.ReadConfig(configPath, &cfg)
 	if err != nil {
-		log.Panicf("Ошибка чтения файла конфигурации %s", err)
+		return nil, fmt.Errorf("ошибка чтения файла конфигурации: %w", err)
+	}
+	if cfg.ShutdownDelay < 0 {
+		return nil, fmt.Errorf("отрицательный shutdown_delay")
+	}
+	if err := cfg.RateLimit.validate(); err != nil {
+		return nil, fmt.Errorf("ошибка в настройках лимита запросов: %w", err)
+	}
+	if err := cfg.Concurrency.validate(); err != nil {
+		return nil, fmt.Errorf("ошибка в настройках ограничения одновременных вычислений: %w", err)
+	}
+	if _, _, err := cfg.IPFilter.Prefixes(); err != nil {
+		return nil, fmt.Errorf("ошибка в списках адресов ip_filter: %w", err)
+	}
+	for _, lang := range []string{cfg.DefaultLanguage, cfg.LogLanguage} {
+		if !i18n.Supported(lang) {
+			return nil, fmt.Errorf("неподдерживаемый язык %q в файле конфигурации", lang)
+		}
 	}
-	return &cfg
+	return &cfg, nil
 }
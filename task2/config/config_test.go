@@ -1,6 +1,7 @@
 package config
 
 import (
+	"net/netip"
 	"os"
 	"testing"
 	"time"
@@ -30,10 +31,53 @@ http_server:
   address: "1.1.1.1:8080"
   timeout: "10s"
   idle_timeout: "120s"
+  shutdown_delay: "7s"
+  max_body_size: 2048
+  strict_decoding: true
+  ip_filter:
+    allow: ["10.0.0.0/8", "::1"]
+    deny: ["203.0.113.0/24"]
+  concurrency:
+    max_in_flight: 8
+    queue_size: 16
+    queue_timeout: "500ms"
+    retry_after: "2s"
   rate limit:
+    mode: "enabled"
     limit: 5
     interval: "2s" 
+    burst: 10
     msg: "Тест."
+    key_by: ["ip", "api_key"]
+    trusted_proxies: ["10.0.0.0/8", "192.168.1.1"]
+    api_key_header: "Authorization"
+    redis:
+      address: "redis:6379"
+      db: 2
+      prefix: "test:"
+      timeout: "50ms"
+    plans:
+      free: {per_second: 5, per_minute: 100, per_day: 1000}
+      internal: {}
+    default_plan: "free"
+    api_keys:
+      "secret": "internal"
+    route_costs:
+      "POST /v2/calculate": 3
+    quota_store: "/var/lib/floatservice/quotas.db"
+    quota_flush_interval: "10s"
+    calculation_cost:
+      precision_step: 50
+      digits_step: 0
+      max_extra: 2
+localization:
+  default_language: "en"
+  log_language: "en"
+api_versions:
+  v1:
+    deprecated: true
+    sunset: "2027-01-01T00:00:00Z"
+    successor: "/v2"
 `
 	name := CreateAndFillTemp(t, validConfigFileName, validConfig)
 	cfg := MustLoad(name)
@@ -41,9 +85,42 @@ http_server:
 	assert.Equal(t, "1.1.1.1:8080", cfg.Address)
 	assert.Equal(t, 10*time.Second, cfg.Timeout)
 	assert.Equal(t, 120*time.Second, cfg.IdleTimeout)
+	assert.Equal(t, 7*time.Second, cfg.ShutdownDelay)
+	assert.Equal(t, int64(2048), cfg.MaxBodySize)
+	assert.True(t, cfg.StrictDecoding)
+	assert.Equal(t, Concurrency{MaxInFlight: 8, QueueSize: 16, QueueTimeout: 500 * time.Millisecond, RetryAfter: 2 * time.Second}, cfg.Concurrency)
+	allow, deny, err := cfg.IPFilter.Prefixes()
+	assert.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("::1/128")}, allow)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24")}, deny)
+	assert.Equal(t, ModeEnabled, cfg.Mode)
 	assert.Equal(t, 5, cfg.Limit)
 	assert.Equal(t, 2*time.Second, cfg.Interval)
+	assert.Equal(t, 10, cfg.Burst)
+	assert.Equal(t, map[string]Plan{"free": {PerSecond: 5, PerMinute: 100, PerDay: 1000}, "internal": {}}, cfg.Plans)
+	assert.Equal(t, "free", cfg.DefaultPlan)
+	assert.Equal(t, map[string]string{"secret": "internal"}, cfg.APIKeys)
+	assert.Equal(t, 3, cfg.RouteCost("POST", "/v2/calculate"))
+	assert.Equal(t, CalculationCost{PrecisionStep: 50, MaxExtra: 2}, cfg.CalculationCost)
+	assert.Equal(t, 1, cfg.RouteCost("GET", "/v2/calculate"))
+	assert.Equal(t, "/var/lib/floatservice/quotas.db", cfg.QuotaStore)
+	assert.Equal(t, 10*time.Second, cfg.QuotaFlushInterval)
+	assert.Equal(t, RateLimitRedis{Address: "redis:6379", DB: 2, Prefix: "test:", Timeout: 50 * time.Millisecond, RetryInterval: 5 * time.Second}, cfg.Redis)
 	assert.Equal(t, "Тест.", cfg.Msg)
+	assert.Equal(t, []string{KeyByIP, KeyByAPIKey}, cfg.KeyBy)
+	assert.Equal(t, "Authorization", cfg.APIKeyHeader)
+	prefixes, err := cfg.TrustedPrefixes()
+	assert.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("192.168.1.1/32")}, prefixes)
+	assert.Equal(t, "en", cfg.DefaultLanguage)
+	assert.Equal(t, "en", cfg.LogLanguage)
+	assert.Equal(t, map[string]APIVersion{
+		"v1": {
+			Deprecated: true,
+			Sunset:     time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC),
+			Successor:  "/v2",
+		},
+	}, cfg.APIVersions)
 }
 
 // тест при отсуствии файла кофигурации
@@ -72,9 +149,40 @@ http_server:
 	assert.Equal(t, "1.1.1.1:8080", cfg.Address)
 	assert.Equal(t, 5*time.Second, cfg.Timeout)
 	assert.Equal(t, 60*time.Second, cfg.IdleTimeout)
+	assert.Equal(t, 3*time.Second, cfg.ComputeTimeout)
+	assert.Equal(t, 5*time.Second, cfg.ShutdownDelay)
+	assert.False(t, cfg.LegacyStatus)
+	assert.False(t, cfg.ProblemJSON)
+	assert.Equal(t, int64(1<<20), cfg.MaxBodySize)
+	assert.False(t, cfg.StrictDecoding)
+	assert.Equal(t, Concurrency{QueueTimeout: time.Second, RetryAfter: time.Second}, cfg.Concurrency)
+	assert.Equal(t, IPFilter{}, cfg.IPFilter)
+	assert.Equal(t, ModeEnabled, cfg.Mode)
 	assert.Equal(t, 100, cfg.Limit)
 	assert.Equal(t, 60*time.Second, cfg.Interval)
-	assert.Equal(t, "Слишком много запросов.", cfg.Msg)
+	assert.Equal(t, 0, cfg.Burst)
+	assert.Empty(t, cfg.Redis.Address)
+	assert.Equal(t, "floatservice:ratelimit:", cfg.Redis.Prefix)
+	assert.Empty(t, cfg.Plans)
+	assert.Equal(t, "quotas.db", cfg.QuotaStore)
+	assert.Equal(t, 5*time.Second, cfg.QuotaFlushInterval)
+	assert.Equal(t, CalculationCost{}, cfg.CalculationCost)
+	assert.Empty(t, cfg.Msg)
+	assert.Equal(t, []string{KeyByIP}, cfg.KeyBy)
+	assert.Empty(t, cfg.TrustedProxies)
+	assert.Equal(t, "X-API-Key", cfg.APIKeyHeader)
+	assert.Equal(t, int32(1000), cfg.Calculation.MaxExponent)
+	assert.Equal(t, 2000, cfg.Calculation.MaxDigits)
+	assert.Equal(t, 1024, cfg.Evaluation.MaxLength)
+	assert.Equal(t, 32, cfg.Evaluation.MaxDepth)
+	assert.Equal(t, 1000, cfg.Evaluation.MaxOperations)
+	assert.Equal(t, int32(1000), cfg.Evaluation.MaxPrecision)
+	assert.Equal(t, int32(32), cfg.Evaluation.DivisionPrecision)
+	assert.Equal(t, int32(1000), cfg.Evaluation.MaxExponent)
+	assert.Equal(t, 2000, cfg.Evaluation.MaxDigits)
+	assert.Equal(t, "ru", cfg.DefaultLanguage)
+	assert.Equal(t, "ru", cfg.LogLanguage)
+	assert.Empty(t, cfg.APIVersions)
 }
 
 func TestMustLoad_InvalidConfigFile_AbsenceOfEnv(t *testing.T) {
@@ -98,3 +206,322 @@ http_server:
 	name := CreateAndFillTemp(t, invalidConfigFileName, invalidConfig)
 	assert.Panics(t, func() { _ = MustLoad(name) })
 }
+
+func TestMustLoad_InvalidConfigFile_UnsupportedLanguage(t *testing.T) {
+	const invalidConfigFileName = "invalid_config*.yml"
+	const invalidConfig = `env: "dev"
+http_server:
+  address: "1.1.1.1:8080"
+localization:
+  default_language: "de"
+`
+	name := CreateAndFillTemp(t, invalidConfigFileName, invalidConfig)
+	assert.Panics(t, func() { _ = MustLoad(name) })
+}
+
+func TestMustLoad_InvalidConfigFile_RateLimitKeys(t *testing.T) {
+	cases := []struct {
+		name      string
+		rateLimit string
+	}{
+		{
+			name:      "Неизвестная стратегия ключа",
+			rateLimit: `key_by: ["cookie"]`,
+		},
+		{
+			name:      "Заголовок без имени",
+			rateLimit: `key_by: ["header:"]`,
+		},
+		{
+			name:      "Стратегия api_key без известных ключей",
+			rateLimit: `key_by: ["api_key"]`,
+		},
+		{
+			name:      "Пустой известный API ключ",
+			rateLimit: "key_by: [\"api_key\"]\n    known_api_keys: [\"\"]",
+		},
+		{
+			name:      "Известные API ключи при отключённом лимите",
+			rateLimit: "mode: \"disabled\"\n    known_api_keys: [\"secret\"]",
+		},
+		{
+			name:      "Отрицательный лимит",
+			rateLimit: `limit: -1`,
+		},
+		{
+			name:      "Отрицательный интервал",
+			rateLimit: `interval: "-1s"`,
+		},
+		{
+			name:      "Лимит чаще одного запроса в микросекунду",
+			rateLimit: "limit: 2000000\n    interval: \"1s\"",
+		},
+		{
+			name:      "Лимит тарифа чаще одного запроса в микросекунду",
+			rateLimit: "plans: {free: {per_second: 2000000}}\n    default_plan: \"free\"",
+		},
+		{
+			name:      "Неизвестный режим",
+			rateLimit: `mode: "off"`,
+		},
+		{
+			name:      "Тарифы при отключённом лимите",
+			rateLimit: "mode: \"disabled\"\n    plans: {free: {per_second: 20}}\n    default_plan: \"free\"",
+		},
+		{
+			name:      "Redis при отключённом лимите",
+			rateLimit: "mode: \"disabled\"\n    redis: {address: \"redis:6379\"}",
+		},
+		{
+			name:      "Burst при отказе всем запросам",
+			rateLimit: "mode: \"deny_all\"\n    burst: 10",
+		},
+		{
+			name:      "Стоимость маршрута при отказе всем запросам",
+			rateLimit: "mode: \"deny_all\"\n    route_costs: {\"POST /v2/calculate\": 2}",
+		},
+		{
+			name:      "Отрицательный интервал записи квот",
+			rateLimit: `quota_flush_interval: "-1s"`,
+		},
+		{
+			name:      "Отрицательный burst",
+			rateLimit: `burst: -1`,
+		},
+		{
+			name:      "Тариф по умолчанию без тарифов",
+			rateLimit: `default_plan: "free"`,
+		},
+		{
+			name:      "Неизвестный тариф по умолчанию",
+			rateLimit: "plans: {free: {per_second: 20}}\n    default_plan: \"standard\"",
+		},
+		{
+			name:      "Неизвестный тариф API ключа",
+			rateLimit: "plans: {free: {per_second: 20}}\n    default_plan: \"free\"\n    api_keys: {\"secret\": \"standard\"}",
+		},
+		{
+			name:      "Отрицательный лимит тарифа",
+			rateLimit: "plans: {free: {per_day: -1}}\n    default_plan: \"free\"",
+		},
+		{
+			name:      "Маршрут без метода",
+			rateLimit: `route_costs: {"/v2/calculate": 2}`,
+		},
+		{
+			name:      "Нулевая стоимость маршрута",
+			rateLimit: `route_costs: {"POST /v2/calculate": 0}`,
+		},
+		{
+			name:      "Стоимость маршрута больше burst",
+			rateLimit: `route_costs: {"POST /v2/calculate": 101}`,
+		},
+		{
+			name:      "Вес вычислений больше burst",
+			rateLimit: "limit: 5\n    calculation_cost: {max_extra: 5}",
+		},
+		{
+			name:      "Отрицательный шаг веса вычислений",
+			rateLimit: "calculation_cost: {precision_step: -1, max_extra: 1}",
+		},
+		{
+			name:      "Стоимость маршрута больше лимита тарифа",
+			rateLimit: "plans: {free: {per_second: 20, per_minute: 100}}\n    default_plan: \"free\"\n    route_costs: {\"POST /v2/calculate\": 21}",
+		},
+		{
+			name:      "Некорректная подсеть прокси",
+			rateLimit: `trusted_proxies: ["10.0.0.0/33"]`,
+		},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			const invalidConfigFileName = "invalid_config*.yml"
+			invalidConfig := `env: "dev"
+http_server:
+  address: "1.1.1.1:8080"
+  rate limit:
+    ` + test_case.rateLimit + "\n"
+			name := CreateAndFillTemp(t, invalidConfigFileName, invalidConfig)
+			assert.Panics(t, func() { _ = MustLoad(name) })
+		})
+	}
+}
+
+func TestMustLoad_RateLimitModes(t *testing.T) {
+	cases := []struct {
+		name      string
+		rateLimit string
+		mode      string
+	}{
+		{
+			name:      "Лимит включён",
+			rateLimit: `mode: "enabled"`,
+			mode:      ModeEnabled,
+		},
+		{
+			name:      "Лимит отключён",
+			rateLimit: `mode: "disabled"`,
+			mode:      ModeDisabled,
+		},
+		{
+			name:      "Отказ всем запросам с сообщением",
+			rateLimit: "mode: \"deny_all\"\n    msg: \"Сервис на обслуживании.\"",
+			mode:      ModeDenyAll,
+		},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			const configFileName = "config*.yml"
+			config := `env: "dev"
+http_server:
+  address: "1.1.1.1:8080"
+  rate limit:
+    ` + test_case.rateLimit + "\n"
+			name := CreateAndFillTemp(t, configFileName, config)
+			cfg := MustLoad(name)
+			assert.Equal(t, test_case.mode, cfg.Mode)
+		})
+	}
+}
+
+func TestLoad_InvalidIPFilter(t *testing.T) {
+	cases := []struct {
+		name     string
+		ipFilter string
+	}{
+		{
+			name:     "Некорректная подсеть в allow",
+			ipFilter: `allow: ["10.0.0.0/33"]`,
+		},
+		{
+			name:     "Некорректный адрес в deny",
+			ipFilter: `deny: ["example.com"]`,
+		},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			const invalidConfigFileName = "invalid_config*.yml"
+			invalidConfig := `env: "dev"
+http_server:
+  address: "1.1.1.1:8080"
+  ip_filter:
+    ` + test_case.ipFilter + "\n"
+			name := CreateAndFillTemp(t, invalidConfigFileName, invalidConfig)
+			_, err := Load(name)
+			assert.ErrorContains(t, err, "ip_filter")
+			assert.Panics(t, func() { _ = MustLoad(name) })
+		})
+	}
+}
+
+func TestMustLoad_InvalidConfigFile_Concurrency(t *testing.T) {
+	cases := []struct {
+		name        string
+		concurrency string
+	}{
+		{
+			name:        "Отрицательный max_in_flight",
+			concurrency: `max_in_flight: -1`,
+		},
+		{
+			name:        "Отрицательный queue_size",
+			concurrency: `queue_size: -1`,
+		},
+		{
+			name:        "Отрицательное время ожидания в очереди",
+			concurrency: `queue_timeout: "-1s"`,
+		},
+		{
+			name:        "Отрицательный retry_after",
+			concurrency: `retry_after: "-1s"`,
+		},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			const invalidConfigFileName = "invalid_config*.yml"
+			invalidConfig := `env: "dev"
+http_server:
+  address: "1.1.1.1:8080"
+  concurrency:
+    ` + test_case.concurrency + "\n"
+			name := CreateAndFillTemp(t, invalidConfigFileName, invalidConfig)
+			assert.Panics(t, func() { _ = MustLoad(name) })
+		})
+	}
+}
+
+func TestMustLoad_InvalidConfigFile_ShutdownDelay(t *testing.T) {
+	const invalidConfigFileName = "invalid_config*.yml"
+	const invalidConfig = `env: "dev"
+http_server:
+  address: "1.1.1.1:8080"
+  shutdown_delay: "-1s"
+`
+	name := CreateAndFillTemp(t, invalidConfigFileName, invalidConfig)
+	assert.Panics(t, func() { _ = MustLoad(name) })
+}
+
+func TestCalculationCost_Extra(t *testing.T) {
+	cost := CalculationCost{PrecisionStep: 100, DigitsStep: 1000, MaxExtra: 10}
+	cases := []struct {
+		name      string
+		cost      CalculationCost
+		precision int32
+		digits    int
+		extra     int
+	}{
+		{
+			name:      "Обычный запрос",
+			cost:      cost,
+			precision: 5,
+			digits:    12,
+		},
+		{
+			name:      "Высокая точность",
+			cost:      cost,
+			precision: 250,
+			digits:    12,
+			extra:     2,
+		},
+		{
+			name:      "Отрицательная точность не учитывается",
+			cost:      cost,
+			precision: -1000,
+		},
+		{
+			name:      "Точность и размер операндов",
+			cost:      cost,
+			precision: 100,
+			digits:    2500,
+			extra:     3,
+		},
+		{
+			name:      "Не больше max_extra",
+			cost:      cost,
+			precision: 1000,
+			digits:    5000,
+			extra:     10,
+		},
+		{
+			name:      "Нулевые шаги",
+			cost:      CalculationCost{MaxExtra: 10},
+			precision: 1000,
+			digits:    5000,
+		},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test_case.extra, test_case.cost.Extra(test_case.precision, test_case.digits))
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package expression
+
+import (
+	"FloatService/i18n"
+	"math"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrDivisionByZero    = i18n.NewError(i18n.DivisionByZero)
+	ErrTooDeep           = i18n.NewError(i18n.TooDeep)
+	ErrTooManyOperations = i18n.NewError(i18n.TooManyOperations)
+	ErrTooLong           = i18n.NewError(i18n.TooLong)
+	ErrNumberTooLarge    = i18n.NewError(i18n.NumberTooLarge)
+	// сравнивается через errors.Is без учёта имени переменной
+	ErrUnknownVariable = i18n.NewError(i18n.UnknownVariable, "")
+)
+
+/*
+Ошибка разбора выражения с позицией символа, в которой она обнаружена.
+Описание берётся из Detail, если оно задано, иначе из Msg.
+*/
+type SyntaxError struct {
+	Pos    int
+	Msg    string
+	Detail *i18n.Error
+}
+
+func (e *SyntaxError) Error() string {
+	return e.Localize(i18n.Fallback)
+}
+
+func (e *SyntaxError) Localize(lang string) string {
+	msg := e.Msg
+	if e.Detail != nil {
+		msg = e.Detail.Localize(lang)
+	}
+	return i18n.T(lang, i18n.SyntaxError, e.Pos, msg)
+}
+
+func syntaxError(pos int, key i18n.Key, args ...any) error {
+	detail := i18n.NewError(key, args...)
+	return &SyntaxError{Pos: pos, Msg: detail.Error(), Detail: detail}
+}
+
+/*
+Вычислитель выражений над decimal.
+Поддерживаются операции +, -, *, /, унарный минус, скобки,
+именованные переменные и функции round(x, n), abs(x), min(...), max(...).
+Размер чисел (литералов, переменных и результатов операций) ограничен
+показателем степени и количеством цифр, операция, результат которой превысил бы
+ограничение, не выполняется.
+Нулевое значение ограничения означает его отсутствие.
+*/
+type Evaluator struct {
+	MaxLength         int   // максимальная длина выражения в символах
+	MaxDepth          int   // максимальная глубина вложенности
+	MaxOperations     int   // максимальное количество операций при вычислении
+	MaxPrecision      int32 // максимальная по модулю точность в round
+	DivisionPrecision int32 // количество знаков после точки в результате деления
+	MaxExponent       int32 // максимальный по модулю показатель степени числа
+	MaxDigits         int   // максимальное количество цифр числа
+}
+
+// вычисление выражения expr с переменными vars
+func (e *Evaluator) Evaluate(expr string, vars map[string]decimal.Decimal) (decimal.Decimal, error) {
+	if e.MaxLength > 0 && len([]rune(expr)) > e.MaxLength {
+		return decimal.Zero, ErrTooLong
+	}
+	root, err := parse(expr, e.MaxDepth)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	s := &state{evaluator: e, vars: vars}
+	return root.eval(s)
+}
+
+// состояние одного вычисления
+type state struct {
+	evaluator  *Evaluator
+	vars       map[string]decimal.Decimal
+	operations int
+}
+
+func (s *state) count() error {
+	s.operations++
+	if s.evaluator.MaxOperations > 0 && s.operations > s.evaluator.MaxOperations {
+		return ErrTooManyOperations
+	}
+	return nil
+}
+
+/*
+Проверка размера числа c показателем степени exp и количеством цифр digits.
+Показатель степени вне int32 не допускается и без ограничений, так как decimal его не поддерживает.
+*/
+func (s *state) checkSize(exp, digits int64) error {
+	if exp > math.MaxInt32 || exp < math.MinInt32 {
+		return ErrNumberTooLarge
+	}
+	e := s.evaluator
+	if e.MaxExponent > 0 && (exp > int64(e.MaxExponent) || exp < -int64(e.MaxExponent)) {
+		return ErrNumberTooLarge
+	}
+	if e.MaxDigits > 0 && digits > int64(e.MaxDigits) {
+		return ErrNumberTooLarge
+	}
+	return nil
+}
+
+func (s *state) check(value decimal.Decimal) error {
+	return s.checkSize(int64(value.Exponent()), int64(value.NumDigits()))
+}
+
+// разряд, следующий за старшей цифрой числа
+func top(value decimal.Decimal) int64 {
+	return int64(value.NumDigits()) + int64(value.Exponent())
+}
+
+/*
+Проверка размера результата операции op до её выполнения:
+показатель степени и количество цифр оцениваются сверху по операндам.
+*/
+func (s *state) checkBinary(op string, left, right decimal.Decimal) error {
+	switch op {
+	case "+", "-":
+		exp := min(int64(left.Exponent()), int64(right.Exponent()))
+		return s.checkSize(exp, max(top(left), top(right))-exp+1)
+	case "*":
+		return s.checkSize(int64(left.Exponent())+int64(right.Exponent()), int64(left.NumDigits())+int64(right.NumDigits()))
+	case "/":
+		precision := int64(s.evaluator.DivisionPrecision)
+		return s.checkSize(-precision, top(left)-top(right)+precision+1)
+	}
+	return nil
+}
+
+type node interface {
+	eval(s *state) (decimal.Decimal, error)
+}
+
+type numberNode struct {
+	value decimal.Decimal
+}
+
+func (n *numberNode) eval(s *state) (decimal.Decimal, error) {
+	if err := s.check(n.value); err != nil {
+		return decimal.Zero, err
+	}
+	return n.value, nil
+}
+
+type variableNode struct {
+	name string
+}
+
+func (n *variableNode) eval(s *state) (decimal.Decimal, error) {
+	value, ok := s.vars[n.name]
+	if !ok {
+		return decimal.Zero, i18n.NewError(i18n.UnknownVariable, n.name)
+	}
+	if err := s.check(value); err != nil {
+		return decimal.Zero, err
+	}
+	return value, nil
+}
+
+type negNode struct {
+	operand node
+}
+
+func (n *negNode) eval(s *state) (decimal.Decimal, error) {
+	if err := s.count(); err != nil {
+		return decimal.Zero, err
+	}
+	value, err := n.operand.eval(s)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return value.Neg(), nil
+}
+
+type binaryNode struct {
+	op          string
+	left, right node
+}
+
+func (n *binaryNode) eval(s *state) (decimal.Decimal, error) {
+	if err := s.count(); err != nil {
+		return decimal.Zero, err
+	}
+	left, err := n.left.eval(s)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	right, err := n.right.eval(s)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if n.op == "/" && right.IsZero() {
+		return decimal.Zero, ErrDivisionByZero
+	}
+	if err := s.checkBinary(n.op, left, right); err != nil {
+		return decimal.Zero, err
+	}
+	switch n.op {
+	case "+":
+		return left.Add(right), nil
+	case "-":
+		return left.Sub(right), nil
+	case "*":
+		return left.Mul(right), nil
+	case "/":
+		return left.DivRound(right, s.evaluator.DivisionPrecision), nil
+	}
+	return decimal.Zero, i18n.NewError(i18n.UnknownOperation, n.op)
+}
+
+type callNode struct {
+	name string
+	args []node
+	pos  int
+}
+
+func (n *callNode) eval(s *state) (decimal.Decimal, error) {
+	if err := s.count(); err != nil {
+		return decimal.Zero, err
+	}
+	args := make([]decimal.Decimal, 0, len(n.args))
+	for _, arg := range n.args {
+		value, err := arg.eval(s)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		args = append(args, value)
+	}
+	switch n.name {
+	case "round":
+		if len(args) != 2 {
+			return decimal.Zero, syntaxError(n.pos, i18n.TakesTwoArgs, n.name)
+		}
+		if !args[1].IsInteger() {
+			return decimal.Zero, i18n.NewError(i18n.RoundNotInteger)
+		}
+		max := decimal.NewFromInt32(s.evaluator.MaxPrecision)
+		if s.evaluator.MaxPrecision > 0 && args[1].Abs().GreaterThan(max) {
+			return decimal.Zero, i18n.NewError(i18n.RoundTooPrecise, s.evaluator.MaxPrecision)
+		}
+		if !args[1].Abs().LessThan(decimal.NewFromInt32(1 << 30)) {
+			return decimal.Zero, i18n.NewError(i18n.RoundOutOfRange)
+		}
+		places := args[1].IntPart()
+		// округление до большего количества знаков дописывает нули
+		if -places < int64(args[0].Exponent()) {
+			if err := s.checkSize(-places, top(args[0])+places); err != nil {
+				return decimal.Zero, err
+			}
+		}
+		return args[0].Round(int32(places)), nil
+	case "abs":
+		if len(args) != 1 {
+			return decimal.Zero, syntaxError(n.pos, i18n.TakesOneArg, n.name)
+		}
+		return args[0].Abs(), nil
+	case "min", "max":
+		if len(args) == 0 {
+			return decimal.Zero, syntaxError(n.pos, i18n.MinArgCount, n.name)
+		}
+		if n.name == "min" {
+			return decimal.Min(args[0], args[1:]...), nil
+		}
+		return decimal.Max(args[0], args[1:]...), nil
+	}
+	return decimal.Zero, syntaxError(n.pos, i18n.UnknownFunction, n.name)
+}
@@ -0,0 +1,209 @@
+package expression
+
+import (
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func DecimalFromString(str string) decimal.Decimal {
+	num, err := decimal.NewFromString(str)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	return num
+}
+
+func TestEvaluate(t *testing.T) {
+	evaluator := Evaluator{
+		MaxLength:         200,
+		MaxDepth:          10,
+		MaxOperations:     20,
+		MaxPrecision:      100,
+		DivisionPrecision: 16,
+		MaxExponent:       100,
+		MaxDigits:         200,
+	}
+	vars := map[string]decimal.Decimal{
+		"a": DecimalFromString("1"),
+		"b": DecimalFromString("2"),
+		"c": DecimalFromString("3"),
+		"d": DecimalFromString("1.234567"),
+		"e": DecimalFromString("1e101"),
+	}
+
+	cases := []struct {
+		name   string
+		expr   string
+		result decimal.Decimal
+		Err    string
+	}{
+		{
+			name:   "Формула из запроса",
+			expr:   "a / b * c + round(d, 4)",
+			result: DecimalFromString("2.7346"),
+		},
+		{
+			name:   "Приоритет операций",
+			expr:   "1 + 2 * 3 - 4 / 2",
+			result: DecimalFromString("5"),
+		},
+		{
+			name:   "Скобки",
+			expr:   "(1 + 2) * 3",
+			result: DecimalFromString("9"),
+		},
+		{
+			name:   "Унарный минус",
+			expr:   "-a - -b",
+			result: DecimalFromString("1"),
+		},
+		{
+			name:   "Экспоненциальная запись",
+			expr:   "1.5e3 + 2E-1",
+			result: DecimalFromString("1500.2"),
+		},
+		{
+			name:   "Деление с точностью",
+			expr:   "1 / 3",
+			result: DecimalFromString("0.3333333333333333"),
+		},
+		{
+			name:   "Функции abs, min, max",
+			expr:   "abs(-c) + min(a, b, c) + max(a, b)",
+			result: DecimalFromString("6"),
+		},
+		{
+			name:   "Отрицательная точность в round",
+			expr:   "round(1234, -2)",
+			result: DecimalFromString("1200"),
+		},
+		{
+			name: "Деление на нуль",
+			expr: "a / (b - 2)",
+			Err:  "деление на нуль",
+		},
+		{
+			name: "Неизвестная переменная",
+			expr: "a + z",
+			Err:  `неизвестная переменная "z"`,
+		},
+		{
+			name: "Неизвестная функция",
+			expr: "sqrt(a)",
+			Err:  `синтаксическая ошибка в позиции 0: неизвестная функция "sqrt"`,
+		},
+		{
+			name: "Неверное количество аргументов",
+			expr: "round(a)",
+			Err:  "синтаксическая ошибка в позиции 0: round принимает 2 аргумента",
+		},
+		{
+			name: "Дробная точность в round",
+			expr: "round(d, 1.5)",
+			Err:  "точность в round должна быть целым числом",
+		},
+		{
+			name: "Слишком большая точность в round",
+			expr: "round(d, 101)",
+			Err:  "точность в round должна быть не больше 100 по модулю",
+		},
+		{
+			name: "Незакрытая скобка",
+			expr: "(a + b",
+			Err:  `синтаксическая ошибка в позиции 6: ожидалась ")"`,
+		},
+		{
+			name: "Лишняя лексема",
+			expr: "a b",
+			Err:  `синтаксическая ошибка в позиции 2: неожиданная лексема "b"`,
+		},
+		{
+			name: "Недопустимый символ",
+			expr: "a ^ b",
+			Err:  `синтаксическая ошибка в позиции 2: неожиданный символ '^'`,
+		},
+		{
+			name: "Пустое выражение",
+			expr: "",
+			Err:  "синтаксическая ошибка в позиции 0: неожиданный конец выражения",
+		},
+		{
+			name: "Превышена глубина вложенности",
+			expr: strings.Repeat("(", 11) + "a" + strings.Repeat(")", 11),
+			Err:  ErrTooDeep.Error(),
+		},
+		{
+			name: "Превышена глубина вложенности унарными минусами",
+			expr: strings.Repeat("-", 11) + "a",
+			Err:  ErrTooDeep.Error(),
+		},
+		{
+			name: "Превышено количество операций",
+			expr: "a" + strings.Repeat("+a", 21),
+			Err:  ErrTooManyOperations.Error(),
+		},
+		{
+			name:   "Большие числа в пределах ограничений",
+			expr:   "1e50 * 1e49 + 1e-50",
+			result: DecimalFromString("1e99").Add(DecimalFromString("1e-50")),
+		},
+		{
+			name: "Переполнение показателя степени при умножении",
+			expr: "1e2000000000 * 1e2000000000",
+			Err:  ErrNumberTooLarge.Error(),
+		},
+		{
+			name: "Сложение с огромным показателем степени",
+			expr: "1e3000000 + 1",
+			Err:  ErrNumberTooLarge.Error(),
+		},
+		{
+			name: "Слишком большой результат умножения",
+			expr: "1e90 * 1e90",
+			Err:  ErrNumberTooLarge.Error(),
+		},
+		{
+			name: "Слишком много цифр в результате сложения",
+			expr: "1e100 + 1e-100",
+			Err:  ErrNumberTooLarge.Error(),
+		},
+		{
+			name: "Слишком большой результат деления",
+			expr: "1e100 / 1e-100",
+			Err:  ErrNumberTooLarge.Error(),
+		},
+		{
+			name: "Слишком много знаков в round",
+			expr: "round(1e100, 100)",
+			Err:  ErrNumberTooLarge.Error(),
+		},
+		{
+			name: "Слишком большая переменная",
+			expr: "e + a",
+			Err:  ErrNumberTooLarge.Error(),
+		},
+		{
+			name: "Превышена длина выражения",
+			expr: strings.Repeat(" ", 201),
+			Err:  ErrTooLong.Error(),
+		},
+	}
+
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := evaluator.Evaluate(test_case.expr, vars)
+			if test_case.Err == "" {
+				assert.NoError(t, err)
+				assert.True(t, test_case.result.Equal(result), "ожидаемый результат %v, полученный %v", test_case.result, result)
+			} else {
+				assert.EqualError(t, err, test_case.Err)
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package expression
+
+import (
+	"FloatService/i18n"
+	"unicode"
+
+	"github.com/shopspring/decimal"
+)
+
+/*
+Грамматика выражений:
+-	expr    = term { ("+" | "-") term }
+-	term    = unary { ("*" | "/") unary }
+-	unary   = ("+" | "-") unary | primary
+-	primary = number | ident | ident "(" [ expr { "," expr } ] ")" | "(" expr ")"
+*/
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenIdent
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	pos   int
+	value decimal.Decimal
+}
+
+// разбиение выражения на лексемы
+func tokenize(src string) ([]token, error) {
+	runes := []rune(src)
+	var tokens []token
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '+' || r == '-' || r == '*' || r == '/':
+			tokens = append(tokens, token{kind: tokenOperator, text: string(r), pos: i})
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: i})
+			i++
+		case r == ',':
+			tokens = append(tokens, token{kind: tokenComma, text: ",", pos: i})
+			i++
+		case isDigit(r) || r == '.':
+			start := i
+			for i < len(runes) && (isDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			// экспонента: 1e10, 1.5E-3
+			if i < len(runes) && (runes[i] == 'e' || runes[i] == 'E') {
+				j := i + 1
+				if j < len(runes) && (runes[j] == '+' || runes[j] == '-') {
+					j++
+				}
+				if j < len(runes) && isDigit(runes[j]) {
+					for j < len(runes) && isDigit(runes[j]) {
+						j++
+					}
+					i = j
+				}
+			}
+			text := string(runes[start:i])
+			value, err := decimal.NewFromString(text)
+			if err != nil {
+				return nil, syntaxError(start, i18n.InvalidNumber, text)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: text, pos: start, value: value})
+		case isIdentStart(r):
+			start := i
+			for i < len(runes) && (isIdentStart(runes[i]) || isDigit(runes[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: string(runes[start:i]), pos: start})
+		default:
+			return nil, syntaxError(i, i18n.UnexpectedChar, r)
+		}
+	}
+	tokens = append(tokens, token{kind: tokenEOF, pos: len(runes)})
+	return tokens, nil
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+type parser struct {
+	tokens   []token
+	pos      int
+	depth    int
+	maxDepth int
+}
+
+// построение дерева выражения
+func parse(src string, maxDepth int) (node, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, maxDepth: maxDepth}
+	n, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, syntaxError(tok.pos, i18n.UnexpectedToken, tok.text)
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// каждый уровень рекурсии спуска учитывается в ограничении глубины
+func (p *parser) enter() error {
+	p.depth++
+	if p.maxDepth > 0 && p.depth > p.maxDepth {
+		return ErrTooDeep
+	}
+	return nil
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+func (p *parser) expr() (node, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if tok.kind != tokenOperator || (tok.text != "+" && tok.text != "-") {
+			return left, nil
+		}
+		p.next()
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: tok.text, left: left, right: right}
+	}
+}
+
+func (p *parser) term() (node, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if tok.kind != tokenOperator || (tok.text != "*" && tok.text != "/") {
+			return left, nil
+		}
+		p.next()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: tok.text, left: left, right: right}
+	}
+}
+
+func (p *parser) unary() (node, error) {
+	tok := p.peek()
+	if tok.kind == tokenOperator && (tok.text == "+" || tok.text == "-") {
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+		p.next()
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if tok.text == "+" {
+			return operand, nil
+		}
+		return &negNode{operand: operand}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenNumber:
+		return &numberNode{value: tok.value}, nil
+	case tokenIdent:
+		if p.peek().kind != tokenLParen {
+			return &variableNode{name: tok.text}, nil
+		}
+		p.next()
+		var args []node
+		if p.peek().kind != tokenRParen {
+			for {
+				arg, err := p.expr()
+				if err != nil {
+					return nil, err
+				}
+				args = append(args, arg)
+				if p.peek().kind != tokenComma {
+					break
+				}
+				p.next()
+			}
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, syntaxError(closing.pos, i18n.ExpectedRParen)
+		}
+		return &callNode{name: tok.text, args: args, pos: tok.pos}, nil
+	case tokenLParen:
+		n, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, syntaxError(closing.pos, i18n.ExpectedRParen)
+		}
+		return n, nil
+	case tokenEOF:
+		return nil, syntaxError(tok.pos, i18n.UnexpectedEnd)
+	default:
+		return nil, syntaxError(tok.pos, i18n.UnexpectedToken, tok.text)
+	}
+}
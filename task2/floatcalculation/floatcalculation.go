@@ -1,12 +1,68 @@
 package floatcalculation
 
 import (
+	"FloatService/i18n"
+	"context"
 	"errors"
+	"math"
+	"math/big"
 
 	"github.com/shopspring/decimal"
 )
 
-type FloatCalculator struct{}
+var (
+	ErrDivisionByZero       = i18n.NewError(i18n.DivisionByZero)
+	ErrNonPositivePrecision = i18n.NewError(i18n.NonPositivePrecision)
+	ErrNumberTooLarge       = i18n.NewError(i18n.NumberTooLarge)
+	ErrCalculationFailed    = i18n.NewError(i18n.CalculationFailed)
+)
+
+// способ интерпретации точности E
+type PrecisionMode string
+
+const (
+	PrecisionPlaces      PrecisionMode = "places"      // E знаков после точки
+	PrecisionSignificant PrecisionMode = "significant" // E значащих цифр
+)
+
+/*
+Вычислитель значений операндов.
+Размер чисел (множителей, делителей, их произведений и частного) ограничен
+показателем степени и количеством цифр, вычисление, результат которого превысил бы
+ограничение, не выполняется: операции decimal и big нельзя прервать,
+поэтому только ограничение размера чисел ограничивает время их работы.
+Нулевое значение ограничения означает его отсутствие.
+*/
+type FloatCalculator struct {
+	MaxExponent int32 // максимальный по модулю показатель степени числа
+	MaxDigits   int   // максимальное количество цифр числа
+}
+
+// значение вида (F1 * F2 * ...) / (D1 * D2 * ...)
+type Operand struct {
+	Factors  []decimal.Decimal
+	Divisors []decimal.Decimal
+}
+
+/*
+Размер операндов: сумма количества цифр всех множителей и делителей
+при записи без экспоненты (без нуля перед точкой), по нему оценивается стоимость вычислений.
+*/
+func Digits(operands []Operand) int {
+	digits := 0
+	for _, operand := range operands {
+		for _, values := range [][]decimal.Decimal{operand.Factors, operand.Divisors} {
+			for _, value := range values {
+				if exp := int(value.Exponent()); exp >= 0 {
+					digits += value.NumDigits() + exp
+				} else {
+					digits += max(value.NumDigits(), -exp)
+				}
+			}
+		}
+	}
+	return digits
+}
 
 /*
 Вычисления параметров:
@@ -23,19 +79,297 @@ func (c *FloatCalculator) FloatCalculation(
 	IsEqual string,
 	err error,
 ) {
-	if X2.IsZero() || Y2.IsZero() {
-		X, Y, IsEqual, err = FloatCalculationError("деление на нуль")
-		return
-	}
-	err = nil
-	X = X1.Mul(X3).DivRound(X2, E)
-	Y = Y1.Mul(Y3).DivRound(Y2, E)
-	if X.Equal(Y) {
-		IsEqual = "T"
-	} else {
-		IsEqual = "F"
+	values, groups, err := c.Calculate(context.Background(), []Operand{
+		{Factors: []decimal.Decimal{X1, X3}, Divisors: []decimal.Decimal{X2}},
+		{Factors: []decimal.Decimal{Y1, Y3}, Divisors: []decimal.Decimal{Y2}},
+	}, E, PrecisionPlaces)
+	if err != nil {
+		return FloatCalculationError(err.Error())
 	}
-	return
+	return values[0], values[1], IsEqualString(groups), nil
+}
+
+/*
+Обобщение FloatCalculation на произвольное количество значений,
+множителей и делителей:
+-	values[i] = произведение множителей / произведение делителей (с точностью E);
+-	groups - группы индексов равных между собой значений в порядке их первого появления.
+В режиме PrecisionPlaces E - количество знаков после точки,
+в режиме PrecisionSignificant - количество значащих цифр.
+Равенство проверяется для уже округлённых значений.
+При отмене ctx возвращается ctx.Err(), не дожидаясь окончания вычисления значения (см. await).
+Если множитель, делитель, их произведение или частное превысили бы ограничения размера,
+возвращается ErrNumberTooLarge и вычисление не выполняется.
+*/
+func (c *FloatCalculator) Calculate(ctx context.Context, operands []Operand, E int32, mode PrecisionMode) (
+	values []decimal.Decimal,
+	groups [][]int,
+	err error,
+) {
+	if mode == PrecisionSignificant && E <= 0 {
+		return nil, nil, ErrNonPositivePrecision
+	}
+	if err := c.Check(operands); err != nil {
+		return nil, nil, err
+	}
+	values = make([]decimal.Decimal, 0, len(operands))
+	for _, operand := range operands {
+		value, err := await(ctx, func() (decimal.Decimal, error) {
+			return c.calculateValue(operand, E, mode)
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		values = append(values, value)
+	}
+	return values, EqualityGroups(values), nil
+}
+
+/*
+Проверка операндов до вычислений: делители не равны нулю,
+а размер каждого множителя и делителя не превышает ограничений.
+*/
+func (c *FloatCalculator) Check(operands []Operand) error {
+	for _, operand := range operands {
+		for _, divisor := range operand.Divisors {
+			if divisor.IsZero() {
+				return ErrDivisionByZero
+			}
+		}
+		for _, values := range [][]decimal.Decimal{operand.Factors, operand.Divisors} {
+			for _, value := range values {
+				if err := c.checkSize(int64(value.Exponent()), int64(value.NumDigits())); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// значение операнда с точностью E
+func (c *FloatCalculator) calculateValue(operand Operand, E int32, mode PrecisionMode) (decimal.Decimal, error) {
+	numerator, err := c.product(operand.Factors)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	denominator, err := c.product(operand.Divisors)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	places := int64(E)
+	if mode == PrecisionSignificant {
+		if numerator.IsZero() {
+			return decimal.Zero, nil
+		}
+		places = int64(E) - 1 - magnitude(numerator, denominator)
+	}
+	if err := c.checkQuotient(numerator, denominator, places); err != nil {
+		return decimal.Zero, err
+	}
+	return numerator.DivRound(denominator, int32(places)), nil
+}
+
+// произведение значений, размер которого проверяется до каждого умножения
+func (c *FloatCalculator) product(values []decimal.Decimal) (decimal.Decimal, error) {
+	result := decimal.New(1, 0)
+	for _, value := range values {
+		exp := int64(result.Exponent()) + int64(value.Exponent())
+		if err := c.checkSize(exp, int64(result.NumDigits())+int64(value.NumDigits())); err != nil {
+			return decimal.Zero, err
+		}
+		result = result.Mul(value)
+	}
+	return result, nil
+}
+
+/*
+Проверка размера частного до деления с округлением до places знаков после точки.
+При делении делимое или делитель домножается на 10^shift (см. decimal.QuoRem).
+Домноженное делимое не длиннее частного и делителя вместе, а домноженный делитель
+может быть сколь угодно длиннее частного, поэтому его количество цифр проверяется отдельно.
+*/
+func (c *FloatCalculator) checkQuotient(numerator, denominator decimal.Decimal, places int64) error {
+	if err := c.checkSize(-places, top(numerator)-top(denominator)+places+1); err != nil {
+		return err
+	}
+	shift := int64(numerator.Exponent()) - int64(denominator.Exponent()) + places
+	if shift > math.MaxInt32 || shift < math.MinInt32 {
+		return ErrNumberTooLarge
+	}
+	if shift < 0 {
+		return c.checkSize(0, int64(denominator.NumDigits())-shift)
+	}
+	return nil
+}
+
+/*
+Проверка показателя степени и количества цифр числа: показатель степени
+всегда должен помещаться в int32, остальное проверяется по ограничениям вычислителя.
+*/
+func (c *FloatCalculator) checkSize(exp, digits int64) error {
+	if exp > math.MaxInt32 || exp < math.MinInt32 || digits > math.MaxInt32 {
+		return ErrNumberTooLarge
+	}
+	if c.MaxExponent > 0 && (exp > int64(c.MaxExponent) || exp < -int64(c.MaxExponent)) {
+		return ErrNumberTooLarge
+	}
+	if c.MaxDigits > 0 && digits > int64(c.MaxDigits) {
+		return ErrNumberTooLarge
+	}
+	return nil
+}
+
+// разряд, следующий за старшей цифрой числа
+func top(value decimal.Decimal) int64 {
+	return int64(value.NumDigits()) + int64(value.Exponent())
+}
+
+type retainCtxKey struct{}
+
+/*
+Контекст вычислений, в котором retain вызывается при запуске каждого вычисления в фоне,
+а возвращённая им функция - после его окончания, даже если ответ по ctx уже отправлен.
+Так ресурсы запроса (например, место в ограничителе одновременных вычислений)
+остаются занятыми, пока вычисление действительно идёт.
+*/
+func WithRetain(ctx context.Context, retain func(ctx context.Context) (release func())) context.Context {
+	return context.WithValue(ctx, retainCtxKey{}, retain)
+}
+
+/*
+Выполнение fn с отказом от результата при отмене ctx.
+Операции decimal и big нельзя прервать, а при больших показателях степени
+деление может идти секундами, поэтому fn выполняется в отдельной горутине:
+при отмене ctx ответ отправляется сразу, а fn досчитывается в фоне и её результат отбрасывается.
+Паника в fn не роняет сервер, а возвращается как ErrCalculationFailed.
+Фоновое выполнение fn удерживается через retain из WithRetain до его окончания.
+*/
+func await[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	type outcome struct {
+		value T
+		err   error
+	}
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+	release := func() {}
+	if retain, ok := ctx.Value(retainCtxKey{}).(func(context.Context) func()); ok {
+		release = retain(ctx)
+	}
+	result := make(chan outcome, 1)
+	go func() {
+		defer release()
+		defer func() {
+			if p := recover(); p != nil {
+				result <- outcome{err: ErrCalculationFailed}
+			}
+		}()
+		value, err := fn()
+		result <- outcome{value: value, err: err}
+	}()
+	select {
+	case out := <-result:
+		return out.value, out.err
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
+
+// десятичный порядок частного ненулевого делимого и делителя
+func magnitude(numerator, denominator decimal.Decimal) int64 {
+	// порядок частного равен разности порядков делимого и делителя
+	// или на единицу меньше, если при выравнивании старших цифр делимое меньше делителя
+	result := adjustedExponent(numerator) - adjustedExponent(denominator)
+	num := decimal.NewFromBigInt(numerator.Coefficient(), int32(denominator.NumDigits()))
+	den := decimal.NewFromBigInt(denominator.Coefficient(), int32(numerator.NumDigits()))
+	if num.Abs().LessThan(den.Abs()) {
+		result--
+	}
+	return result
+}
+
+// десятичный порядок старшей цифры числа
+func adjustedExponent(d decimal.Decimal) int64 {
+	return top(d) - 1
+}
+
+// точный результат вычисления значения и его отличие от округлённого
+type Detail struct {
+	Exact         *big.Rat // точное значение без округления
+	Rounded       bool     // изменило ли округление значение
+	RoundingError *big.Rat // округлённое значение минус точное
+}
+
+/*
+Точные значения операндов в виде рациональных чисел
+и погрешность округления для соответствующих им values,
+полученных из Calculate.
+*/
+func (c *FloatCalculator) Details(ctx context.Context, operands []Operand, values []decimal.Decimal) ([]Detail, error) {
+	if len(operands) != len(values) {
+		return nil, i18n.NewError(i18n.OperandsMismatch)
+	}
+	if err := c.Check(operands); err != nil {
+		return nil, err
+	}
+	details := make([]Detail, 0, len(operands))
+	for i, operand := range operands {
+		exact, err := await(ctx, func() (*big.Rat, error) {
+			return c.exactValue(operand)
+		})
+		if err != nil {
+			return nil, err
+		}
+		roundingError := new(big.Rat).Sub(values[i].Rat(), exact)
+		details = append(details, Detail{
+			Exact:         exact,
+			Rounded:       roundingError.Sign() != 0,
+			RoundingError: roundingError,
+		})
+	}
+	return details, nil
+}
+
+// точное значение операнда
+func (c *FloatCalculator) exactValue(operand Operand) (*big.Rat, error) {
+	numerator, err := c.product(operand.Factors)
+	if err != nil {
+		return nil, err
+	}
+	denominator, err := c.product(operand.Divisors)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Rat).Quo(numerator.Rat(), denominator.Rat()), nil
+}
+
+// разбиение значений на группы равных
+func EqualityGroups(values []decimal.Decimal) [][]int {
+	groups := [][]int{}
+	for i, value := range values {
+		found := false
+		for g, group := range groups {
+			if values[group[0]].Equal(value) {
+				groups[g] = append(group, i)
+				found = true
+				break
+			}
+		}
+		if !found {
+			groups = append(groups, []int{i})
+		}
+	}
+	return groups
+}
+
+// “T” - если все значения равны, и “F” в противном случае
+func IsEqualString(groups [][]int) string {
+	if len(groups) == 1 {
+		return "T"
+	}
+	return "F"
 }
 
 func FloatCalculationError(msg string) (
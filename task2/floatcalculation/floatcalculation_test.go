@@ -1,8 +1,12 @@
 package floatcalculation
 
 import (
+	"context"
 	"log"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
@@ -292,3 +296,472 @@ func TestFloatCalculation(t *testing.T) {
 		})
 	}
 }
+
+func TestCalculate(t *testing.T) {
+	calc := FloatCalculator{}
+
+	cases := []struct {
+		name     string
+		operands []Operand
+		E        int32
+		values   []decimal.Decimal
+		groups   [][]int
+		Err      string
+	}{
+		{
+			name: "Три значения, все равны",
+			operands: []Operand{
+				{Factors: []decimal.Decimal{DecimalFromString("1.5"), DecimalFromString("3")}, Divisors: []decimal.Decimal{DecimalFromString("2")}},
+				{Factors: []decimal.Decimal{DecimalFromString("4.5"), DecimalFromString("3")}, Divisors: []decimal.Decimal{DecimalFromString("6")}},
+				{Factors: []decimal.Decimal{DecimalFromString("2.25")}},
+			},
+			E:      3,
+			values: []decimal.Decimal{DecimalFromString("2.250"), DecimalFromString("2.250"), DecimalFromString("2.250")},
+			groups: [][]int{{0, 1, 2}},
+		},
+		{
+			name: "Группы равных значений",
+			operands: []Operand{
+				{Factors: []decimal.Decimal{DecimalFromString("1")}, Divisors: []decimal.Decimal{DecimalFromString("3")}},
+				{Factors: []decimal.Decimal{DecimalFromString("2")}},
+				{Factors: []decimal.Decimal{DecimalFromString("2")}, Divisors: []decimal.Decimal{DecimalFromString("6")}},
+				{Factors: []decimal.Decimal{DecimalFromString("8")}, Divisors: []decimal.Decimal{DecimalFromString("2"), DecimalFromString("2")}},
+			},
+			E:      2,
+			values: []decimal.Decimal{DecimalFromString("0.33"), DecimalFromString("2"), DecimalFromString("0.33"), DecimalFromString("2")},
+			groups: [][]int{{0, 2}, {1, 3}},
+		},
+		{
+			name: "Все значения различны",
+			operands: []Operand{
+				{Factors: []decimal.Decimal{DecimalFromString("1"), DecimalFromString("2"), DecimalFromString("3")}},
+				{Factors: []decimal.Decimal{DecimalFromString("1")}, Divisors: []decimal.Decimal{DecimalFromString("4")}},
+			},
+			E:      1,
+			values: []decimal.Decimal{DecimalFromString("6"), DecimalFromString("0.3")},
+			groups: [][]int{{0}, {1}},
+		},
+		{
+			name: "Деление на нуль в одном из делителей",
+			operands: []Operand{
+				{Factors: []decimal.Decimal{DecimalFromString("1")}},
+				{Factors: []decimal.Decimal{DecimalFromString("1")}, Divisors: []decimal.Decimal{DecimalFromString("2"), decimal.Zero}},
+			},
+			E:   1,
+			Err: "деление на нуль",
+		},
+		{
+			name: "Показатель степени произведения не помещается в int32",
+			operands: []Operand{
+				{Factors: []decimal.Decimal{DecimalFromString("1e2000000000"), DecimalFromString("1e2000000000")}, Divisors: []decimal.Decimal{DecimalFromString("2")}},
+			},
+			E:   2,
+			Err: "превышен допустимый размер числа",
+		},
+		{
+			name: "Показатель степени частного не помещается в int32",
+			operands: []Operand{
+				{Factors: []decimal.Decimal{DecimalFromString("1e2000000000")}, Divisors: []decimal.Decimal{DecimalFromString("1e-2000000000")}},
+			},
+			E:   2,
+			Err: "превышен допустимый размер числа",
+		},
+	}
+
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			values, groups, err := calc.Calculate(context.Background(), test_case.operands, test_case.E, PrecisionPlaces)
+			if test_case.Err != "" {
+				assert.EqualError(t, err, test_case.Err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, values, len(test_case.values))
+			for i := range test_case.values {
+				assert.True(t, test_case.values[i].Equal(values[i]), "ожидаемое значение %v, полученное %v", test_case.values[i], values[i])
+			}
+			assert.Equal(t, test_case.groups, groups)
+		})
+	}
+}
+
+func TestDetails(t *testing.T) {
+	calc := FloatCalculator{}
+
+	cases := []struct {
+		name          string
+		operands      []Operand
+		E             int32
+		exact         []string
+		rounded       []bool
+		roundingError []string
+		Err           string
+	}{
+		{
+			name: "Равенство без округления",
+			operands: []Operand{
+				{Factors: []decimal.Decimal{DecimalFromString("1"), DecimalFromString("3")}, Divisors: []decimal.Decimal{DecimalFromString("2")}},
+				{Factors: []decimal.Decimal{DecimalFromString("1.5")}},
+			},
+			E:             5,
+			exact:         []string{"3/2", "3/2"},
+			rounded:       []bool{false, false},
+			roundingError: []string{"0/1", "0/1"},
+		},
+		{
+			name: "Равенство из-за округления",
+			operands: []Operand{
+				{Factors: []decimal.Decimal{DecimalFromString("1")}, Divisors: []decimal.Decimal{DecimalFromString("3")}},
+				{Factors: []decimal.Decimal{DecimalFromString("0.3334")}},
+			},
+			E:             2,
+			exact:         []string{"1/3", "1667/5000"},
+			rounded:       []bool{true, true},
+			roundingError: []string{"-1/300", "-17/5000"},
+		},
+		{
+			name: "Деление на нуль",
+			operands: []Operand{
+				{Factors: []decimal.Decimal{DecimalFromString("1")}, Divisors: []decimal.Decimal{decimal.Zero}},
+			},
+			E:   2,
+			Err: "деление на нуль",
+		},
+	}
+
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			values := make([]decimal.Decimal, len(test_case.operands))
+			if test_case.Err == "" {
+				var err error
+				values, _, err = calc.Calculate(context.Background(), test_case.operands, test_case.E, PrecisionPlaces)
+				assert.NoError(t, err)
+			}
+			details, err := calc.Details(context.Background(), test_case.operands, values)
+			if test_case.Err != "" {
+				assert.EqualError(t, err, test_case.Err)
+				return
+			}
+			assert.NoError(t, err)
+			for i, detail := range details {
+				assert.Equal(t, test_case.exact[i], detail.Exact.String())
+				assert.Equal(t, test_case.rounded[i], detail.Rounded)
+				assert.Equal(t, test_case.roundingError[i], detail.RoundingError.String())
+			}
+		})
+	}
+}
+
+func TestCalculate_Significant(t *testing.T) {
+	calc := FloatCalculator{}
+
+	cases := []struct {
+		name     string
+		operands []Operand
+		E        int32
+		values   []decimal.Decimal
+		groups   [][]int
+		Err      string
+	}{
+		{
+			name: "Очень большие числа",
+			operands: []Operand{
+				{Factors: []decimal.Decimal{DecimalFromString("6.02e23")}, Divisors: []decimal.Decimal{DecimalFromString("3")}},
+				{Factors: []decimal.Decimal{DecimalFromString("2.0071e23")}},
+			},
+			E:      3,
+			values: []decimal.Decimal{DecimalFromString("2.01e23"), DecimalFromString("2.01e23")},
+			groups: [][]int{{0, 1}},
+		},
+		{
+			name: "Очень малые числа",
+			operands: []Operand{
+				{Factors: []decimal.Decimal{DecimalFromString("1e-30")}, Divisors: []decimal.Decimal{DecimalFromString("3")}},
+				{Factors: []decimal.Decimal{DecimalFromString("-1e-30")}, Divisors: []decimal.Decimal{DecimalFromString("3")}},
+			},
+			E:      2,
+			values: []decimal.Decimal{DecimalFromString("3.3e-31"), DecimalFromString("-3.3e-31")},
+			groups: [][]int{{0}, {1}},
+		},
+		{
+			name: "Порядок частного меньше разности порядков",
+			operands: []Operand{
+				{Factors: []decimal.Decimal{DecimalFromString("1")}, Divisors: []decimal.Decimal{DecimalFromString("7")}},
+				{Factors: []decimal.Decimal{DecimalFromString("9")}, Divisors: []decimal.Decimal{DecimalFromString("1")}},
+			},
+			E:      2,
+			values: []decimal.Decimal{DecimalFromString("0.14"), DecimalFromString("9")},
+			groups: [][]int{{0}, {1}},
+		},
+		{
+			name: "Перенос разряда при округлении",
+			operands: []Operand{
+				{Factors: []decimal.Decimal{DecimalFromString("9.996")}},
+				{Factors: []decimal.Decimal{DecimalFromString("10")}},
+			},
+			E:      3,
+			values: []decimal.Decimal{DecimalFromString("10.0"), DecimalFromString("10")},
+			groups: [][]int{{0, 1}},
+		},
+		{
+			name: "Нулевое значение",
+			operands: []Operand{
+				{Factors: []decimal.Decimal{decimal.Zero}, Divisors: []decimal.Decimal{DecimalFromString("3")}},
+				{Factors: []decimal.Decimal{DecimalFromString("0.0001")}},
+			},
+			E:      1,
+			values: []decimal.Decimal{decimal.Zero, DecimalFromString("0.0001")},
+			groups: [][]int{{0}, {1}},
+		},
+		{
+			name: "Нулевая точность",
+			operands: []Operand{
+				{Factors: []decimal.Decimal{DecimalFromString("1")}},
+				{Factors: []decimal.Decimal{DecimalFromString("1")}},
+			},
+			E:   0,
+			Err: "в режиме significant точность E должна быть положительной",
+		},
+	}
+
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			values, groups, err := calc.Calculate(context.Background(), test_case.operands, test_case.E, PrecisionSignificant)
+			if test_case.Err != "" {
+				assert.EqualError(t, err, test_case.Err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, values, len(test_case.values))
+			for i := range test_case.values {
+				assert.True(t, test_case.values[i].Equal(values[i]), "ожидаемое значение %v, полученное %v", test_case.values[i], values[i])
+			}
+			assert.Equal(t, test_case.groups, groups)
+		})
+	}
+}
+
+func TestCalculate_Canceled(t *testing.T) {
+	calc := FloatCalculator{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	operands := []Operand{
+		{Factors: []decimal.Decimal{DecimalFromString("1")}, Divisors: []decimal.Decimal{DecimalFromString("3")}},
+		{Factors: []decimal.Decimal{DecimalFromString("2")}},
+	}
+	_, _, err := calc.Calculate(ctx, operands, 5, PrecisionPlaces)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = calc.Details(ctx, operands, []decimal.Decimal{decimal.Zero, decimal.Zero})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestCalculate_Limits(t *testing.T) {
+	calc := FloatCalculator{MaxExponent: 1000, MaxDigits: 2000}
+
+	cases := []struct {
+		name     string
+		operands []Operand
+		E        int32
+		mode     PrecisionMode
+		value    string
+		Err      error
+		exact    bool // ошибка возвращается и при вычислении точных значений
+	}{
+		{
+			name:     "Допустимый размер",
+			operands: []Operand{{Factors: []decimal.Decimal{DecimalFromString("1e900"), DecimalFromString("2")}, Divisors: []decimal.Decimal{DecimalFromString("1e-900")}}},
+			E:        2,
+			value:    "2e1800",
+		},
+		{
+			name:     "Показатель степени множителя",
+			operands: []Operand{{Factors: []decimal.Decimal{DecimalFromString("1e2000")}}},
+			E:        2,
+			Err:      ErrNumberTooLarge,
+			exact:    true,
+		},
+		{
+			name:     "Количество цифр делителя",
+			operands: []Operand{{Factors: []decimal.Decimal{DecimalFromString("1")}, Divisors: []decimal.Decimal{DecimalFromString("1" + strings.Repeat("0", 2000) + ".1")}}},
+			E:        2,
+			Err:      ErrNumberTooLarge,
+			exact:    true,
+		},
+		{
+			name:     "Показатель степени произведения",
+			operands: []Operand{{Factors: []decimal.Decimal{DecimalFromString("1e900"), DecimalFromString("1e900")}}},
+			E:        2,
+			Err:      ErrNumberTooLarge,
+			exact:    true,
+		},
+		{
+			name:     "Деление на число с большим отрицательным показателем степени",
+			operands: []Operand{{Factors: []decimal.Decimal{DecimalFromString("1")}, Divisors: []decimal.Decimal{DecimalFromString("1e-400000000")}}},
+			E:        2,
+			Err:      ErrNumberTooLarge,
+			exact:    true,
+		},
+		{
+			name:     "Количество цифр частного",
+			operands: []Operand{{Factors: []decimal.Decimal{DecimalFromString("1e1000")}, Divisors: []decimal.Decimal{DecimalFromString("1e-1000")}}},
+			E:        2,
+			Err:      ErrNumberTooLarge,
+		},
+		{
+			name:     "Значащие цифры частного",
+			operands: []Operand{{Factors: []decimal.Decimal{DecimalFromString("1e-900")}, Divisors: []decimal.Decimal{DecimalFromString("3e900")}}},
+			E:        5,
+			mode:     PrecisionSignificant,
+			Err:      ErrNumberTooLarge,
+		},
+	}
+
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			mode := test_case.mode
+			if mode == "" {
+				mode = PrecisionPlaces
+			}
+			values, _, err := calc.Calculate(context.Background(), test_case.operands, test_case.E, mode)
+			if test_case.Err != nil {
+				assert.ErrorIs(t, err, test_case.Err)
+				if test_case.exact {
+					_, err = calc.Details(context.Background(), test_case.operands, make([]decimal.Decimal, len(test_case.operands)))
+					assert.ErrorIs(t, err, test_case.Err)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, DecimalFromString(test_case.value).Equal(values[0]), "ожидаемое значение %v, полученное %v", test_case.value, values[0])
+		})
+	}
+}
+
+/*
+Ограничения размера чисел ограничивают и работу, которая продолжается в фоне после отмены ctx:
+вычисление с наибольшими допустимыми операндами заканчивается быстро,
+а оставшиеся после ответа горутины вычислений завершаются.
+*/
+func TestCalculate_LimitsStopWork(t *testing.T) {
+	calc := FloatCalculator{MaxExponent: 1000, MaxDigits: 2000}
+	digits := DecimalFromString(strings.Repeat("7", 1000) + "e-500")
+	operands := make([]Operand, 64)
+	for i := range operands {
+		operands[i] = Operand{
+			Factors:  []decimal.Decimal{digits, digits},
+			Divisors: []decimal.Decimal{DecimalFromString("3" + strings.Repeat("1", 999))},
+		}
+	}
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, _, err := calc.Calculate(ctx, operands, 1000, PrecisionPlaces)
+	if err != nil {
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	}
+	// assert.Eventually проверяет условие в отдельной горутине, поэтому ждём вручную
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > before && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before)
+
+	start := time.Now()
+	_, _, err = calc.Calculate(context.Background(), operands, 1000, PrecisionPlaces)
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+// паника при вычислении возвращается как ошибка и не роняет процесс
+func TestAwait_Panic(t *testing.T) {
+	_, err := await(context.Background(), func() (int, error) {
+		panic("exponent 4000000000 overflows an int32!")
+	})
+	assert.ErrorIs(t, err, ErrCalculationFailed)
+}
+
+// удержание из WithRetain отпускается после окончания вычисления, а не после ответа по ctx
+func TestAwait_Retain(t *testing.T) {
+	released := make(chan struct{})
+	retained := 0
+	ctx, cancel := context.WithCancel(WithRetain(context.Background(), func(context.Context) func() {
+		retained++
+		return func() { close(released) }
+	}))
+	unblock := make(chan struct{})
+	go cancel()
+	_, err := await(ctx, func() (int, error) {
+		<-unblock
+		return 1, nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, retained)
+	select {
+	case <-released:
+		t.Fatal("удержание отпущено до окончания вычисления")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(unblock)
+	select {
+	case <-released:
+	case <-time.After(time.Second):
+		t.Fatal("удержание не отпущено после окончания вычисления")
+	}
+}
+
+// долгое деление не задерживает ответ после истечения времени вычислений
+func TestCalculate_DeadlineDuringDivision(t *testing.T) {
+	calc := FloatCalculator{}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	operands := []Operand{
+		{Factors: []decimal.Decimal{DecimalFromString("1")}, Divisors: []decimal.Decimal{DecimalFromString("1e-2000000")}},
+	}
+	start := time.Now()
+	_, _, err := calc.Calculate(ctx, operands, 5, PrecisionPlaces)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestDigits(t *testing.T) {
+	cases := []struct {
+		name     string
+		operands []Operand
+		digits   int
+	}{
+		{
+			name:     "Без операндов",
+			operands: nil,
+			digits:   0,
+		},
+		{
+			name: "Множители и делители",
+			operands: []Operand{
+				{Factors: []decimal.Decimal{DecimalFromString("1.5"), DecimalFromString("-300")}, Divisors: []decimal.Decimal{DecimalFromString("7")}},
+				{Factors: []decimal.Decimal{DecimalFromString("0.001")}},
+			},
+			digits: 2 + 3 + 1 + 3,
+		},
+		{
+			name: "Экспонента",
+			operands: []Operand{
+				{Factors: []decimal.Decimal{DecimalFromString("1e1000"), DecimalFromString("12e-500")}},
+			},
+			digits: 1001 + 500,
+		},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test_case.digits, Digits(test_case.operands))
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package handlecalculatev2
+
+import (
+	"FloatService/codec"
+	"FloatService/floatcalculation"
+	"FloatService/handlers/handlefloatcalculation"
+	"FloatService/response"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+/*
+Запрос второй версии API: только произвольное количество значений,
+имена полей в snake_case, точность называется precision.
+Precision - указатель, чтобы отличать его отсутствие от нуля.
+*/
+type Request struct {
+	Values        []Operand `json:"values" xml:"values" validate:"required,min=2,max=64,dive"`
+	Precision     *int32    `json:"precision" xml:"precision" validate:"required,min=-1000,max=1000"`
+	PrecisionMode string    `json:"precision_mode" xml:"precision_mode" validate:"omitempty,oneof=places significant"`
+	Detail        bool      `json:"detail" xml:"detail"`
+}
+
+// значение вида (F1 * F2 * ...) / (D1 * D2 * ...)
+type Operand struct {
+	Factors  []decimal.Decimal `json:"factors" xml:"factors" validate:"required,min=1,max=64"`
+	Divisors []decimal.Decimal `json:"divisors,omitempty" xml:"divisors,omitempty" validate:"max=64,dive,nonzero"`
+}
+
+// is_equal - логическое значение вместо “T”/“F” первой версии
+type Response struct {
+	response.Response
+	Values  []decimal.Decimal `json:"values" xml:"values"`
+	IsEqual bool              `json:"is_equal" xml:"is_equal"`
+	Groups  []codec.IntList   `json:"groups" xml:"groups"`
+	Details []Detail          `json:"details,omitempty" xml:"details,omitempty"`
+}
+
+// точный результат без округления при detail=true
+type Detail struct {
+	Numerator     string `json:"numerator" xml:"numerator"`
+	Denominator   string `json:"denominator" xml:"denominator"`
+	Rounded       bool   `json:"rounded" xml:"rounded"`
+	RoundingError string `json:"rounding_error" xml:"rounding_error"`
+}
+
+/*
+создание нового обработчика запроса второй версии,
+computeTimeout - ограничение времени вычислений для одного запроса (0 - без ограничения).
+Обработка та же, что у первой версии, отличаются только типы запроса и ответа.
+*/
+func New(log *slog.Logger, calculator handlefloatcalculation.FloatCalculatorInt, computeTimeout time.Duration) http.HandlerFunc {
+	return handlefloatcalculation.NewHandler(log, calculator, computeTimeout, "handlers.handlecalculatev2.New",
+		func() handlefloatcalculation.CalculationRequest { return &Request{} },
+		handlefloatcalculation.DecodeBody,
+	)
+}
+
+// параметры вычисления; вызывается после валидации, поэтому Precision задан
+func (req *Request) Calculation() handlefloatcalculation.Calculation {
+	return handlefloatcalculation.Calculation{
+		Operands: req.Operands(),
+		E:        *req.Precision,
+		Mode:     req.Mode(),
+		Detail:   req.Detail,
+	}
+}
+
+func (req *Request) Response(result handlefloatcalculation.Result) any {
+	resp := Response{
+		Response: response.OK(),
+		Values:   result.Values,
+		IsEqual:  len(result.Groups) == 1,
+		Groups:   codec.IntLists(result.Groups),
+	}
+	if result.Details != nil {
+		resp.Details = NewDetails(result.Details)
+	}
+	return resp
+}
+
+func (req *Request) Operands() []floatcalculation.Operand {
+	operands := make([]floatcalculation.Operand, 0, len(req.Values))
+	for _, value := range req.Values {
+		operands = append(operands, floatcalculation.Operand{
+			Factors:  value.Factors,
+			Divisors: value.Divisors,
+		})
+	}
+	return operands
+}
+
+func (req *Request) Mode() floatcalculation.PrecisionMode {
+	if req.PrecisionMode == "" {
+		return floatcalculation.PrecisionPlaces
+	}
+	return floatcalculation.PrecisionMode(req.PrecisionMode)
+}
+
+// перевод точных значений в формат ответа
+func NewDetails(exact []floatcalculation.Detail) []Detail {
+	details := make([]Detail, 0, len(exact))
+	for _, detail := range exact {
+		details = append(details, Detail{
+			Numerator:     detail.Exact.Num().String(),
+			Denominator:   detail.Exact.Denom().String(),
+			Rounded:       detail.Rounded,
+			RoundingError: detail.RoundingError.RatString(),
+		})
+	}
+	return details
+}
@@ -0,0 +1,121 @@
+package handlecalculatev2
+
+import (
+	"FloatService/floatcalculation"
+	"FloatService/handlers/handlefloatcalculation/mocks"
+	"FloatService/nulllogger"
+	"bytes"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleCalculateV2(t *testing.T) {
+	operands := []floatcalculation.Operand{
+		{Factors: []decimal.Decimal{decimal.New(1, 0), decimal.New(3, 0)}, Divisors: []decimal.Decimal{decimal.New(2, 0)}},
+		{Factors: []decimal.Decimal{decimal.New(3, 0)}, Divisors: []decimal.Decimal{decimal.New(2, 0)}},
+	}
+	cases := []struct {
+		name      string
+		input     string
+		response  string
+		status    int
+		mockError error
+		values    []decimal.Decimal
+		groups    [][]int
+	}{
+		{
+			name:     "Равные значения",
+			input:    `{"values":[{"factors":["1","3"],"divisors":["2"]},{"factors":["3"],"divisors":["2"]}],"precision":5}`,
+			response: `{"status":"OK","values":["1.5","1.5"],"is_equal":true,"groups":[[0,1]]}`,
+			values:   []decimal.Decimal{decimal.New(15, -1), decimal.New(15, -1)},
+			groups:   [][]int{{0, 1}},
+		},
+
+		{
+			name:     "Разные значения",
+			input:    `{"values":[{"factors":["1","3"],"divisors":["2"]},{"factors":["3"],"divisors":["2"]}],"precision":5}`,
+			response: `{"status":"OK","values":["1.5","1.4"],"is_equal":false,"groups":[[0],[1]]}`,
+			values:   []decimal.Decimal{decimal.New(15, -1), decimal.New(14, -1)},
+			groups:   [][]int{{0}, {1}},
+		},
+
+		{
+			name:      "Деление на нуль",
+			input:     `{"values":[{"factors":["1","3"],"divisors":["2"]},{"factors":["3"],"divisors":["2"]}],"precision":5}`,
+			response:  `{"status":"Error","error":"деление на нуль","code":"DIVISION_BY_ZERO"}`,
+			status:    http.StatusUnprocessableEntity,
+			mockError: floatcalculation.ErrDivisionByZero,
+		},
+
+		{
+			name:     "Поля первой версии не принимаются",
+			input:    `{"X1":"1","X2":"2","X3":"3","Y1":"1","Y2":"2","Y3":"3","E":5}`,
+			response: `{"status":"Error","error":"Некорректный запрос","code":"VALIDATION_FAILED","fields":[{"field":"values","rule":"required","message":"Поле обязательно."},{"field":"precision","rule":"required","message":"Поле обязательно."}]}`,
+			status:   http.StatusBadRequest,
+		},
+
+		{
+			name:     "Некорректный JSON",
+			input:    `}{`,
+			response: `{"status":"Error","error":"Ошибка декодирования запроса.","code":"INVALID_JSON"}`,
+			status:   http.StatusBadRequest,
+		},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			calculatorMock := mocks.NewFloatCalculatorInt(t)
+			if test_case.values != nil || test_case.mockError != nil {
+				calculatorMock.On("Check", mock.Anything).Return(nil).Once()
+				calculatorMock.On("Calculate", mock.Anything, operands, int32(5), floatcalculation.PrecisionPlaces).
+					Return(test_case.values, test_case.groups, test_case.mockError).Once()
+			}
+			handler := New(slog.New(&nulllogger.NullLogger{}), calculatorMock, 0)
+			req, err := http.NewRequest(http.MethodPost, "/v2/calculate", bytes.NewReader([]byte(test_case.input)))
+			require.NoError(t, err)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			status := http.StatusOK
+			if test_case.status != 0 {
+				status = test_case.status
+			}
+			require.Equal(t, status, rr.Code)
+			require.JSONEq(t, test_case.response, rr.Body.String())
+		})
+	}
+}
+
+func TestHandleCalculateV2_Detail(t *testing.T) {
+	operands := []floatcalculation.Operand{
+		{Factors: []decimal.Decimal{decimal.New(1, 0)}, Divisors: []decimal.Decimal{decimal.New(3, 0)}},
+		{Factors: []decimal.Decimal{decimal.New(1, 0)}, Divisors: []decimal.Decimal{decimal.New(3, 0)}},
+	}
+	values := []decimal.Decimal{decimal.New(33, -2), decimal.New(33, -2)}
+	calculatorMock := mocks.NewFloatCalculatorInt(t)
+	calculatorMock.On("Check", mock.Anything).Return(nil).Once()
+	calculatorMock.On("Calculate", mock.Anything, operands, int32(2), floatcalculation.PrecisionSignificant).Return(values, [][]int{{0, 1}}, nil).Once()
+	calculatorMock.On("Details", mock.Anything, operands, values).Return(
+		[]floatcalculation.Detail{
+			{Exact: big.NewRat(1, 3), Rounded: true, RoundingError: big.NewRat(-1, 300)},
+			{Exact: big.NewRat(1, 3), Rounded: true, RoundingError: big.NewRat(-1, 300)},
+		},
+		nil,
+	).Once()
+	handler := New(slog.New(&nulllogger.NullLogger{}), calculatorMock, 0)
+	input := `{"values":[{"factors":["1"],"divisors":["3"]},{"factors":["1"],"divisors":["3"]}],"precision":2,"precision_mode":"significant","detail":true}`
+	req, err := http.NewRequest(http.MethodPost, "/v2/calculate", bytes.NewReader([]byte(input)))
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	detail := `{"numerator":"1","denominator":"3","rounded":true,"rounding_error":"-1/300"}`
+	require.JSONEq(t, `{"status":"OK","values":["0.33","0.33"],"is_equal":true,"groups":[[0,1]],"details":[`+detail+`,`+detail+`]}`, rr.Body.String())
+}
@@ -0,0 +1,94 @@
+package handleevaluation
+
+import (
+	"FloatService/codec"
+	"FloatService/expression"
+	"FloatService/i18n"
+	"FloatService/response"
+	"FloatService/validation"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/shopspring/decimal"
+)
+
+type Request struct {
+	Expression string           `json:"expression" xml:"expression" validate:"required"`
+	Variables  codec.DecimalMap `json:"variables" xml:"variables"`
+}
+
+type Response struct {
+	response.Response
+	Result decimal.Decimal `json:"result" xml:"result"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=EvaluatorInt
+type EvaluatorInt interface {
+	Evaluate(expr string, vars map[string]decimal.Decimal) (decimal.Decimal, error)
+}
+
+// создание нового обработчика запроса на вычисление выражения
+func New(log *slog.Logger, evaluator EvaluatorInt) http.HandlerFunc {
+	// валидатор общий для всех запросов, разбор тегов структур кэшируется в нём
+	validate := validation.Default()
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.handleevaluation.New"
+		// добавляем в логи имя функции и ID запроса
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+		lang := i18n.FromContext(r.Context())
+		log.Debug("Чтение запроса.")
+		var req Request
+		if err := codec.Decode(r, &req); err != nil {
+			log.Error("Ошибка декодирования тела запроса.", slog.String("error", err.Error()))
+			response.RenderDecodeError(w, r, err)
+			return
+		}
+		log.Debug("Декодировано тело запроса.", slog.Any("request", req))
+		log.Debug("Валидация запроса.")
+		if err := validate.Struct(req); err != nil {
+			log.Error("Некорректный запрос.", slog.String("error", err.Error()))
+			response.RenderError(w, r, http.StatusBadRequest, response.ValidationError(i18n.T(lang, i18n.InvalidRequest), validation.Fields(lang, err)))
+			return
+		}
+		log.Debug("Валидация запроса прошла успешно.")
+		log.Debug("Начинаем вычисление выражения.")
+		result, err := evaluator.Evaluate(req.Expression, req.Variables)
+		if err != nil {
+			log.Error("Ошибка в вычислении выражения.", slog.String("error", err.Error()))
+			status, code := evaluationErrorCode(err)
+			response.RenderError(w, r, status, response.Error(code, i18n.Message(lang, err)))
+			return
+		}
+		log.Debug("Вычисление окончено.")
+		log.Debug("Отправляем ответ.")
+		codec.Render(w, r, http.StatusOK, Response{
+			Response: response.OK(),
+			Result:   result,
+		})
+		log.Info("Результаты отправлены.")
+	}
+}
+
+// синтаксическая ошибка - некорректный запрос, остальное - ошибка вычислений
+func evaluationErrorCode(err error) (status int, code string) {
+	var syntaxErr *expression.SyntaxError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return http.StatusBadRequest, response.CodeSyntaxError
+	case errors.Is(err, expression.ErrDivisionByZero):
+		return http.StatusUnprocessableEntity, response.CodeDivisionByZero
+	case errors.Is(err, expression.ErrUnknownVariable):
+		return http.StatusUnprocessableEntity, response.CodeUnknownVariable
+	case errors.Is(err, expression.ErrTooLong),
+		errors.Is(err, expression.ErrTooDeep),
+		errors.Is(err, expression.ErrTooManyOperations),
+		errors.Is(err, expression.ErrNumberTooLarge):
+		return http.StatusUnprocessableEntity, response.CodeExpressionTooComplex
+	}
+	return http.StatusUnprocessableEntity, response.CodeCalculationError
+}
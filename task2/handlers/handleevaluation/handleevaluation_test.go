@@ -0,0 +1,255 @@
+package handleevaluation
+
+import (
+	"FloatService/codec"
+	"FloatService/expression"
+	"FloatService/handlers/handleevaluation/mocks"
+	"FloatService/i18n"
+	"FloatService/nulllogger"
+	"FloatService/response"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestHandleEvaluation(t *testing.T) {
+	cases := []struct {
+		name      string
+		input     string
+		respError string
+		mockError error
+		status    int
+		code      string
+	}{
+		{
+			name: "Успех",
+		},
+
+		{
+			name:      "Деление на нуль",
+			respError: "деление на нуль",
+			mockError: expression.ErrDivisionByZero,
+			status:    http.StatusUnprocessableEntity,
+			code:      response.CodeDivisionByZero,
+		},
+
+		{
+			name:      "Синтаксическая ошибка",
+			respError: "синтаксическая ошибка в позиции 2: неожиданный символ '^'",
+			mockError: &expression.SyntaxError{Pos: 2, Msg: "неожиданный символ '^'"},
+			status:    http.StatusBadRequest,
+			code:      response.CodeSyntaxError,
+		},
+
+		{
+			name:      "Превышено количество операций",
+			respError: "превышено максимальное количество операций",
+			mockError: expression.ErrTooManyOperations,
+			status:    http.StatusUnprocessableEntity,
+			code:      response.CodeExpressionTooComplex,
+		},
+
+		{
+			name:      "Неизвестная переменная",
+			respError: `неизвестная переменная "z"`,
+			mockError: i18n.NewError(i18n.UnknownVariable, "z"),
+			status:    http.StatusUnprocessableEntity,
+			code:      response.CodeUnknownVariable,
+		},
+
+		{
+			name:      "Некорректный запрос",
+			input:     "}{",
+			respError: "Ошибка декодирования запроса.",
+			status:    http.StatusBadRequest,
+			code:      response.CodeInvalidJSON,
+		},
+
+		{
+			name:      "Ошибка валидации: нет выражения",
+			input:     `{"variables":{"a":"1","b":"2"}}`,
+			respError: "Некорректный запрос",
+			status:    http.StatusBadRequest,
+			code:      response.CodeValidationFailed,
+		},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			evaluatorMock := mocks.NewEvaluatorInt(t)
+			if test_case.respError == "" || test_case.mockError != nil {
+				evaluatorMock.On(
+					"Evaluate",
+					"a / b",
+					map[string]decimal.Decimal{
+						"a": decimal.New(1, 0),
+						"b": decimal.New(2, 0),
+					},
+				).Return(
+					decimal.New(5, -1),
+					test_case.mockError,
+				).Once()
+			}
+			handler := New(slog.New(&nulllogger.NullLogger{}), evaluatorMock)
+			input := `{"expression":"a / b","variables":{"a":"1","b":"2"}}`
+			if test_case.input != "" {
+				input = test_case.input
+			}
+			req, err := http.NewRequest(http.MethodPost, "/evaluate", bytes.NewReader([]byte(input)))
+			require.NoError(t, err)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			status := http.StatusOK
+			if test_case.status != 0 {
+				status = test_case.status
+			}
+			require.Equal(t, status, rr.Code)
+			body := rr.Body.String()
+			var resp Response
+			require.NoError(t, json.Unmarshal([]byte(body), &resp))
+			require.Equal(t, test_case.respError, resp.Error)
+			require.Equal(t, test_case.code, resp.Code)
+			if test_case.respError == "" {
+				require.True(t, decimal.New(5, -1).Equal(resp.Result))
+			}
+		})
+	}
+}
+
+// сообщения об ошибках на языке из Accept-Language
+func TestHandleEvaluation_Localized(t *testing.T) {
+	cases := []struct {
+		name      string
+		input     string
+		mockError error
+		respError string
+	}{
+		{
+			name:      "Деление на нуль",
+			mockError: expression.ErrDivisionByZero,
+			respError: "division by zero",
+		},
+		{
+			name:      "Синтаксическая ошибка",
+			mockError: &expression.SyntaxError{Pos: 2, Detail: i18n.NewError(i18n.UnexpectedChar, '^')},
+			respError: "syntax error at position 2: unexpected character '^'",
+		},
+		{
+			name:      "Некорректный запрос",
+			input:     "}{",
+			respError: "Failed to decode request.",
+		},
+		{
+			name:      "Ошибка валидации",
+			input:     `{"variables":{"a":"1"}}`,
+			respError: "Invalid request",
+		},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			evaluatorMock := mocks.NewEvaluatorInt(t)
+			if test_case.mockError != nil {
+				evaluatorMock.On("Evaluate", "a / b", map[string]decimal.Decimal(nil)).
+					Return(decimal.Zero, test_case.mockError).Once()
+			}
+			handler := i18n.New(i18n.RU)(New(slog.New(&nulllogger.NullLogger{}), evaluatorMock))
+			input := `{"expression":"a / b"}`
+			if test_case.input != "" {
+				input = test_case.input
+			}
+			req, err := http.NewRequest(http.MethodPost, "/evaluate", bytes.NewReader([]byte(input)))
+			require.NoError(t, err)
+			req.Header.Set("Accept-Language", "en")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			require.Equal(t, "en", rr.Header().Get("Content-Language"))
+			var resp Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.Equal(t, test_case.respError, resp.Error)
+		})
+	}
+}
+
+// переменные принимаются во всех форматах тела запроса
+func TestHandleEvaluation_Formats(t *testing.T) {
+	variables := map[string]any{"a": "1", "b": "2"}
+	msgpackBody, err := msgpack.Marshal(map[string]any{"expression": "a / b", "variables": variables})
+	require.NoError(t, err)
+	cborBody, err := cbor.Marshal(map[string]any{"expression": "a / b", "variables": variables})
+	require.NoError(t, err)
+	cases := []struct {
+		name        string
+		contentType string
+		body        []byte
+		// формат ответа, если отличается от формата запроса
+		responseType string
+	}{
+		{
+			name:        "JSON",
+			contentType: codec.JSON,
+			body:        []byte(`{"expression":"a / b","variables":{"a":"1","b":"2"}}`),
+		},
+		{
+			name:        "Форма",
+			contentType: codec.Form,
+			body:        []byte(`expression=a+%2F+b&variables%5Ba%5D=1&variables[b]=2`),
+			// в формате формы ответы не отправляются
+			responseType: codec.JSON,
+		},
+		{
+			name:        "XML",
+			contentType: codec.XML,
+			body:        []byte(`<Request><expression>a / b</expression><variables><variable name="a">1</variable><variable name="b">2</variable></variables></Request>`),
+		},
+		{
+			name:        "MessagePack",
+			contentType: codec.MessagePack,
+			body:        msgpackBody,
+		},
+		{
+			name:        "CBOR",
+			contentType: codec.CBOR,
+			body:        cborBody,
+		},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			evaluatorMock := mocks.NewEvaluatorInt(t)
+			evaluatorMock.On(
+				"Evaluate",
+				"a / b",
+				map[string]decimal.Decimal{
+					"a": decimal.New(1, 0),
+					"b": decimal.New(2, 0),
+				},
+			).Return(decimal.New(5, -1), nil).Once()
+			handler := codec.New(0, true)(New(slog.New(&nulllogger.NullLogger{}), evaluatorMock))
+			req := httptest.NewRequest(http.MethodPost, "/evaluate", bytes.NewReader(test_case.body))
+			req.Header.Set("Content-Type", test_case.contentType)
+			req.Header.Set("Accept", test_case.contentType)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+			// ответ отправляется в том же формате, десятичные числа - строками
+			responseType := test_case.contentType
+			if test_case.responseType != "" {
+				responseType = test_case.responseType
+			}
+			require.Equal(t, responseType, rr.Header().Get("Content-Type"))
+			require.Contains(t, rr.Body.String(), "0.5")
+		})
+	}
+}
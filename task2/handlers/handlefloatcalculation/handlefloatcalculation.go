@@ -1,14 +1,20 @@
 package handlefloatcalculation
 
 import (
+	"FloatService/codec"
+	"FloatService/floatcalculation"
+	"FloatService/i18n"
+	"FloatService/middleware/concurrency"
+	"FloatService/middleware/ratelimit"
 	"FloatService/response"
+	"FloatService/validation"
+	"context"
+	"errors"
 	"log/slog"
 	"net/http"
-	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/render"
-	"github.com/go-playground/validator/v10"
 	"github.com/shopspring/decimal"
 )
 
@@ -18,15 +24,30 @@ E назначен указателем для того,
 Если он просто значение, то валидатор обрасывает запросы,
 где E=0.
 Нашёл данное решение в issue на гитхабе.
+Запрос передаётся либо в виде X1..Y3 (совместимый формат),
+либо в виде произвольного количества значений Values.
+PrecisionMode задаёт смысл E: places (по умолчанию) - знаки после точки,
+significant - значащие цифры.
+E ограничен по модулю 1000, а делители не могут быть нулём,
+поэтому деление на нуль обнаруживается ещё при валидации.
 */
 type Request struct {
-	X1 decimal.Decimal `json:"X1" validate:"required"`
-	X2 decimal.Decimal `json:"X2" validate:"required"`
-	X3 decimal.Decimal `json:"X3" validate:"required"`
-	Y1 decimal.Decimal `json:"Y1" validate:"required"`
-	Y2 decimal.Decimal `json:"Y2" validate:"required"`
-	Y3 decimal.Decimal `json:"Y3" validate:"required"`
-	E  *int32          `json:"E" validate:"required"`
+	X1            decimal.Decimal `json:"X1" validate:"required_without=Values,excluded_with=Values"`
+	X2            decimal.Decimal `json:"X2" validate:"required_without=Values,excluded_with=Values,nonzero"`
+	X3            decimal.Decimal `json:"X3" validate:"required_without=Values,excluded_with=Values"`
+	Y1            decimal.Decimal `json:"Y1" validate:"required_without=Values,excluded_with=Values"`
+	Y2            decimal.Decimal `json:"Y2" validate:"required_without=Values,excluded_with=Values,nonzero"`
+	Y3            decimal.Decimal `json:"Y3" validate:"required_without=Values,excluded_with=Values"`
+	Values        []Operand       `json:"Values,omitempty" validate:"omitempty,min=2,max=64,dive"`
+	E             *int32          `json:"E" validate:"required,min=-1000,max=1000"`
+	PrecisionMode string          `json:"precision_mode" xml:"precision_mode" validate:"omitempty,oneof=places significant"`
+	Detail        bool            `json:"detail" xml:"detail"`
+}
+
+// значение вида (F1 * F2 * ...) / (D1 * D2 * ...)
+type Operand struct {
+	Factors  []decimal.Decimal `json:"Factors" validate:"required,min=1,max=64"`
+	Divisors []decimal.Decimal `json:"Divisors,omitempty" validate:"max=64,dive,nonzero"`
 }
 
 type Response struct {
@@ -34,72 +55,301 @@ type Response struct {
 	X       decimal.Decimal `json:"X"`
 	Y       decimal.Decimal `json:"Y"`
 	IsEqual string          `json:"IsEqual"`
+	XDetail *Detail         `json:"XDetail,omitempty"`
+	YDetail *Detail         `json:"YDetail,omitempty"`
+}
+
+// ответ на запрос в виде Values
+type ValuesResponse struct {
+	response.Response
+	Values  []decimal.Decimal `json:"Values"`
+	IsEqual string            `json:"IsEqual"`
+	Groups  []codec.IntList   `json:"Groups"`
+	Details []Detail          `json:"Details,omitempty"`
+}
+
+/*
+Точный результат без округления при detail=true:
+числитель и знаменатель несократимой дроби,
+изменило ли округление значение
+и погрешность округления (округлённое минус точное) в виде дроби.
+*/
+type Detail struct {
+	Numerator     string `json:"Numerator"`
+	Denominator   string `json:"Denominator"`
+	Rounded       bool   `json:"Rounded"`
+	RoundingError string `json:"RoundingError"`
 }
 
 //go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=FloatCalculatorInt
 type FloatCalculatorInt interface {
-	FloatCalculation(
-		X1, X2, X3 decimal.Decimal,
-		Y1, Y2, Y3 decimal.Decimal,
-		E int32,
-	) (
-		X, Y decimal.Decimal,
-		IsEqual string,
+	Check(operands []floatcalculation.Operand) error
+	Calculate(ctx context.Context, operands []floatcalculation.Operand, E int32, mode floatcalculation.PrecisionMode) (
+		values []decimal.Decimal,
+		groups [][]int,
 		err error,
 	)
+	Details(ctx context.Context, operands []floatcalculation.Operand, values []decimal.Decimal) (
+		[]floatcalculation.Detail,
+		error,
+	)
+}
+
+/*
+Запрос на вычисление в формате одной из версий API.
+Calculation - параметры вычисления после успешной валидации запроса,
+Response - ответ версии API по результатам вычисления.
+*/
+type CalculationRequest interface {
+	Calculation() Calculation
+	Response(result Result) any
+}
+
+// параметры вычисления, общие для всех версий API
+type Calculation struct {
+	Operands []floatcalculation.Operand
+	E        int32
+	Mode     floatcalculation.PrecisionMode
+	Detail   bool
+}
+
+// результат вычисления, Details заполнены только при Calculation.Detail
+type Result struct {
+	Values  []decimal.Decimal
+	Groups  [][]int
+	Details []floatcalculation.Detail
+}
+
+// чтение запроса в req, при ошибке отправляет ответ и возвращает false
+type DecodeFunc func(log *slog.Logger, w http.ResponseWriter, r *http.Request, req CalculationRequest) bool
+
+/*
+создание нового обработчика запроса с телом в формате JSON, XML, MessagePack, CBOR или формы,
+computeTimeout - ограничение времени вычислений для одного запроса (0 - без ограничения)
+*/
+func New(log *slog.Logger, calculator FloatCalculatorInt, computeTimeout time.Duration) http.HandlerFunc {
+	return NewHandler(log, calculator, computeTimeout, "handlers.handlefloatcalculation.New", newRequest, DecodeBody)
+}
+
+/*
+создание нового обработчика запроса с параметрами в строке запроса
+(X1..Y3, E, precision_mode, detail), правила валидации те же, что и у New
+*/
+func NewQuery(log *slog.Logger, calculator FloatCalculatorInt, computeTimeout time.Duration) http.HandlerFunc {
+	return NewHandler(log, calculator, computeTimeout, "handlers.handlefloatcalculation.NewQuery", newRequest, decodeQuery)
+}
+
+func newRequest() CalculationRequest {
+	return &Request{}
+}
+
+// чтение тела запроса любого поддерживаемого формата
+func DecodeBody(log *slog.Logger, w http.ResponseWriter, r *http.Request, req CalculationRequest) bool {
+	if err := codec.Decode(r, req); err != nil {
+		log.Error("Ошибка декодирования тела запроса.", slog.String("error", err.Error()))
+		response.RenderDecodeError(w, r, err)
+		return false
+	}
+	return true
+}
+
+/*
+Заполнение запроса из параметров строки запроса.
+Отсутствующие параметры остаются нулевыми, чтобы их отсутствие обнаружил валидатор.
+Values в строке запроса не поддерживается.
+*/
+func decodeQuery(log *slog.Logger, w http.ResponseWriter, r *http.Request, req CalculationRequest) bool {
+	query := r.URL.Query()
+	if codec.Strict(r.Context()) {
+		if err := codec.CheckValues(query, req); err != nil {
+			log.Error("Ошибка разбора параметров запроса.", slog.String("error", err.Error()))
+			response.RenderDecodeError(w, r, err)
+			return false
+		}
+	}
+	if err := codec.DecodeValues(query, req); err != nil {
+		log.Error("Ошибка разбора параметров запроса.", slog.String("error", err.Error()))
+		response.RenderError(w, r, http.StatusBadRequest, response.Error(
+			response.CodeInvalidQuery, i18n.T(i18n.FromContext(r.Context()), i18n.QueryDecodeError),
+		))
+		return false
+	}
+	return true
 }
 
-// создание нового обработчика запроса
-func New(log *slog.Logger, calculator FloatCalculatorInt) http.HandlerFunc {
+/*
+Обработчик запроса на вычисление, общий для всех версий API: версии отличаются
+только типом запроса, который создаёт newRequest, и способом его чтения decode.
+op - имя обработчика в логах.
+*/
+func NewHandler(
+	log *slog.Logger,
+	calculator FloatCalculatorInt,
+	computeTimeout time.Duration,
+	op string,
+	newRequest func() CalculationRequest,
+	decode DecodeFunc,
+) http.HandlerFunc {
+	// валидатор общий для всех запросов, разбор тегов структур кэшируется в нём
+	validate := validation.Default()
 	return func(w http.ResponseWriter, r *http.Request) {
-		const op = "handlers.handlefloatcalculation.New"
 		// добавляем в логи имя функции и ID запроса
-		log = log.With(
+		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
+		lang := i18n.FromContext(r.Context())
 		log.Debug("Чтение запроса.")
-		var req Request
-		err := render.DecodeJSON(r.Body, &req)
-		if err != nil {
-			log.Error("Ошибка декодирования тела запроса.", slog.String("error", err.Error()))
-			render.JSON(w, r, response.Error("Ошибка декодирования запроса."))
+		req := newRequest()
+		if !decode(log, w, r, req) {
 			return
 		}
-		log.Debug("Декодировано тело запроса.", slog.Any("request", req), slog.Any("E", DereferenceToString(req.E)))
 		log.Debug("Валидация запроса.")
-		if err := validator.New(validator.WithRequiredStructEnabled()).Struct(req); err != nil {
+		if err := validate.Struct(req); err != nil {
 			log.Error("Некорректный запрос.", slog.String("error", err.Error()))
-			render.JSON(w, r, response.Error("Некорректный запрос"))
+			response.RenderError(w, r, http.StatusBadRequest, response.ValidationError(i18n.T(lang, i18n.InvalidRequest), validation.Fields(lang, err)))
+			return
+		}
+		calculation := req.Calculation()
+		// размер чисел проверяется до записи запроса в лог: запись числа вида 1e2000000000 раскрывает все его цифры
+		if err := calculator.Check(calculation.Operands); err != nil {
+			RenderCalculationError(w, r, log, err)
+			return
+		}
+		log.Debug("Валидация запроса прошла успешно.", slog.Any("request", req))
+		// вес вычислений списывается с лимита клиента, когда известны точность и операнды
+		if !ratelimit.ChargeCalculation(w, r, calculation.E, floatcalculation.Digits(calculation.Operands)) {
 			return
 		}
-		log.Debug("Валидация запроса прошла успешно.")
 		log.Debug("Начинаем расчёты.")
-		X, Y, IsEqual, err := calculator.FloatCalculation(
-			req.X1, req.X2, req.X3,
-			req.Y1, req.Y2, req.Y3,
-			*req.E,
-		)
+		// вычисления прерываются при отключении клиента, остановке сервера или по истечении времени,
+		// а место в ограничителе одновременных вычислений занято, пока они идут в фоне
+		ctx := floatcalculation.WithRetain(r.Context(), concurrency.Retain)
+		if computeTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, computeTimeout)
+			defer cancel()
+		}
+		var result Result
+		var err error
+		result.Values, result.Groups, err = calculator.Calculate(ctx, calculation.Operands, calculation.E, calculation.Mode)
 		if err != nil {
-			log.Error("Ошибка в расчётах.", slog.String("error", err.Error()))
-			render.JSON(w, r, response.Error(err.Error()))
+			RenderCalculationError(w, r, log, err)
 			return
 		}
+		if calculation.Detail {
+			log.Debug("Вычисляем точные значения.")
+			result.Details, err = calculator.Details(ctx, calculation.Operands, result.Values)
+			if err != nil {
+				RenderCalculationError(w, r, log, err)
+				return
+			}
+		}
 		log.Debug("Расчёты окончены.")
 		log.Debug("Отправляем ответ.")
-		render.JSON(w, r, Response{
+		codec.Render(w, r, http.StatusOK, req.Response(result))
+		log.Info("Результаты отправлены.")
+	}
+}
+
+// параметры вычисления; вызывается после валидации, поэтому E задан
+func (req *Request) Calculation() Calculation {
+	return Calculation{
+		Operands: req.Operands(),
+		E:        *req.E,
+		Mode:     req.Mode(),
+		Detail:   req.Detail,
+	}
+}
+
+// ответ в формате запроса: X и Y для совместимого формата, иначе список значений
+func (req *Request) Response(result Result) any {
+	var details []Detail
+	if result.Details != nil {
+		details = NewDetails(result.Details)
+	}
+	if len(req.Values) == 0 {
+		resp := Response{
 			Response: response.OK(),
-			X:        X,
-			Y:        Y,
-			IsEqual:  IsEqual,
+			X:        result.Values[0],
+			Y:        result.Values[1],
+			IsEqual:  floatcalculation.IsEqualString(result.Groups),
+		}
+		if details != nil {
+			resp.XDetail, resp.YDetail = &details[0], &details[1]
+		}
+		return resp
+	}
+	return ValuesResponse{
+		Response: response.OK(),
+		Values:   result.Values,
+		IsEqual:  floatcalculation.IsEqualString(result.Groups),
+		Groups:   codec.IntLists(result.Groups),
+		Details:  details,
+	}
+}
+
+// приведение запроса любого формата к списку значений для вычисления
+func (req *Request) Operands() []floatcalculation.Operand {
+	if len(req.Values) == 0 {
+		return []floatcalculation.Operand{
+			{Factors: []decimal.Decimal{req.X1, req.X3}, Divisors: []decimal.Decimal{req.X2}},
+			{Factors: []decimal.Decimal{req.Y1, req.Y3}, Divisors: []decimal.Decimal{req.Y2}},
+		}
+	}
+	operands := make([]floatcalculation.Operand, 0, len(req.Values))
+	for _, value := range req.Values {
+		operands = append(operands, floatcalculation.Operand{
+			Factors:  value.Factors,
+			Divisors: value.Divisors,
 		})
-		log.Info("Результаты отправлены.")
+	}
+	return operands
+}
+
+// ответ на ошибку в расчётах со статусом 422, прерванные вычисления получают статус 503
+func RenderCalculationError(w http.ResponseWriter, r *http.Request, log *slog.Logger, err error) {
+	lang := i18n.FromContext(r.Context())
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		log.Warn("Превышено время вычислений.")
+		response.RenderError(w, r, http.StatusServiceUnavailable, response.Error(response.CodeComputeTimeout, i18n.T(lang, i18n.ComputeTimeout)))
+	case errors.Is(err, context.Canceled):
+		log.Warn("Вычисления прерваны.")
+		response.RenderError(w, r, http.StatusServiceUnavailable, response.Error(response.CodeCanceled, i18n.T(lang, i18n.Canceled)))
+	default:
+		log.Error("Ошибка в расчётах.", slog.String("error", err.Error()))
+		code := response.CodeCalculationError
+		switch {
+		case errors.Is(err, floatcalculation.ErrDivisionByZero):
+			code = response.CodeDivisionByZero
+		case errors.Is(err, floatcalculation.ErrNonPositivePrecision):
+			code = response.CodeInvalidPrecision
+		case errors.Is(err, floatcalculation.ErrNumberTooLarge):
+			code = response.CodeNumberTooLarge
+		}
+		response.RenderError(w, r, http.StatusUnprocessableEntity, response.Error(code, i18n.Message(lang, err)))
 	}
 }
 
-func DereferenceToString(p *int32) string {
-	if p != nil {
-		return strconv.FormatInt(int64(*p), 10)
+// режим точности с учётом значения по умолчанию
+func (req *Request) Mode() floatcalculation.PrecisionMode {
+	if req.PrecisionMode == "" {
+		return floatcalculation.PrecisionPlaces
+	}
+	return floatcalculation.PrecisionMode(req.PrecisionMode)
+}
+
+// перевод точных значений в формат ответа
+func NewDetails(exact []floatcalculation.Detail) []Detail {
+	details := make([]Detail, 0, len(exact))
+	for _, detail := range exact {
+		details = append(details, Detail{
+			Numerator:     detail.Exact.Num().String(),
+			Denominator:   detail.Exact.Denom().String(),
+			Rounded:       detail.Rounded,
+			RoundingError: detail.RoundingError.RatString(),
+		})
 	}
-	return "nil"
+	return details
 }
@@ -1,17 +1,29 @@
 package handlefloatcalculation
 
 import (
+	"FloatService/codec"
+	"FloatService/config"
+	"FloatService/floatcalculation"
 	"FloatService/handlers/handlefloatcalculation/mocks"
+	"FloatService/i18n"
+	"FloatService/middleware/concurrency"
 	"FloatService/nulllogger"
+	"FloatService/response"
+	"FloatService/validation"
 	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
 	"log/slog"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
@@ -21,6 +33,8 @@ func TestHanleFloatCalculation(t *testing.T) {
 		input     string
 		respError string
 		mockError error
+		status    int
+		code      string
 	}{
 		{
 			name: "Успех",
@@ -29,55 +43,137 @@ func TestHanleFloatCalculation(t *testing.T) {
 		{
 			name:      "Деление на нуль",
 			respError: "деление на нуль",
-			mockError: errors.New("деление на нуль"),
+			mockError: floatcalculation.ErrDivisionByZero,
+			status:    http.StatusUnprocessableEntity,
+			code:      response.CodeDivisionByZero,
+		},
+
+		{
+			name:      "Слишком большие операнды",
+			respError: "превышен допустимый размер числа",
+			mockError: floatcalculation.ErrNumberTooLarge,
+			status:    http.StatusUnprocessableEntity,
+			code:      response.CodeNumberTooLarge,
+		},
+
+		{
+			name:      "Паника при вычислении",
+			respError: "не удалось выполнить вычисление",
+			mockError: floatcalculation.ErrCalculationFailed,
+			status:    http.StatusUnprocessableEntity,
+			code:      response.CodeCalculationError,
+		},
+
+		{
+			name:      "Превышено время вычислений",
+			respError: "Превышено время вычислений.",
+			mockError: context.DeadlineExceeded,
+			status:    http.StatusServiceUnavailable,
+			code:      response.CodeComputeTimeout,
+		},
+
+		{
+			name:      "Вычисления прерваны",
+			respError: "Вычисления прерваны.",
+			mockError: context.Canceled,
+			status:    http.StatusServiceUnavailable,
+			code:      response.CodeCanceled,
 		},
 
 		{
 			name:      "Некорректный запрос",
 			input:     "}{",
 			respError: "Ошибка декодирования запроса.",
+			status:    http.StatusBadRequest,
+			code:      response.CodeInvalidJSON,
 		},
 
 		{
 			name:      "Ошибка валидации: нет X1",
 			input:     `{"X2":"2", "X3":"3","Y1":"1","Y2":"2","Y3":"3","E":5}`,
 			respError: "Некорректный запрос",
+			status:    http.StatusBadRequest,
+			code:      response.CodeValidationFailed,
 		},
 
 		{
 			name:      "Ошибка валидации: нет X2",
 			input:     `{"X1":"1", "X3":"3","Y1":"1","Y2":"2","Y3":"3","E":5}`,
 			respError: "Некорректный запрос",
+			status:    http.StatusBadRequest,
+			code:      response.CodeValidationFailed,
 		},
 
 		{
 			name:      "Ошибка валидации: нет X3",
 			input:     `{"X1":"1", "X2":"2","Y1":"1","Y2":"2","Y3":"3","E":5}`,
 			respError: "Некорректный запрос",
+			status:    http.StatusBadRequest,
+			code:      response.CodeValidationFailed,
 		},
 
 		{
 			name:      "Ошибка валидации: нет Y1",
 			input:     `{"X1":"1", "X2":"2", "X3":"3","Y2":"2","Y3":"3","E":5}`,
 			respError: "Некорректный запрос",
+			status:    http.StatusBadRequest,
+			code:      response.CodeValidationFailed,
 		},
 
 		{
 			name:      "Ошибка валидации: нет Y2",
 			input:     `{"X1":"1", "X2":"2", "X3":"3","Y1":"1","Y3":"3","E":5}`,
 			respError: "Некорректный запрос",
+			status:    http.StatusBadRequest,
+			code:      response.CodeValidationFailed,
 		},
 
 		{
 			name:      "Ошибка валидации: нет Y3",
 			input:     `{"X1":"1", "X2":"2", "X3":"3","Y1":"1","Y2":"2","E":5}`,
 			respError: "Некорректный запрос",
+			status:    http.StatusBadRequest,
+			code:      response.CodeValidationFailed,
 		},
 
 		{
 			name:      "Ошибка валидации: нет E",
 			input:     `{"X1":"1", "X2":"2", "X3":"3","Y1":"1","Y2":"2","Y3":"3"}`,
 			respError: "Некорректный запрос",
+			status:    http.StatusBadRequest,
+			code:      response.CodeValidationFailed,
+		},
+
+		{
+			name:      "Ошибка валидации: X1..Y3 вместе с Values",
+			input:     `{"X1":"1", "X2":"2", "X3":"3","Y1":"1","Y2":"2","Y3":"3","E":5,"Values":[{"Factors":["1"]},{"Factors":["1"]}]}`,
+			respError: "Некорректный запрос",
+			status:    http.StatusBadRequest,
+			code:      response.CodeValidationFailed,
+		},
+
+		{
+			name:      "Ошибка валидации: неизвестный режим точности",
+			input:     `{"X1":"1", "X2":"2", "X3":"3","Y1":"1","Y2":"2","Y3":"3","E":5,"precision_mode":"digits"}`,
+			respError: "Некорректный запрос",
+			status:    http.StatusBadRequest,
+			code:      response.CodeValidationFailed,
+		},
+
+		{
+			name:      "Ошибка валидации: одно значение в Values",
+			input:     `{"Values":[{"Factors":["1"]}],"E":5}`,
+			respError: "Некорректный запрос",
+			status:    http.StatusBadRequest,
+			code:      response.CodeValidationFailed,
+		},
+
+		{
+			name:      "Ошибка валидации: значение без множителей",
+			input:     `{"Values":[{"Factors":["1"]},{"Divisors":["2"]}],"E":5}`,
+			respError: "Некорректный запрос",
+			status:    http.StatusBadRequest,
+			code:      response.CodeValidationFailed,
 		},
 	}
 	for _, test_case := range cases {
@@ -86,19 +182,29 @@ func TestHanleFloatCalculation(t *testing.T) {
 			t.Parallel()
 			calculatorMock := mocks.NewFloatCalculatorInt(t)
 			if test_case.respError == "" || test_case.mockError != nil {
+				calculatorMock.On("Check", mock.Anything).Return(nil).Once()
 				calculatorMock.On(
-					"FloatCalculation",
-					decimal.New(1, 0), decimal.New(2, 0), decimal.New(3, 0),
-					decimal.New(1, 0), decimal.New(2, 0), decimal.New(3, 0),
+					"Calculate",
+					mock.Anything,
+					[]floatcalculation.Operand{
+						{
+							Factors:  []decimal.Decimal{decimal.New(1, 0), decimal.New(3, 0)},
+							Divisors: []decimal.Decimal{decimal.New(2, 0)},
+						},
+						{
+							Factors:  []decimal.Decimal{decimal.New(1, 0), decimal.New(3, 0)},
+							Divisors: []decimal.Decimal{decimal.New(2, 0)},
+						},
+					},
 					int32(5),
+					floatcalculation.PrecisionPlaces,
 				).Return(
-					decimal.New(5, -1),
-					decimal.New(5, -1),
-					"T",
+					[]decimal.Decimal{decimal.New(5, -1), decimal.New(5, -1)},
+					[][]int{{0, 1}},
 					test_case.mockError,
 				).Once()
 			}
-			handler := New(slog.New(&nulllogger.NullLogger{}), calculatorMock)
+			handler := New(slog.New(&nulllogger.NullLogger{}), calculatorMock, 0)
 			input := `{"X1":"1", "X2":"2", "X3":"3","Y1":"1","Y2":"2","Y3":"3","E":5}`
 			if test_case.input != "" {
 				input = test_case.input
@@ -107,11 +213,472 @@ func TestHanleFloatCalculation(t *testing.T) {
 			require.NoError(t, err)
 			rr := httptest.NewRecorder()
 			handler.ServeHTTP(rr, req)
-			require.Equal(t, rr.Code, http.StatusOK)
+			status := http.StatusOK
+			if test_case.status != 0 {
+				status = test_case.status
+			}
+			require.Equal(t, status, rr.Code)
 			body := rr.Body.String()
 			var resp Response
 			require.NoError(t, json.Unmarshal([]byte(body), &resp))
 			require.Equal(t, test_case.respError, resp.Error)
+			require.Equal(t, test_case.code, resp.Code)
+			if test_case.code == response.CodeValidationFailed {
+				require.NotEmpty(t, resp.Fields)
+			}
 		})
 	}
 }
+
+func TestHanleFloatCalculation_Values(t *testing.T) {
+	calculatorMock := mocks.NewFloatCalculatorInt(t)
+	calculatorMock.On("Check", mock.Anything).Return(nil).Once()
+	calculatorMock.On(
+		"Calculate",
+		mock.Anything,
+		[]floatcalculation.Operand{
+			{Factors: []decimal.Decimal{decimal.New(1, 0), decimal.New(3, 0)}, Divisors: []decimal.Decimal{decimal.New(2, 0)}},
+			{Factors: []decimal.Decimal{decimal.New(3, 0)}, Divisors: []decimal.Decimal{decimal.New(2, 0)}},
+			{Factors: []decimal.Decimal{decimal.New(2, 0)}},
+		},
+		int32(5),
+		floatcalculation.PrecisionPlaces,
+	).Return(
+		[]decimal.Decimal{decimal.New(15, -1), decimal.New(15, -1), decimal.New(2, 0)},
+		[][]int{{0, 1}, {2}},
+		nil,
+	).Once()
+	handler := New(slog.New(&nulllogger.NullLogger{}), calculatorMock, 0)
+	input := `{"Values":[{"Factors":["1","3"],"Divisors":["2"]},{"Factors":["3"],"Divisors":["2"]},{"Factors":["2"]}],"E":5}`
+	req, err := http.NewRequest(http.MethodGet, "/", bytes.NewReader([]byte(input)))
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, rr.Code, http.StatusOK)
+	require.JSONEq(t, `{"status":"OK","Values":["1.5","1.5","2"],"IsEqual":"F","Groups":[[0,1],[2]]}`, rr.Body.String())
+}
+
+func TestHanleFloatCalculation_Detail(t *testing.T) {
+	operands := []floatcalculation.Operand{
+		{Factors: []decimal.Decimal{decimal.New(1, 0), decimal.New(1, 0)}, Divisors: []decimal.Decimal{decimal.New(3, 0)}},
+		{Factors: []decimal.Decimal{decimal.New(1, 0), decimal.New(1, 0)}, Divisors: []decimal.Decimal{decimal.New(3, 0)}},
+	}
+	values := []decimal.Decimal{decimal.New(33, -2), decimal.New(33, -2)}
+	calculatorMock := mocks.NewFloatCalculatorInt(t)
+	calculatorMock.On("Check", mock.Anything).Return(nil).Once()
+	calculatorMock.On("Calculate", mock.Anything, operands, int32(2), floatcalculation.PrecisionPlaces).Return(values, [][]int{{0, 1}}, nil).Once()
+	calculatorMock.On("Details", mock.Anything, operands, values).Return(
+		[]floatcalculation.Detail{
+			{Exact: big.NewRat(1, 3), Rounded: true, RoundingError: big.NewRat(-1, 300)},
+			{Exact: big.NewRat(1, 3), Rounded: true, RoundingError: big.NewRat(-1, 300)},
+		},
+		nil,
+	).Once()
+	handler := New(slog.New(&nulllogger.NullLogger{}), calculatorMock, 0)
+	input := `{"X1":"1", "X2":"3", "X3":"1","Y1":"1","Y2":"3","Y3":"1","E":2,"detail":true}`
+	req, err := http.NewRequest(http.MethodGet, "/", bytes.NewReader([]byte(input)))
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, rr.Code, http.StatusOK)
+	detail := `{"Numerator":"1","Denominator":"3","Rounded":true,"RoundingError":"-1/300"}`
+	require.JSONEq(t, `{"status":"OK","X":"0.33","Y":"0.33","IsEqual":"T","XDetail":`+detail+`,"YDetail":`+detail+`}`, rr.Body.String())
+}
+
+func TestHanleFloatCalculation_Significant(t *testing.T) {
+	calculatorMock := mocks.NewFloatCalculatorInt(t)
+	calculatorMock.On("Check", mock.Anything).Return(nil).Once()
+	calculatorMock.On(
+		"Calculate",
+		mock.Anything,
+		[]floatcalculation.Operand{
+			{Factors: []decimal.Decimal{decimal.New(602, 21), decimal.New(1, 0)}, Divisors: []decimal.Decimal{decimal.New(3, 0)}},
+			{Factors: []decimal.Decimal{decimal.New(2, 23), decimal.New(1, 0)}, Divisors: []decimal.Decimal{decimal.New(1, 0)}},
+		},
+		int32(3),
+		floatcalculation.PrecisionSignificant,
+	).Return(
+		[]decimal.Decimal{decimal.New(201, 21), decimal.New(200, 21)},
+		[][]int{{0}, {1}},
+		nil,
+	).Once()
+	handler := New(slog.New(&nulllogger.NullLogger{}), calculatorMock, 0)
+	input := `{"X1":"6.02e23", "X2":"3", "X3":"1","Y1":"2e23","Y2":"1","Y3":"1","E":3,"precision_mode":"significant"}`
+	req, err := http.NewRequest(http.MethodGet, "/", bytes.NewReader([]byte(input)))
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, rr.Code, http.StatusOK)
+	var resp Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Equal(t, "", resp.Error)
+	require.Equal(t, "F", resp.IsEqual)
+}
+
+// слишком большие операнды отклоняются до вычислений
+func TestHanleFloatCalculation_NumberTooLarge(t *testing.T) {
+	calculatorMock := mocks.NewFloatCalculatorInt(t)
+	calculatorMock.On("Check", mock.Anything).Return(floatcalculation.ErrNumberTooLarge).Once()
+	handler := New(slog.New(&nulllogger.NullLogger{}), calculatorMock, time.Minute)
+	input := `{"X1":"1e2000", "X2":"2", "X3":"1e2000","Y1":"1","Y2":"2","Y3":"3","E":5}`
+	req, err := http.NewRequest(http.MethodGet, "/", bytes.NewReader([]byte(input)))
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	var resp Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Equal(t, response.CodeNumberTooLarge, resp.Code)
+}
+
+/*
+После ответа по истечении времени вычислений деление досчитывается в фоне,
+и место в ограничителе одновременных вычислений остаётся занятым до его окончания.
+*/
+func TestHanleFloatCalculation_ComputeTimeoutHoldsSlot(t *testing.T) {
+	limiter := concurrency.New(slog.New(&nulllogger.NullLogger{}), config.Concurrency{MaxInFlight: 1, QueueTimeout: time.Second, RetryAfter: time.Second})
+	handler := limiter.Middleware(New(slog.New(&nulllogger.NullLogger{}), &floatcalculation.FloatCalculator{}, time.Millisecond))
+	input := `{"X1":"1", "X2":"1e-2000000", "X3":"1","Y1":"1","Y2":"1","Y3":"1","E":5}`
+	req, err := http.NewRequest(http.MethodPost, "/", strings.NewReader(input))
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	require.Equal(t, 1, limiter.Stats().InFlight)
+	require.Eventually(t, func() bool { return limiter.Stats().InFlight == 0 }, 10*time.Second, time.Millisecond)
+}
+
+// проверяем, что вычислениям передаётся контекст с ограничением времени
+func TestHanleFloatCalculation_ComputeTimeout(t *testing.T) {
+	calculatorMock := mocks.NewFloatCalculatorInt(t)
+	calculatorMock.On("Check", mock.Anything).Return(nil).Once()
+	calculatorMock.On(
+		"Calculate",
+		mock.MatchedBy(func(ctx context.Context) bool {
+			deadline, ok := ctx.Deadline()
+			return ok && time.Until(deadline) <= time.Minute
+		}),
+		mock.Anything, int32(5), floatcalculation.PrecisionPlaces,
+	).Return(nil, nil, context.DeadlineExceeded).Once()
+	handler := New(slog.New(&nulllogger.NullLogger{}), calculatorMock, time.Minute)
+	input := `{"X1":"1", "X2":"2", "X3":"3","Y1":"1","Y2":"2","Y3":"3","E":5}`
+	req, err := http.NewRequest(http.MethodGet, "/", bytes.NewReader([]byte(input)))
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+}
+
+func TestHanleFloatCalculation_ValidationFields(t *testing.T) {
+	handler := New(slog.New(&nulllogger.NullLogger{}), mocks.NewFloatCalculatorInt(t), 0)
+	input := `{"X2":"2", "X3":"3","Y1":"1","Y2":"2","Y3":"3","precision_mode":"digits"}`
+	req, err := http.NewRequest(http.MethodGet, "/", bytes.NewReader([]byte(input)))
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	var resp Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Equal(t, []response.FieldError{
+		{Field: "X1", Rule: "required_without", Param: "Values", Message: "Поле обязательно, если не указано Values."},
+		{Field: "E", Rule: "required", Message: "Поле обязательно."},
+		{Field: "precision_mode", Rule: "oneof", Param: "places significant", Message: "Допустимые значения: places, significant."},
+	}, resp.Fields)
+}
+
+// деление на нуль и недопустимая точность обнаруживаются при валидации, а не при вычислениях
+func TestHanleFloatCalculation_ValidationRules(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  string
+		lang   string
+		fields []response.FieldError
+	}{
+		{
+			name:  "X2 равен нулю",
+			input: `{"X1":"1", "X2":"0", "X3":"3","Y1":"1","Y2":"2","Y3":"3","E":5}`,
+			fields: []response.FieldError{
+				{Field: "X2", Rule: "nonzero", Message: "Значение не должно быть равно нулю."},
+			},
+		},
+		{
+			name:  "X2 и Y2 равны нулю, английский язык",
+			input: `{"X1":"1", "X2":"0", "X3":"3","Y1":"1","Y2":"0.000","Y3":"3","E":5}`,
+			lang:  "en",
+			fields: []response.FieldError{
+				{Field: "X2", Rule: "nonzero", Message: "Value must not be zero."},
+				{Field: "Y2", Rule: "nonzero", Message: "Value must not be zero."},
+			},
+		},
+		{
+			name:  "Слишком большая точность",
+			input: `{"X1":"1", "X2":"2", "X3":"3","Y1":"1","Y2":"2","Y3":"3","E":1001}`,
+			fields: []response.FieldError{
+				{Field: "E", Rule: "max", Param: "1000", Message: "Значение должно быть не больше 1000."},
+			},
+		},
+		{
+			name:  "Слишком маленькая точность",
+			input: `{"X1":"1", "X2":"2", "X3":"3","Y1":"1","Y2":"2","Y3":"3","E":-1001}`,
+			fields: []response.FieldError{
+				{Field: "E", Rule: "min", Param: "-1000", Message: "Значение должно быть не меньше -1000."},
+			},
+		},
+		{
+			name:  "Мало значений",
+			input: `{"Values":[{"Factors":["1"],"Divisors":["2"]}],"E":5}`,
+			fields: []response.FieldError{
+				{Field: "Values", Rule: "min", Param: "2", Message: "Количество элементов должно быть не меньше 2."},
+			},
+		},
+		{
+			name:  "Нулевой делитель",
+			input: `{"Values":[{"Factors":["1"],"Divisors":["2","0"]},{"Factors":["1"]}],"E":5}`,
+			fields: []response.FieldError{
+				{Field: "Values[0].Divisors[1]", Rule: "nonzero", Message: "Значение не должно быть равно нулю."},
+			},
+		},
+		{
+			name:  "Нет множителей",
+			input: `{"Values":[{"Factors":[]},{"Factors":["1"]}],"E":5}`,
+			fields: []response.FieldError{
+				{Field: "Values[0].Factors", Rule: "min", Param: "1", Message: "Количество элементов должно быть не меньше 1."},
+			},
+		},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			handler := i18n.New(i18n.RU)(New(slog.New(&nulllogger.NullLogger{}), mocks.NewFloatCalculatorInt(t), 0))
+			req, err := http.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(test_case.input)))
+			require.NoError(t, err)
+			req.Header.Set("Accept-Language", test_case.lang)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			require.Equal(t, http.StatusBadRequest, rr.Code)
+			var resp Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.Equal(t, response.CodeValidationFailed, resp.Code)
+			require.Equal(t, test_case.fields, resp.Fields)
+		})
+	}
+}
+
+func TestHanleFloatCalculation_ProblemJSON(t *testing.T) {
+	calculatorMock := mocks.NewFloatCalculatorInt(t)
+	calculatorMock.On("Check", mock.Anything).Return(nil).Once()
+	calculatorMock.On("Calculate", mock.Anything, mock.Anything, int32(5), floatcalculation.PrecisionPlaces).
+		Return(nil, nil, floatcalculation.ErrDivisionByZero).Once()
+	handler := New(slog.New(&nulllogger.NullLogger{}), calculatorMock, 0)
+	input := `{"X1":"1", "X2":"2", "X3":"3","Y1":"1","Y2":"2","Y3":"3","E":5}`
+	req, err := http.NewRequest(http.MethodGet, "/", bytes.NewReader([]byte(input)))
+	require.NoError(t, err)
+	req.Header.Set("Accept", response.ProblemContentType)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	require.Equal(t, response.ProblemContentType, rr.Header().Get("Content-Type"))
+	require.JSONEq(t, `{
+		"type":"urn:floatservice:error:DIVISION_BY_ZERO",
+		"title":"Unprocessable Entity",
+		"status":422,
+		"detail":"деление на нуль",
+		"instance":"/",
+		"code":"DIVISION_BY_ZERO"
+	}`, rr.Body.String())
+}
+
+// запрос с параметрами в строке запроса проходит ту же валидацию, что и JSON
+func TestHanleFloatCalculation_Query(t *testing.T) {
+	cases := []struct {
+		name     string
+		query    string
+		response string
+		status   int
+		mode     floatcalculation.PrecisionMode
+	}{
+		{
+			name:     "Успех",
+			query:    "X1=1&X2=2&X3=3&Y1=1&Y2=2&Y3=3&E=5",
+			response: `{"status":"OK","X":"1.5","Y":"1.5","IsEqual":"T"}`,
+			mode:     floatcalculation.PrecisionPlaces,
+		},
+		{
+			name:     "Значащие цифры",
+			query:    "X1=1&X2=2&X3=3&Y1=1&Y2=2&Y3=3&E=5&precision_mode=significant",
+			response: `{"status":"OK","X":"1.5","Y":"1.5","IsEqual":"T"}`,
+			mode:     floatcalculation.PrecisionSignificant,
+		},
+		{
+			name:     "Нет E",
+			query:    "X1=1&X2=2&X3=3&Y1=1&Y2=2&Y3=3",
+			response: `{"status":"Error","error":"Некорректный запрос","code":"VALIDATION_FAILED","fields":[{"field":"E","rule":"required","message":"Поле обязательно."}]}`,
+			status:   http.StatusBadRequest,
+		},
+		{
+			name:     "Некорректное число",
+			query:    "X1=abc&X2=2&X3=3&Y1=1&Y2=2&Y3=3&E=5",
+			response: `{"status":"Error","error":"Ошибка разбора параметров запроса.","code":"INVALID_QUERY"}`,
+			status:   http.StatusBadRequest,
+		},
+		{
+			name:     "Некорректная точность",
+			query:    "X1=1&X2=2&X3=3&Y1=1&Y2=2&Y3=3&E=1.5",
+			response: `{"status":"Error","error":"Ошибка разбора параметров запроса.","code":"INVALID_QUERY"}`,
+			status:   http.StatusBadRequest,
+		},
+		{
+			name:     "Некорректный detail",
+			query:    "X1=1&X2=2&X3=3&Y1=1&Y2=2&Y3=3&E=5&detail=maybe",
+			response: `{"status":"Error","error":"Ошибка разбора параметров запроса.","code":"INVALID_QUERY"}`,
+			status:   http.StatusBadRequest,
+		},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			calculatorMock := mocks.NewFloatCalculatorInt(t)
+			if test_case.mode != "" {
+				calculatorMock.On("Check", mock.Anything).Return(nil).Once()
+				calculatorMock.On(
+					"Calculate",
+					mock.Anything,
+					[]floatcalculation.Operand{
+						{Factors: []decimal.Decimal{decimal.New(1, 0), decimal.New(3, 0)}, Divisors: []decimal.Decimal{decimal.New(2, 0)}},
+						{Factors: []decimal.Decimal{decimal.New(1, 0), decimal.New(3, 0)}, Divisors: []decimal.Decimal{decimal.New(2, 0)}},
+					},
+					int32(5),
+					test_case.mode,
+				).Return(
+					[]decimal.Decimal{decimal.New(15, -1), decimal.New(15, -1)},
+					[][]int{{0, 1}},
+					nil,
+				).Once()
+			}
+			handler := NewQuery(slog.New(&nulllogger.NullLogger{}), calculatorMock, 0)
+			req, err := http.NewRequest(http.MethodGet, "/v1/calculate?"+test_case.query, nil)
+			require.NoError(t, err)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			status := http.StatusOK
+			if test_case.status != 0 {
+				status = test_case.status
+			}
+			require.Equal(t, status, rr.Code)
+			require.JSONEq(t, test_case.response, rr.Body.String())
+		})
+	}
+}
+
+// разбор строки запроса GET /v1/calculate: отсутствующие параметры остаются нулевыми
+func TestDecodeQuery(t *testing.T) {
+	var req Request
+	query := url.Values{}
+	query.Set("X1", "0.1")
+	query.Set("E", "0")
+	query.Set("detail", "true")
+	r := httptest.NewRequest(http.MethodGet, "/v1/calculate?"+query.Encode(), nil)
+	rr := httptest.NewRecorder()
+	require.True(t, decodeQuery(slog.New(&nulllogger.NullLogger{}), rr, r, &req))
+	require.Empty(t, rr.Body.String())
+	require.True(t, decimal.New(1, -1).Equal(req.X1))
+	require.True(t, req.X2.IsZero())
+	require.NotNil(t, req.E)
+	require.Equal(t, int32(0), *req.E)
+	require.True(t, req.Detail)
+	require.Equal(t, "", req.PrecisionMode)
+	require.Nil(t, req.Values)
+}
+
+// в строгом режиме опечатка в имени поля отклоняется, а не приводит к расчёту с нулём
+func TestHanleFloatCalculation_Strict(t *testing.T) {
+	cases := []struct {
+		name     string
+		target   string
+		input    string
+		response string
+		status   int
+	}{
+		{
+			name:     "Имя поля в нижнем регистре",
+			target:   "/",
+			input:    `{"x1":"1", "X2":"2", "X3":"3","Y1":"1","Y2":"2","Y3":"3","E":5}`,
+			response: `{"status":"Error","error":"Неизвестное поле x1.","code":"UNKNOWN_FIELD","fields":[{"field":"x1","rule":"unknown","message":"Неизвестное поле x1."}]}`,
+			status:   http.StatusBadRequest,
+		},
+		{
+			name:     "Повторяющееся поле",
+			target:   "/",
+			input:    `{"X1":"1", "X2":"2", "X3":"3","Y1":"1","Y2":"2","Y3":"3","E":5,"E":6}`,
+			response: `{"status":"Error","error":"Поле E указано несколько раз.","code":"DUPLICATE_FIELD","fields":[{"field":"E","rule":"duplicate","message":"Поле E указано несколько раз."}]}`,
+			status:   http.StatusBadRequest,
+		},
+		{
+			name:     "Неизвестный параметр строки запроса",
+			target:   "/?X1=1&X2=2&X3=3&Y1=1&Y2=2&Y3=3&E=5&Z=1",
+			response: `{"status":"Error","error":"Неизвестное поле Z.","code":"UNKNOWN_FIELD","fields":[{"field":"Z","rule":"unknown","message":"Неизвестное поле Z."}]}`,
+			status:   http.StatusBadRequest,
+		},
+		{
+			name:     "Слишком большое тело",
+			target:   "/",
+			input:    `{"X1":"1", "X2":"2", "X3":"3","Y1":"1","Y2":"2","Y3":"3","E":5,"precision_mode":"` + strings.Repeat("p", 200) + `"}`,
+			response: `{"status":"Error","error":"Тело запроса больше 128 байт.","code":"BODY_TOO_LARGE"}`,
+			status:   http.StatusRequestEntityTooLarge,
+		},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			log := slog.New(&nulllogger.NullLogger{})
+			handler := New(log, mocks.NewFloatCalculatorInt(t), 0)
+			if test_case.input == "" {
+				handler = NewQuery(log, mocks.NewFloatCalculatorInt(t), 0)
+			}
+			req, err := http.NewRequest(http.MethodPost, test_case.target, strings.NewReader(test_case.input))
+			require.NoError(t, err)
+			rr := httptest.NewRecorder()
+			codec.New(128, true)(handler).ServeHTTP(rr, req)
+			require.Equal(t, test_case.status, rr.Code)
+			require.JSONEq(t, test_case.response, rr.Body.String())
+		})
+	}
+}
+
+/*
+Сравнение создания валидатора на каждый запрос, как было раньше в обработчике,
+с общим валидатором, который обработчик создаёт один раз:
+go test -run=^$ -bench=Validate -benchmem ./handlers/handlefloatcalculation
+*/
+func BenchmarkValidate(b *testing.B) {
+	E := int32(5)
+	req := Request{
+		X1: decimal.New(1, 0), X2: decimal.New(2, 0), X3: decimal.New(3, 0),
+		Y1: decimal.New(1, 0), Y2: decimal.New(2, 0), Y3: decimal.New(3, 0),
+		E: &E,
+	}
+	b.Run("PerRequest", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if err := validation.New().Struct(req); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+	b.Run("Shared", func(b *testing.B) {
+		b.ReportAllocs()
+		validate := validation.Default()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if err := validate.Struct(req); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+}
@@ -3,6 +3,9 @@
 package mocks
 
 import (
+	floatcalculation "FloatService/floatcalculation"
+	context "context"
+
 	decimal "github.com/shopspring/decimal"
 
 	mock "github.com/stretchr/testify/mock"
@@ -13,42 +16,79 @@ type FloatCalculatorInt struct {
 	mock.Mock
 }
 
-// FloatCalculation provides a mock function with given fields: X1, X2, X3, Y1, Y2, Y3, E
-func (_m *FloatCalculatorInt) FloatCalculation(X1 decimal.Decimal, X2 decimal.Decimal, X3 decimal.Decimal, Y1 decimal.Decimal, Y2 decimal.Decimal, Y3 decimal.Decimal, E int32) (decimal.Decimal, decimal.Decimal, string, error) {
-	ret := _m.Called(X1, X2, X3, Y1, Y2, Y3, E)
+// Calculate provides a mock function with given fields: ctx, operands, E, mode
+func (_m *FloatCalculatorInt) Calculate(ctx context.Context, operands []floatcalculation.Operand, E int32, mode floatcalculation.PrecisionMode) ([]decimal.Decimal, [][]int, error) {
+	ret := _m.Called(ctx, operands, E, mode)
 
-	var r0 decimal.Decimal
-	var r1 decimal.Decimal
-	var r2 string
-	var r3 error
-	if rf, ok := ret.Get(0).(func(decimal.Decimal, decimal.Decimal, decimal.Decimal, decimal.Decimal, decimal.Decimal, decimal.Decimal, int32) (decimal.Decimal, decimal.Decimal, string, error)); ok {
-		return rf(X1, X2, X3, Y1, Y2, Y3, E)
+	var r0 []decimal.Decimal
+	var r1 [][]int
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, []floatcalculation.Operand, int32, floatcalculation.PrecisionMode) ([]decimal.Decimal, [][]int, error)); ok {
+		return rf(ctx, operands, E, mode)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []floatcalculation.Operand, int32, floatcalculation.PrecisionMode) []decimal.Decimal); ok {
+		r0 = rf(ctx, operands, E, mode)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]decimal.Decimal)
+		}
 	}
-	if rf, ok := ret.Get(0).(func(decimal.Decimal, decimal.Decimal, decimal.Decimal, decimal.Decimal, decimal.Decimal, decimal.Decimal, int32) decimal.Decimal); ok {
-		r0 = rf(X1, X2, X3, Y1, Y2, Y3, E)
+
+	if rf, ok := ret.Get(1).(func(context.Context, []floatcalculation.Operand, int32, floatcalculation.PrecisionMode) [][]int); ok {
+		r1 = rf(ctx, operands, E, mode)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([][]int)
+		}
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, []floatcalculation.Operand, int32, floatcalculation.PrecisionMode) error); ok {
+		r2 = rf(ctx, operands, E, mode)
 	} else {
-		r0 = ret.Get(0).(decimal.Decimal)
+		r2 = ret.Error(2)
 	}
 
-	if rf, ok := ret.Get(1).(func(decimal.Decimal, decimal.Decimal, decimal.Decimal, decimal.Decimal, decimal.Decimal, decimal.Decimal, int32) decimal.Decimal); ok {
-		r1 = rf(X1, X2, X3, Y1, Y2, Y3, E)
+	return r0, r1, r2
+}
+
+// Check provides a mock function with given fields: operands
+func (_m *FloatCalculatorInt) Check(operands []floatcalculation.Operand) error {
+	ret := _m.Called(operands)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]floatcalculation.Operand) error); ok {
+		r0 = rf(operands)
 	} else {
-		r1 = ret.Get(1).(decimal.Decimal)
+		r0 = ret.Error(0)
 	}
 
-	if rf, ok := ret.Get(2).(func(decimal.Decimal, decimal.Decimal, decimal.Decimal, decimal.Decimal, decimal.Decimal, decimal.Decimal, int32) string); ok {
-		r2 = rf(X1, X2, X3, Y1, Y2, Y3, E)
+	return r0
+}
+
+// Details provides a mock function with given fields: ctx, operands, values
+func (_m *FloatCalculatorInt) Details(ctx context.Context, operands []floatcalculation.Operand, values []decimal.Decimal) ([]floatcalculation.Detail, error) {
+	ret := _m.Called(ctx, operands, values)
+
+	var r0 []floatcalculation.Detail
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []floatcalculation.Operand, []decimal.Decimal) ([]floatcalculation.Detail, error)); ok {
+		return rf(ctx, operands, values)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []floatcalculation.Operand, []decimal.Decimal) []floatcalculation.Detail); ok {
+		r0 = rf(ctx, operands, values)
 	} else {
-		r2 = ret.Get(2).(string)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]floatcalculation.Detail)
+		}
 	}
 
-	if rf, ok := ret.Get(3).(func(decimal.Decimal, decimal.Decimal, decimal.Decimal, decimal.Decimal, decimal.Decimal, decimal.Decimal, int32) error); ok {
-		r3 = rf(X1, X2, X3, Y1, Y2, Y3, E)
+	if rf, ok := ret.Get(1).(func(context.Context, []floatcalculation.Operand, []decimal.Decimal) error); ok {
+		r1 = rf(ctx, operands, values)
 	} else {
-		r3 = ret.Error(3)
+		r1 = ret.Error(1)
 	}
 
-	return r0, r1, r2, r3
+	return r0, r1
 }
 
 type mockConstructorTestingTNewFloatCalculatorInt interface {
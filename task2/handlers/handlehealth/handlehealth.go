@@ -0,0 +1,45 @@
+package handlehealth
+
+import (
+	"FloatService/codec"
+	"FloatService/i18n"
+	"FloatService/response"
+	"net/http"
+	"sync/atomic"
+)
+
+/*
+Готовность сервиса принимать новые запросы. Снимается в начале остановки сервера,
+чтобы балансировщик перестал направлять запросы до закрытия соединений.
+*/
+type Readiness struct {
+	stopping atomic.Bool
+}
+
+// с этого момента /readyz отвечает 503
+func (r *Readiness) Stop() {
+	r.stopping.Store(true)
+}
+
+func (r *Readiness) Ready() bool {
+	return !r.stopping.Load()
+}
+
+// проверка живости: процесс запущен и обрабатывает запросы
+func New() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		codec.Render(w, r, http.StatusOK, response.OK())
+	}
+}
+
+// проверка готовности: 503, как только началась остановка сервера
+func NewReady(readiness *Readiness) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !readiness.Ready() {
+			msg := i18n.T(i18n.FromContext(r.Context()), i18n.ShuttingDown)
+			response.RenderError(w, r, http.StatusServiceUnavailable, response.Error(response.CodeShuttingDown, msg))
+			return
+		}
+		codec.Render(w, r, http.StatusOK, response.OK())
+	}
+}
@@ -0,0 +1,68 @@
+package handlehealth
+
+import (
+	"FloatService/response"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleHealth(t *testing.T) {
+	cases := []struct {
+		name     string
+		stopping bool
+		path     string
+		status   int
+		resp     response.Response
+	}{
+		{
+			name:   "Живость",
+			path:   "/healthz",
+			status: http.StatusOK,
+			resp:   response.OK(),
+		},
+		{
+			name:     "Живость при остановке",
+			stopping: true,
+			path:     "/healthz",
+			status:   http.StatusOK,
+			resp:     response.OK(),
+		},
+		{
+			name:   "Готовность",
+			path:   "/readyz",
+			status: http.StatusOK,
+			resp:   response.OK(),
+		},
+		{
+			name:     "Готовность при остановке",
+			stopping: true,
+			path:     "/readyz",
+			status:   http.StatusServiceUnavailable,
+			resp:     response.Error(response.CodeShuttingDown, "Сервер останавливается."),
+		},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			var readiness Readiness
+			if test_case.stopping {
+				readiness.Stop()
+			}
+			handler := New()
+			if test_case.path == "/readyz" {
+				handler = NewReady(&readiness)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, test_case.path, nil))
+			require.Equal(t, test_case.status, rr.Code)
+			var resp response.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.Equal(t, test_case.resp, resp)
+		})
+	}
+}
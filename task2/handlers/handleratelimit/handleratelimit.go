@@ -0,0 +1,192 @@
+package handleratelimit
+
+import (
+	"FloatService/codec"
+	"FloatService/i18n"
+	"FloatService/limiter"
+	"FloatService/middleware/ratelimit"
+	"FloatService/response"
+	"FloatService/validation"
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// фильтры списка ключей: тариф целиком и подстрока ключа
+type ListRequest struct {
+	Plan string `json:"plan"`
+	Key  string `json:"key"`
+}
+
+// ключ, лимит которого нужно сбросить; без тарифов plan пустой
+type ResetRequest struct {
+	Plan string `json:"plan"`
+	Key  string `json:"key" validate:"required"`
+}
+
+type Response struct {
+	response.Response
+	Keys []Key `json:"keys" xml:"key"`
+}
+
+/*
+Состояние ключа лимита: окна, израсходованные хотя бы частично,
+и количество отклонённых запросов с начала последней серии отказов.
+*/
+type Key struct {
+	Plan           string     `json:"plan" xml:"plan"`
+	Key            string     `json:"key" xml:"key"`
+	Windows        []Window   `json:"windows" xml:"window"`
+	Rejected       int        `json:"rejected" xml:"rejected"`
+	LastRejectedAt *time.Time `json:"last_rejected_at,omitempty" xml:"last_rejected_at,omitempty"`
+}
+
+// окно лимита: limit запросов за interval_seconds, из них осталось remaining до reset_at
+type Window struct {
+	IntervalSeconds float64   `json:"interval_seconds" xml:"interval_seconds"`
+	Limit           int       `json:"limit" xml:"limit"`
+	Remaining       int       `json:"remaining" xml:"remaining"`
+	ResetSeconds    int64     `json:"reset_seconds" xml:"reset_seconds"`
+	ResetAt         time.Time `json:"reset_at" xml:"reset_at"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=InspectorInt
+type InspectorInt interface {
+	Keys(ctx context.Context) (map[string][]limiter.KeyState, error)
+	Reset(ctx context.Context, plan, key string) error
+}
+
+// создание обработчика списка ключей лимита с параметрами plan и key в строке запроса
+func New(log *slog.Logger, inspector InspectorInt) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.handleratelimit.New"
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+		var req ListRequest
+		if !decodeQuery(log, w, r, &req) {
+			return
+		}
+		plans, err := inspector.Keys(r.Context())
+		if err != nil {
+			log.Error("Ошибка чтения состояния лимитов.", slog.String("error", err.Error()))
+			renderInternalError(w, r)
+			return
+		}
+		now := time.Now()
+		keys := []Key{}
+		for plan, states := range plans {
+			if req.Plan != "" && plan != req.Plan {
+				continue
+			}
+			for _, state := range states {
+				if strings.Contains(state.Key, req.Key) {
+					keys = append(keys, NewKey(plan, state, now))
+				}
+			}
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i].Plan != keys[j].Plan {
+				return keys[i].Plan < keys[j].Plan
+			}
+			return keys[i].Key < keys[j].Key
+		})
+		codec.Render(w, r, http.StatusOK, Response{
+			Response: response.OK(),
+			Keys:     keys,
+		})
+	}
+}
+
+// создание обработчика сброса лимита ключа с параметрами plan и key в строке запроса
+func NewReset(log *slog.Logger, inspector InspectorInt) http.HandlerFunc {
+	// валидатор общий для всех запросов, разбор тегов структур кэшируется в нём
+	validate := validation.Default()
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.handleratelimit.NewReset"
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+		lang := i18n.FromContext(r.Context())
+		var req ResetRequest
+		if !decodeQuery(log, w, r, &req) {
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			log.Error("Некорректный запрос.", slog.String("error", err.Error()))
+			response.RenderError(w, r, http.StatusBadRequest, response.ValidationError(i18n.T(lang, i18n.InvalidRequest), validation.Fields(lang, err)))
+			return
+		}
+		err := inspector.Reset(r.Context(), req.Plan, req.Key)
+		switch {
+		case errors.Is(err, ratelimit.ErrUnknownPlan):
+			log.Error("Ошибка сброса лимита ключа.", slog.String("error", err.Error()), slog.String("plan", req.Plan))
+			response.RenderError(w, r, http.StatusNotFound, response.Error(response.CodeUnknownPlan, i18n.T(lang, i18n.UnknownPlan, req.Plan)))
+			return
+		case err != nil:
+			log.Error("Ошибка сброса лимита ключа.", slog.String("error", err.Error()))
+			renderInternalError(w, r)
+			return
+		}
+		log.Info("Лимит ключа сброшен.", slog.String("plan", req.Plan), slog.String("key", req.Key))
+		codec.Render(w, r, http.StatusOK, response.OK())
+	}
+}
+
+// разбор строки запроса в req, при ошибке отправляет ответ и возвращает false
+func decodeQuery(log *slog.Logger, w http.ResponseWriter, r *http.Request, req any) bool {
+	query := r.URL.Query()
+	if codec.Strict(r.Context()) {
+		if err := codec.CheckValues(query, req); err != nil {
+			log.Error("Ошибка разбора параметров запроса.", slog.String("error", err.Error()))
+			response.RenderDecodeError(w, r, err)
+			return false
+		}
+	}
+	if err := codec.DecodeValues(query, req); err != nil {
+		log.Error("Ошибка разбора параметров запроса.", slog.String("error", err.Error()))
+		response.RenderError(w, r, http.StatusBadRequest, response.Error(
+			response.CodeInvalidQuery, i18n.T(i18n.FromContext(r.Context()), i18n.QueryDecodeError),
+		))
+		return false
+	}
+	return true
+}
+
+func renderInternalError(w http.ResponseWriter, r *http.Request) {
+	msg := i18n.T(i18n.FromContext(r.Context()), i18n.InternalError)
+	response.RenderError(w, r, http.StatusInternalServerError, response.Error(response.CodeInternalError, msg))
+}
+
+// перевод состояния ключа в формат ответа, время сброса отсчитывается от now
+func NewKey(plan string, state limiter.KeyState, now time.Time) Key {
+	key := Key{
+		Plan:     plan,
+		Key:      state.Key,
+		Windows:  make([]Window, 0, len(state.Windows)),
+		Rejected: state.Rejected,
+	}
+	for _, window := range state.Windows {
+		key.Windows = append(key.Windows, Window{
+			IntervalSeconds: window.Interval.Seconds(),
+			Limit:           window.Limit,
+			Remaining:       window.Remaining,
+			ResetSeconds:    int64(math.Ceil(window.ResetAfter.Seconds())),
+			ResetAt:         now.Add(window.ResetAfter).UTC().Round(time.Second),
+		})
+	}
+	if !state.LastRejected.IsZero() {
+		lastRejected := state.LastRejected.UTC()
+		key.LastRejectedAt = &lastRejected
+	}
+	return key
+}
@@ -0,0 +1,192 @@
+package handleratelimit
+
+import (
+	"FloatService/handlers/handleratelimit/mocks"
+	"FloatService/limiter"
+	"FloatService/middleware/ratelimit"
+	"FloatService/nulllogger"
+	"FloatService/response"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var lastRejected = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+var states = map[string][]limiter.KeyState{
+	"free": {
+		{
+			Key:          "api_key=a",
+			Windows:      []limiter.Window{{Interval: time.Minute, Limit: 10, Remaining: 0, ResetAfter: 1500 * time.Millisecond}},
+			Rejected:     3,
+			LastRejected: lastRejected,
+		},
+		{
+			Key:     "ip=10.0.0.1",
+			Windows: []limiter.Window{{Interval: time.Second, Limit: 2, Remaining: 1, ResetAfter: 500 * time.Millisecond}},
+		},
+	},
+	"pro": {
+		{
+			Key:     "api_key=b",
+			Windows: []limiter.Window{{Interval: 24 * time.Hour, Limit: 1000, Remaining: 999, ResetAfter: time.Hour}},
+		},
+	},
+}
+
+func TestHandleRateLimit(t *testing.T) {
+	cases := []struct {
+		name      string
+		query     string
+		mockError error
+		status    int
+		keys      []string
+	}{
+		{
+			name:   "Все ключи",
+			status: http.StatusOK,
+			keys:   []string{"free api_key=a", "free ip=10.0.0.1", "pro api_key=b"},
+		},
+		{
+			name:   "Фильтр по тарифу",
+			query:  "?plan=free",
+			status: http.StatusOK,
+			keys:   []string{"free api_key=a", "free ip=10.0.0.1"},
+		},
+		{
+			name:   "Фильтр по ключу",
+			query:  "?key=api_key",
+			status: http.StatusOK,
+			keys:   []string{"free api_key=a", "pro api_key=b"},
+		},
+		{
+			name:   "Нет подходящих ключей",
+			query:  "?plan=enterprise",
+			status: http.StatusOK,
+			keys:   []string{},
+		},
+		{
+			name:      "Ошибка хранилища",
+			mockError: errors.New("redis недоступен"),
+			status:    http.StatusInternalServerError,
+		},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			inspectorMock := mocks.NewInspectorInt(t)
+			if test_case.mockError != nil {
+				inspectorMock.On("Keys", mock.Anything).Return(nil, test_case.mockError).Once()
+			} else {
+				inspectorMock.On("Keys", mock.Anything).Return(states, nil).Once()
+			}
+			handler := New(slog.New(&nulllogger.NullLogger{}), inspectorMock)
+			req := httptest.NewRequest(http.MethodGet, "/admin/ratelimit/keys"+test_case.query, nil)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			require.Equal(t, test_case.status, rr.Code)
+			var resp Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			if test_case.mockError != nil {
+				require.Equal(t, response.CodeInternalError, resp.Code)
+				return
+			}
+			keys := make([]string, 0, len(resp.Keys))
+			for _, key := range resp.Keys {
+				keys = append(keys, key.Plan+" "+key.Key)
+			}
+			require.Equal(t, test_case.keys, keys)
+		})
+	}
+}
+
+func TestNewKey(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 10, 0, time.UTC)
+	key := NewKey("free", states["free"][0], now)
+	require.Equal(t, Key{
+		Plan: "free",
+		Key:  "api_key=a",
+		Windows: []Window{{
+			IntervalSeconds: 60,
+			Limit:           10,
+			Remaining:       0,
+			ResetSeconds:    2,
+			ResetAt:         now.Add(2 * time.Second),
+		}},
+		Rejected:       3,
+		LastRejectedAt: &lastRejected,
+	}, key)
+	key = NewKey("free", states["free"][1], now)
+	require.Nil(t, key.LastRejectedAt)
+}
+
+func TestHandleRateLimit_Reset(t *testing.T) {
+	cases := []struct {
+		name      string
+		query     string
+		mockError error
+		status    int
+		code      string
+	}{
+		{
+			name:   "Сброс ключа тарифа",
+			query:  "?plan=free&key=api_key%3Da",
+			status: http.StatusOK,
+		},
+		{
+			name:   "Сброс ключа без тарифов",
+			query:  "?key=ip%3D10.0.0.1",
+			status: http.StatusOK,
+		},
+		{
+			name:   "Нет ключа",
+			query:  "?plan=free",
+			status: http.StatusBadRequest,
+			code:   response.CodeValidationFailed,
+		},
+		{
+			name:      "Неизвестный тариф",
+			query:     "?plan=enterprise&key=api_key%3Da",
+			mockError: ratelimit.ErrUnknownPlan,
+			status:    http.StatusNotFound,
+			code:      response.CodeUnknownPlan,
+		},
+		{
+			name:      "Ошибка хранилища",
+			query:     "?plan=free&key=api_key%3Da",
+			mockError: errors.New("redis недоступен"),
+			status:    http.StatusInternalServerError,
+			code:      response.CodeInternalError,
+		},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			inspectorMock := mocks.NewInspectorInt(t)
+			req := httptest.NewRequest(http.MethodDelete, "/admin/ratelimit/keys"+test_case.query, nil)
+			query := req.URL.Query()
+			if query.Has("key") {
+				inspectorMock.On("Reset", mock.Anything, query.Get("plan"), query.Get("key")).Return(test_case.mockError).Once()
+			}
+			handler := NewReset(slog.New(&nulllogger.NullLogger{}), inspectorMock)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			require.Equal(t, test_case.status, rr.Code)
+			var resp response.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.Equal(t, test_case.code, resp.Code)
+			if test_case.code == response.CodeValidationFailed {
+				require.Equal(t, "key", resp.Fields[0].Field)
+			}
+		})
+	}
+}
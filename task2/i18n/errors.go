@@ -0,0 +1,47 @@
+package i18n
+
+import "errors"
+
+/*
+Ошибка, текст которой может быть переведён.
+Error() возвращает текст на языке Fallback,
+а errors.Is сравнивает ошибки по ключу сообщения без учёта аргументов.
+*/
+type Error struct {
+	Key  Key
+	Args []any
+}
+
+func NewError(key Key, args ...any) *Error {
+	return &Error{Key: key, Args: args}
+}
+
+func (e *Error) Error() string {
+	return e.Localize(Fallback)
+}
+
+func (e *Error) Localize(lang string) string {
+	args := make([]any, 0, len(e.Args))
+	for _, arg := range e.Args {
+		// вложенные переводимые ошибки переводятся на тот же язык
+		if nested, ok := arg.(*Error); ok {
+			arg = nested.Localize(lang)
+		}
+		args = append(args, arg)
+	}
+	return T(lang, e.Key, args...)
+}
+
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Key == e.Key
+}
+
+// текст ошибки на языке lang, если её можно перевести
+func Message(lang string, err error) string {
+	var localized interface{ Localize(lang string) string }
+	if errors.As(err, &localized) {
+		return localized.Localize(lang)
+	}
+	return err.Error()
+}
@@ -0,0 +1,268 @@
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	RU = "ru"
+	EN = "en"
+)
+
+// язык, используемый, если перевод сообщения не найден
+const Fallback = RU
+
+type Key string
+
+// сообщения, отправляемые клиентам
+const (
+	DecodeError          Key = "decode_error"
+	QueryDecodeError     Key = "query_decode_error"
+	UnsupportedMediaType Key = "unsupported_media_type"
+	BodyTooLarge         Key = "body_too_large"
+	UnknownField         Key = "unknown_field"
+	DuplicateField       Key = "duplicate_field"
+	InvalidRequest       Key = "invalid_request"
+	ComputeTimeout       Key = "compute_timeout"
+	Canceled             Key = "canceled"
+	RateLimited          Key = "rate_limited"
+	Overloaded           Key = "overloaded"
+	Forbidden            Key = "forbidden"
+	Unauthorized         Key = "unauthorized"
+	InternalError        Key = "internal_error"
+	UnknownPlan          Key = "unknown_plan"
+	ShuttingDown         Key = "shutting_down"
+	DivisionByZero       Key = "division_by_zero"
+	NonPositivePrecision Key = "non_positive_precision"
+	OperandsMismatch     Key = "operands_mismatch"
+	CalculationFailed    Key = "calculation_failed"
+	SyntaxError          Key = "syntax_error"
+	TooDeep              Key = "too_deep"
+	TooManyOperations    Key = "too_many_operations"
+	TooLong              Key = "too_long"
+	NumberTooLarge       Key = "number_too_large"
+	UnknownVariable      Key = "unknown_variable"
+	UnknownOperation     Key = "unknown_operation"
+	InvalidNumber        Key = "invalid_number"
+	UnexpectedChar       Key = "unexpected_char"
+	UnexpectedToken      Key = "unexpected_token"
+	ExpectedRParen       Key = "expected_rparen"
+	UnexpectedEnd        Key = "unexpected_end"
+	UnknownFunction      Key = "unknown_function"
+	TakesOneArg          Key = "takes_one_arg"
+	TakesTwoArgs         Key = "takes_two_args"
+	MinArgCount          Key = "min_arg_count"
+	RoundNotInteger      Key = "round_not_integer"
+	RoundTooPrecise      Key = "round_too_precise"
+	RoundOutOfRange      Key = "round_out_of_range"
+)
+
+// пояснения к нарушенным правилам валидации
+const (
+	RuleRequired        Key = "rule_required"
+	RuleRequiredWithout Key = "rule_required_without"
+	RuleExcludedWith    Key = "rule_excluded_with"
+	RuleMin             Key = "rule_min"
+	RuleMax             Key = "rule_max"
+	RuleMinItems        Key = "rule_min_items"
+	RuleMaxItems        Key = "rule_max_items"
+	RuleMinLength       Key = "rule_min_length"
+	RuleMaxLength       Key = "rule_max_length"
+	RuleOneOf           Key = "rule_oneof"
+	RuleNonZero         Key = "rule_nonzero"
+	RuleOther           Key = "rule_other"
+)
+
+var catalog = map[string]map[Key]string{
+	RU: {
+		DecodeError:          "Ошибка декодирования запроса.",
+		QueryDecodeError:     "Ошибка разбора параметров запроса.",
+		UnsupportedMediaType: "Неподдерживаемый формат тела запроса.",
+		BodyTooLarge:         "Тело запроса больше %d байт.",
+		UnknownField:         "Неизвестное поле %s.",
+		DuplicateField:       "Поле %s указано несколько раз.",
+		InvalidRequest:       "Некорректный запрос",
+		ComputeTimeout:       "Превышено время вычислений.",
+		Canceled:             "Вычисления прерваны.",
+		RateLimited:          "Слишком много запросов.",
+		Overloaded:           "Сервер перегружен, повторите запрос позже.",
+		Forbidden:            "Доступ запрещён.",
+		Unauthorized:         "Требуется токен администратора.",
+		InternalError:        "Внутренняя ошибка сервера.",
+		UnknownPlan:          "Неизвестный тариф %q.",
+		ShuttingDown:         "Сервер останавливается.",
+		DivisionByZero:       "деление на нуль",
+		NonPositivePrecision: "в режиме significant точность E должна быть положительной",
+		OperandsMismatch:     "количество значений не совпадает с количеством операндов",
+		CalculationFailed:    "не удалось выполнить вычисление",
+		SyntaxError:          "синтаксическая ошибка в позиции %d: %s",
+		TooDeep:              "превышена максимальная глубина вложенности выражения",
+		TooManyOperations:    "превышено максимальное количество операций",
+		TooLong:              "превышена максимальная длина выражения",
+		NumberTooLarge:       "превышен допустимый размер числа",
+		UnknownVariable:      "неизвестная переменная %q",
+		UnknownOperation:     "неизвестная операция %q",
+		InvalidNumber:        "некорректное число %q",
+		UnexpectedChar:       "неожиданный символ %q",
+		UnexpectedToken:      "неожиданная лексема %q",
+		ExpectedRParen:       "ожидалась \")\"",
+		UnexpectedEnd:        "неожиданный конец выражения",
+		UnknownFunction:      "неизвестная функция %q",
+		TakesOneArg:          "%s принимает 1 аргумент",
+		TakesTwoArgs:         "%s принимает 2 аргумента",
+		MinArgCount:          "%s принимает хотя бы 1 аргумент",
+		RoundNotInteger:      "точность в round должна быть целым числом",
+		RoundTooPrecise:      "точность в round должна быть не больше %d по модулю",
+		RoundOutOfRange:      "слишком большая точность в round",
+		RuleRequired:         "Поле обязательно.",
+		RuleRequiredWithout:  "Поле обязательно, если не указано %s.",
+		RuleExcludedWith:     "Поле нельзя указывать вместе с %s.",
+		RuleMin:              "Значение должно быть не меньше %s.",
+		RuleMax:              "Значение должно быть не больше %s.",
+		RuleMinItems:         "Количество элементов должно быть не меньше %s.",
+		RuleMaxItems:         "Количество элементов должно быть не больше %s.",
+		RuleMinLength:        "Длина должна быть не меньше %s.",
+		RuleMaxLength:        "Длина должна быть не больше %s.",
+		RuleOneOf:            "Допустимые значения: %s.",
+		RuleNonZero:          "Значение не должно быть равно нулю.",
+		RuleOther:            "Нарушено правило %s.",
+	},
+	EN: {
+		DecodeError:          "Failed to decode request.",
+		QueryDecodeError:     "Failed to parse query parameters.",
+		UnsupportedMediaType: "Unsupported request body format.",
+		BodyTooLarge:         "Request body exceeds %d bytes.",
+		UnknownField:         "Unknown field %s.",
+		DuplicateField:       "Field %s is specified more than once.",
+		InvalidRequest:       "Invalid request",
+		ComputeTimeout:       "Computation time exceeded.",
+		Canceled:             "Computation canceled.",
+		RateLimited:          "Too many requests.",
+		Overloaded:           "Server is overloaded, try again later.",
+		Forbidden:            "Access denied.",
+		Unauthorized:         "Administrator token required.",
+		InternalError:        "Internal server error.",
+		UnknownPlan:          "Unknown plan %q.",
+		ShuttingDown:         "Server is shutting down.",
+		DivisionByZero:       "division by zero",
+		NonPositivePrecision: "precision E must be positive in significant mode",
+		OperandsMismatch:     "number of values does not match number of operands",
+		CalculationFailed:    "calculation failed",
+		SyntaxError:          "syntax error at position %d: %s",
+		TooDeep:              "maximum expression nesting depth exceeded",
+		TooManyOperations:    "maximum number of operations exceeded",
+		TooLong:              "maximum expression length exceeded",
+		NumberTooLarge:       "maximum number size exceeded",
+		UnknownVariable:      "unknown variable %q",
+		UnknownOperation:     "unknown operation %q",
+		InvalidNumber:        "invalid number %q",
+		UnexpectedChar:       "unexpected character %q",
+		UnexpectedToken:      "unexpected token %q",
+		ExpectedRParen:       "expected \")\"",
+		UnexpectedEnd:        "unexpected end of expression",
+		UnknownFunction:      "unknown function %q",
+		TakesOneArg:          "%s takes 1 argument",
+		TakesTwoArgs:         "%s takes 2 arguments",
+		MinArgCount:          "%s takes at least 1 argument",
+		RoundNotInteger:      "round precision must be an integer",
+		RoundTooPrecise:      "round precision must not exceed %d in absolute value",
+		RoundOutOfRange:      "round precision is too large",
+		RuleRequired:         "Field is required.",
+		RuleRequiredWithout:  "Field is required when %s is not specified.",
+		RuleExcludedWith:     "Field must not be specified together with %s.",
+		RuleMin:              "Value must be at least %s.",
+		RuleMax:              "Value must be at most %s.",
+		RuleMinItems:         "Must contain at least %s items.",
+		RuleMaxItems:         "Must contain at most %s items.",
+		RuleMinLength:        "Length must be at least %s.",
+		RuleMaxLength:        "Length must be at most %s.",
+		RuleOneOf:            "Allowed values: %s.",
+		RuleNonZero:          "Value must not be zero.",
+		RuleOther:            "Rule %s is violated.",
+	},
+}
+
+// поддерживается ли язык
+func Supported(lang string) bool {
+	_, ok := catalog[lang]
+	return ok
+}
+
+// перевод сообщения key на язык lang с подстановкой аргументов
+func T(lang string, key Key, args ...any) string {
+	format, ok := catalog[lang][key]
+	if !ok {
+		format, ok = catalog[Fallback][key]
+	}
+	if !ok {
+		format = string(key)
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+/*
+Выбор языка по заголовку Accept-Language:
+из поддерживаемых языков берётся язык с наибольшим весом q,
+при его отсутствии - def.
+*/
+func Negotiate(acceptLanguage string, def string) string {
+	type candidate struct {
+		lang string
+		q    float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		// en-US -> en
+		lang := strings.ToLower(strings.SplitN(strings.TrimSpace(fields[0]), "-", 2)[0])
+		if !Supported(lang) {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{lang: lang, q: q})
+		}
+	}
+	if len(candidates) == 0 {
+		return def
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].lang
+}
+
+type langCtxKey struct{}
+
+// middleware, выбирающий язык ответа по заголовку Accept-Language
+func New(def string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lang := Negotiate(r.Header.Get("Accept-Language"), def)
+			w.Header().Set("Content-Language", lang)
+			w.Header().Add("Vary", "Accept-Language")
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), langCtxKey{}, lang)))
+		})
+	}
+}
+
+// язык ответа на запрос
+func FromContext(ctx context.Context) string {
+	if lang, ok := ctx.Value(langCtxKey{}).(string); ok {
+		return lang
+	}
+	return Fallback
+}
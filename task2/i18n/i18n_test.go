@@ -0,0 +1,125 @@
+package i18n
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiate(t *testing.T) {
+	cases := []struct {
+		name           string
+		acceptLanguage string
+		def            string
+		lang           string
+	}{
+		{name: "Пустой заголовок", acceptLanguage: "", def: RU, lang: RU},
+		{name: "Пустой заголовок, английский по умолчанию", acceptLanguage: "", def: EN, lang: EN},
+		{name: "Английский", acceptLanguage: "en", def: RU, lang: EN},
+		{name: "Регион", acceptLanguage: "en-GB", def: RU, lang: EN},
+		{name: "Вес", acceptLanguage: "ru;q=0.5, en;q=0.8", def: RU, lang: EN},
+		{name: "Неподдерживаемый язык пропускается", acceptLanguage: "de, en;q=0.1", def: RU, lang: EN},
+		{name: "Только неподдерживаемые языки", acceptLanguage: "de, fr", def: RU, lang: RU},
+		{name: "Нулевой вес", acceptLanguage: "en;q=0", def: RU, lang: RU},
+		{name: "Любой язык", acceptLanguage: "*", def: EN, lang: EN},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test_case.lang, Negotiate(test_case.acceptLanguage, test_case.def))
+		})
+	}
+}
+
+func TestT(t *testing.T) {
+	assert.Equal(t, "деление на нуль", T(RU, DivisionByZero))
+	assert.Equal(t, "division by zero", T(EN, DivisionByZero))
+	assert.Equal(t, `unknown variable "z"`, T(EN, UnknownVariable, "z"))
+	// неизвестный язык - язык по умолчанию
+	assert.Equal(t, "деление на нуль", T("de", DivisionByZero))
+	// неизвестный ключ возвращается как есть
+	assert.Equal(t, "no_such_key", T(EN, Key("no_such_key")))
+}
+
+func TestError(t *testing.T) {
+	err := NewError(UnknownVariable, "z")
+	assert.EqualError(t, err, `неизвестная переменная "z"`)
+	assert.Equal(t, `unknown variable "z"`, err.Localize(EN))
+	// сравнение по ключу без учёта аргументов
+	assert.ErrorIs(t, err, NewError(UnknownVariable, ""))
+	assert.NotErrorIs(t, err, NewError(DivisionByZero))
+	wrapped := fmt.Errorf("обёртка: %w", err)
+	assert.Equal(t, `unknown variable "z"`, Message(EN, wrapped))
+	assert.Equal(t, "обычная ошибка", Message(EN, errors.New("обычная ошибка")))
+	// вложенные ошибки переводятся на тот же язык
+	nested := NewError(SyntaxError, 3, NewError(UnexpectedEnd))
+	assert.Equal(t, "syntax error at position 3: unexpected end of expression", nested.Localize(EN))
+}
+
+func TestMiddleware(t *testing.T) {
+	var lang string
+	handler := New(EN)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lang = FromContext(r.Context())
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Language", "ru-RU,ru;q=0.9,en;q=0.8")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, RU, lang)
+	require.Equal(t, RU, rr.Header().Get("Content-Language"))
+	require.Equal(t, "Accept-Language", rr.Header().Get("Vary"))
+	// без middleware - язык по умолчанию
+	require.Equal(t, Fallback, FromContext(context.Background()))
+}
+
+func TestLogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewLogHandler(slog.NewTextHandler(&buf, nil), EN)).With(slog.String("op", "test"))
+	log.Info("Сервер запущен", slog.String("address", "localhost"))
+	assert.Contains(t, buf.String(), `msg="Server started"`)
+	assert.Contains(t, buf.String(), "op=test")
+	assert.Contains(t, buf.String(), "address=localhost")
+	buf.Reset()
+	log.Info("неизвестное сообщение")
+	assert.Contains(t, buf.String(), `msg="неизвестное сообщение"`)
+	assert.Equal(t, "Запрос обработан.", LogMessage(RU, "request completed"))
+}
+
+// все сообщения логов сервиса есть в каталоге, иначе они не переводятся
+func TestLogMessages_Catalog(t *testing.T) {
+	call := regexp.MustCompile(`\blog\.(?:Debug|Info|Warn|Error)\(\s*"([^"]+)"`)
+	err := filepath.WalkDir("..", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && (d.Name() == "tests" || d.Name() == "mocks") {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, match := range call.FindAllStringSubmatch(string(src), -1) {
+			assert.Contains(t, logIndex, match[1], path)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+}
@@ -0,0 +1,114 @@
+package i18n
+
+import (
+	"context"
+	"log/slog"
+)
+
+// сообщения логов на русском и английском
+var logMessages = []map[string]string{
+	{RU: "Запуск FloatService", EN: "Starting FloatService"},
+	{RU: "Логгирование запущено на уровне DEBUG.", EN: "Logging started at DEBUG level."},
+	{RU: "Запускаем сервер.", EN: "Starting server."},
+	{RU: "Сервер запущен", EN: "Server started"},
+	{RU: "Ошибка сервера.", EN: "Server error."},
+	{RU: "Остановка сервера.", EN: "Stopping server."},
+	{RU: "Ошибка остановки сервера", EN: "Server stop error"},
+	{RU: "Сервер остановлен.", EN: "Server stopped."},
+	{RU: "Чтение запроса.", EN: "Reading request."},
+	{RU: "Ошибка декодирования тела запроса.", EN: "Failed to decode request body."},
+	{RU: "Декодировано тело запроса.", EN: "Request body decoded."},
+	{RU: "Ошибка разбора параметров запроса.", EN: "Failed to parse query parameters."},
+	{RU: "Валидация запроса.", EN: "Validating request."},
+	{RU: "Некорректный запрос.", EN: "Invalid request."},
+	{RU: "Валидация запроса прошла успешно.", EN: "Request validated successfully."},
+	{RU: "Начинаем расчёты.", EN: "Starting calculation."},
+	{RU: "Ошибка в расчётах.", EN: "Calculation error."},
+	{RU: "Вычисляем точные значения.", EN: "Calculating exact values."},
+	{RU: "Расчёты окончены.", EN: "Calculation finished."},
+	{RU: "Начинаем вычисление выражения.", EN: "Starting expression evaluation."},
+	{RU: "Ошибка в вычислении выражения.", EN: "Expression evaluation error."},
+	{RU: "Вычисление окончено.", EN: "Evaluation finished."},
+	{RU: "Превышено время вычислений.", EN: "Computation time exceeded."},
+	{RU: "Вычисления прерваны.", EN: "Computation canceled."},
+	{RU: "Отправляем ответ.", EN: "Sending response."},
+	{RU: "Результаты отправлены.", EN: "Results sent."},
+	{RU: "Достигнут лимит запросов.", EN: "Rate limit reached."},
+	{RU: "Включено логгирование запросов.", EN: "logger middleware enabled"},
+	{RU: "Запрос обработан.", EN: "request completed"},
+	{RU: "Включено ограничение количества запросов.", EN: "rate limit middleware enabled"},
+	{RU: "Ограничение количества запросов отключено.", EN: "rate limit middleware disabled"},
+	{RU: "Ограничение количества запросов отклоняет все запросы.", EN: "rate limit middleware denies all requests"},
+	{RU: "Включено ограничение одновременных вычислений.", EN: "concurrency limit middleware enabled"},
+	{RU: "Некорректный список доверенных прокси.", EN: "Invalid trusted proxy list."},
+	{RU: "Ошибка проверки лимита запросов.", EN: "Rate limit check failed."},
+	{RU: "Хранилище лимитов недоступно, используется локальный лимит.", EN: "Rate limit store is unavailable, falling back to local limiting."},
+	{RU: "Хранилище лимитов снова доступно.", EN: "Rate limit store is available again."},
+	{RU: "Ошибка записи квот в файл.", EN: "Failed to write quotas to file."},
+	{RU: "Ошибка открытия хранилища квот.", EN: "Failed to open quota store."},
+	{RU: "Очередь вычислений заполнена, запрос отклонён.", EN: "Calculation queue is full, request rejected."},
+	{RU: "Превышено время ожидания в очереди вычислений, запрос отклонён.", EN: "Calculation queue wait timed out, request rejected."},
+	{RU: "Запрос дождался своей очереди вычислений.", EN: "Request left the calculation queue."},
+	{RU: "Ошибка в списках адресов.", EN: "Invalid IP filter lists."},
+	{RU: "Списки адресов обновлены.", EN: "IP filter lists updated."},
+	{RU: "Ошибка перечитывания файла конфигурации.", EN: "Failed to reload config file."},
+	{RU: "Запрос без действительного токена администратора.", EN: "Request without a valid administrator token."},
+	{RU: "Ошибка чтения состояния лимитов.", EN: "Failed to read rate limit state."},
+	{RU: "Ошибка сброса лимита ключа.", EN: "Failed to reset rate limit key."},
+	{RU: "Лимит ключа сброшен.", EN: "Rate limit key reset."},
+	{RU: "Ожидание перед остановкой сервера.", EN: "Waiting before stopping the server."},
+	{RU: "Включены прежние HTTP статусы ошибок.", EN: "legacy status codes middleware enabled"},
+	{RU: "Версия API помечена как устаревшая.", EN: "deprecation middleware enabled"},
+	{RU: "Вызвана устаревшая версия API.", EN: "Deprecated API version called."},
+	{RU: "Маршруты не описаны в спецификации OpenAPI.", EN: "Routes are missing from the OpenAPI specification."},
+}
+
+// сообщение лога на любом из языков -> его переводы
+var logIndex = func() map[string]map[string]string {
+	index := make(map[string]map[string]string)
+	for _, translations := range logMessages {
+		for _, msg := range translations {
+			index[msg] = translations
+		}
+	}
+	return index
+}()
+
+// перевод сообщения лога на язык lang, неизвестные сообщения не меняются
+func LogMessage(lang string, msg string) string {
+	if translated, ok := logIndex[msg][lang]; ok {
+		return translated
+	}
+	return msg
+}
+
+// обёртка над slog.Handler, переводящая сообщения логов на язык lang
+type LogHandler struct {
+	handler slog.Handler
+	lang    string
+}
+
+func NewLogHandler(handler slog.Handler, lang string) *LogHandler {
+	return &LogHandler{handler: handler, lang: lang}
+}
+
+func (h *LogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *LogHandler) Handle(ctx context.Context, r slog.Record) error {
+	translated := slog.NewRecord(r.Time, r.Level, LogMessage(h.lang, r.Message), r.PC)
+	r.Attrs(func(attr slog.Attr) bool {
+		translated.AddAttrs(attr)
+		return true
+	})
+	return h.handler.Handle(ctx, translated)
+}
+
+func (h *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return NewLogHandler(h.handler.WithAttrs(attrs), h.lang)
+}
+
+func (h *LogHandler) WithGroup(name string) slog.Handler {
+	return NewLogHandler(h.handler.WithGroup(name), h.lang)
+}
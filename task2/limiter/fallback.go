@@ -0,0 +1,112 @@
+package limiter
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+/*
+Ограничитель, который проверяет запросы в primary (например, Redis),
+а при его ошибке - в local. После ошибки primary не опрашивается retryInterval,
+чтобы недоступное хранилище не задерживало каждый запрос.
+В логи пишется только переход между хранилищами, а не каждая ошибка.
+*/
+type Fallback struct {
+	log           *slog.Logger
+	primary       Limiter
+	local         Limiter
+	retryInterval time.Duration
+	clock         Clock
+
+	mu        sync.Mutex
+	downUntil time.Time
+	down      bool
+}
+
+func NewFallback(log *slog.Logger, primary, local Limiter, retryInterval time.Duration, clock Clock) *Fallback {
+	return &Fallback{
+		log: log.With(
+			slog.String("component", "limiter/fallback"),
+		),
+		primary:       primary,
+		local:         local,
+		retryInterval: retryInterval,
+		clock:         clock,
+	}
+}
+
+func (f *Fallback) Allow(ctx context.Context, key string, cost int) (Result, error) {
+	if f.usePrimary() {
+		res, err := f.primary.Allow(ctx, key, cost)
+		if err == nil {
+			f.markUp()
+			return res, nil
+		}
+		// запрос отменён клиентом, хранилище тут ни при чём
+		if ctx.Err() != nil {
+			return Result{}, err
+		}
+		f.markDown(err)
+	}
+	return f.local.Allow(ctx, key, cost)
+}
+
+// доступно ли основное хранилище или пора проверить его снова
+func (f *Fallback) usePrimary() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return !f.down || !f.clock.Now().Before(f.downUntil)
+}
+
+func (f *Fallback) markUp() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		f.down = false
+		f.log.Info("Хранилище лимитов снова доступно.")
+	}
+}
+
+func (f *Fallback) markDown(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.down {
+		f.log.Error("Хранилище лимитов недоступно, используется локальный лимит.", slog.String("error", err.Error()))
+	}
+	f.down = true
+	f.downUntil = f.clock.Now().Add(f.retryInterval)
+}
+
+// состояния ключей из primary, а если он недоступен - из local
+func (f *Fallback) Keys(ctx context.Context) ([]KeyState, error) {
+	if primary, ok := f.primary.(Inspector); ok && f.usePrimary() {
+		states, err := primary.Keys(ctx)
+		if err == nil {
+			f.markUp()
+			return states, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		f.markDown(err)
+	}
+	if local, ok := f.local.(Inspector); ok {
+		return local.Keys(ctx)
+	}
+	return nil, nil
+}
+
+// сброс ключа в обоих хранилищах, чтобы он не вернулся при переходе между ними
+func (f *Fallback) Reset(ctx context.Context, key string) error {
+	if local, ok := f.local.(Inspector); ok {
+		if err := local.Reset(ctx, key); err != nil {
+			return err
+		}
+	}
+	if primary, ok := f.primary.(Inspector); ok {
+		return primary.Reset(ctx, key)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package limiter
+
+import (
+	"FloatService/nulllogger"
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// при недоступном хранилище действует локальный лимит, а после восстановления - снова общий
+func TestFallback(t *testing.T) {
+	server, client := newTestRedis(t)
+	clock := NewManualClock(start)
+	primary := NewRedis(client, "test:", 2, time.Minute, 0, clock)
+	local := NewGCRA(1, time.Minute, 0, clock)
+	f := NewFallback(slog.New(&nulllogger.NullLogger{}), primary, local, 5*time.Second, clock)
+	allow := func() bool {
+		res, err := f.Allow(context.Background(), "key", 1)
+		require.NoError(t, err)
+		return res.Allowed
+	}
+	require.True(t, allow())
+	require.True(t, server.Exists("test:key"))
+
+	server.Close()
+	require.True(t, allow())
+	require.False(t, allow())
+
+	// до конца retryInterval хранилище не опрашивается, даже если оно уже доступно
+	require.NoError(t, server.Restart())
+	clock.Advance(5*time.Second - time.Nanosecond)
+	require.False(t, allow())
+	tat, err := server.Get("test:key")
+	require.NoError(t, err)
+
+	// в общем хранилище остался один запрос из двух
+	clock.Advance(time.Nanosecond)
+	require.True(t, allow())
+	require.False(t, allow())
+	newTat, err := server.Get("test:key")
+	require.NoError(t, err)
+	require.NotEqual(t, tat, newTat)
+}
+
+// отмена запроса клиентом не переключает на локальный лимит
+func TestFallback_Canceled(t *testing.T) {
+	_, client := newTestRedis(t)
+	clock := NewManualClock(start)
+	f := NewFallback(slog.New(&nulllogger.NullLogger{}), NewRedis(client, "test:", 1, time.Minute, 0, clock), NewGCRA(1, time.Minute, 0, clock), time.Minute, clock)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := f.Allow(ctx, "key", 1)
+	require.Error(t, err)
+	require.False(t, f.down)
+}
+
+// состояние берётся из доступного хранилища, а сбрасывается в обоих
+func TestFallback_Inspect(t *testing.T) {
+	server, client := newTestRedis(t)
+	clock := NewManualClock(start)
+	primary := NewRedis(client, "test:", 2, time.Minute, 0, clock)
+	local := NewGCRA(1, time.Minute, 0, clock)
+	f := NewFallback(slog.New(&nulllogger.NullLogger{}), primary, local, 5*time.Second, clock)
+	ctx := context.Background()
+	_, err := f.Allow(ctx, "key", 1)
+	require.NoError(t, err)
+	keys, err := f.Keys(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []KeyState{{Key: "key", Windows: []Window{{Interval: time.Minute, Limit: 2, Remaining: 1, ResetAfter: 30 * time.Second}}}}, keys)
+
+	server.Close()
+	keys, err = f.Keys(ctx)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+	_, err = f.Allow(ctx, "key", 1)
+	require.NoError(t, err)
+	keys, err = f.Keys(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []KeyState{{Key: "key", Windows: []Window{{Interval: time.Minute, Limit: 1, Remaining: 0, ResetAfter: time.Minute}}}}, keys)
+
+	require.NoError(t, server.Restart())
+	require.NoError(t, f.Reset(ctx, "key"))
+	require.False(t, server.Exists("test:key"))
+	keys, err = local.Keys(ctx)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+}
@@ -0,0 +1,99 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+/*
+Ограничитель запросов по алгоритму GCRA (generic cell rate algorithm),
+эквивалентному корзине токенов ёмкостью burst, которая пополняется
+на limit токенов за interval. Для каждого ключа хранится только
+теоретическое время прибытия (TAT) следующего запроса, поэтому, в отличие
+от скользящего окна, лимит соблюдается точно, а не приблизительно.
+*/
+type GCRA struct {
+	rate
+	clock Clock
+
+	mu        sync.Mutex
+	tat       map[string]time.Time
+	lastSweep time.Time
+}
+
+// ограничитель в памяти процесса со скоростью limit запросов за interval и burst запросами подряд (см. newRate)
+func NewGCRA(limit int, interval time.Duration, burst int, clock Clock) *GCRA {
+	return &GCRA{
+		rate:      newRate(limit, interval, burst, time.Nanosecond),
+		clock:     clock,
+		tat:       make(map[string]time.Time),
+		lastSweep: clock.Now(),
+	}
+}
+
+// проверка запроса с весом cost для ключа key, пропущенный запрос расходует лимит
+func (g *GCRA) Allow(_ context.Context, key string, cost int) (Result, error) {
+	now := g.clock.Now()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.sweep(now)
+	tat := g.tat[key]
+	if tat.Before(now) {
+		tat = now
+	}
+	newTat := tat.Add(g.emission * time.Duration(cost))
+	allowAt := newTat.Add(-g.tolerance)
+	if now.Before(allowAt) {
+		return Result{
+			Limit:      g.burst,
+			Remaining:  g.remaining(tat.Sub(now)),
+			RetryAfter: allowAt.Sub(now),
+			ResetAfter: tat.Sub(now),
+		}, nil
+	}
+	g.tat[key] = newTat
+	return Result{
+		Allowed:    true,
+		Limit:      g.burst,
+		Remaining:  g.remaining(newTat.Sub(now)),
+		ResetAfter: newTat.Sub(now),
+	}, nil
+}
+
+/*
+Удаление ключей, лимит которых полностью восстановился: они ничем не отличаются от новых.
+Выполняется не чаще, чем лимит восстанавливается с нуля, чтобы не перебирать ключи на каждый запрос.
+*/
+func (g *GCRA) sweep(now time.Time) {
+	if now.Sub(g.lastSweep) < g.tolerance {
+		return
+	}
+	for key, tat := range g.tat {
+		if !tat.After(now) {
+			delete(g.tat, key)
+		}
+	}
+	g.lastSweep = now
+}
+
+// ключи, лимит которых ещё не восстановился полностью
+func (g *GCRA) Keys(_ context.Context) ([]KeyState, error) {
+	now := g.clock.Now()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	states := make([]KeyState, 0, len(g.tat))
+	for key, tat := range g.tat {
+		if tat.After(now) {
+			states = append(states, KeyState{Key: key, Windows: []Window{g.window(tat.Sub(now))}})
+		}
+	}
+	return mergeKeys(states), nil
+}
+
+func (g *GCRA) Reset(_ context.Context, key string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.tat, key)
+	return nil
+}
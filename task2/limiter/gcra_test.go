@@ -0,0 +1,229 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestGCRA_Burst(t *testing.T) {
+	cases := []struct {
+		name       string
+		limit      int
+		interval   time.Duration
+		burst      int
+		allowed    int
+		retryAfter time.Duration
+	}{
+		{
+			name:       "Burst равен лимиту",
+			limit:      50,
+			interval:   time.Second,
+			allowed:    50,
+			retryAfter: 20 * time.Millisecond,
+		},
+		{
+			name:       "Burst больше лимита",
+			limit:      2,
+			interval:   time.Minute,
+			burst:      5,
+			allowed:    5,
+			retryAfter: 30 * time.Second,
+		},
+		{
+			name:       "Burst меньше лимита",
+			limit:      100,
+			interval:   time.Second,
+			burst:      1,
+			allowed:    1,
+			retryAfter: 10 * time.Millisecond,
+		},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			clock := NewManualClock(start)
+			g := NewGCRA(test_case.limit, test_case.interval, test_case.burst, clock)
+			for i := 0; i < test_case.allowed; i++ {
+				res, err := g.Allow(context.Background(), "key", 1)
+				require.NoError(t, err)
+				require.True(t, res.Allowed, "запрос %d", i)
+				require.Equal(t, test_case.allowed, res.Limit)
+				require.Equal(t, test_case.allowed-i-1, res.Remaining)
+			}
+			res, err := g.Allow(context.Background(), "key", 1)
+			require.NoError(t, err)
+			require.False(t, res.Allowed)
+			require.Equal(t, 0, res.Remaining)
+			require.Equal(t, test_case.retryAfter, res.RetryAfter)
+			// ровно через RetryAfter запрос пропускается, а на наносекунду раньше - нет
+			clock.Advance(res.RetryAfter - time.Nanosecond)
+			res, err = g.Allow(context.Background(), "key", 1)
+			require.NoError(t, err)
+			require.False(t, res.Allowed)
+			clock.Advance(time.Nanosecond)
+			res, err = g.Allow(context.Background(), "key", 1)
+			require.NoError(t, err)
+			require.True(t, res.Allowed)
+		})
+	}
+}
+
+// при постоянной нагрузке за intervals интервалов включительно пропускается ровно burst + intervals*limit запросов
+func TestGCRA_SustainedRate(t *testing.T) {
+	cases := []struct {
+		name     string
+		limit    int
+		interval time.Duration
+		burst    int
+		step     time.Duration
+	}{
+		{
+			name:     "100 запросов в секунду",
+			limit:    100,
+			interval: time.Second,
+			step:     time.Millisecond,
+		},
+		{
+			name:     "50 запросов в секунду с burst 10",
+			limit:    50,
+			interval: time.Second,
+			burst:    10,
+			step:     100 * time.Microsecond,
+		},
+		{
+			name:     "3 запроса в минуту",
+			limit:    3,
+			interval: time.Minute,
+			step:     time.Second,
+		},
+	}
+	const intervals = 10
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			clock := NewManualClock(start)
+			g := NewGCRA(test_case.limit, test_case.interval, test_case.burst, clock)
+			burst := test_case.burst
+			if burst == 0 {
+				burst = test_case.limit
+			}
+			allowed := 0
+			for elapsed := time.Duration(0); elapsed <= intervals*test_case.interval; elapsed += test_case.step {
+				res, err := g.Allow(context.Background(), "key", 1)
+				require.NoError(t, err)
+				if res.Allowed {
+					allowed++
+				}
+				clock.Advance(test_case.step)
+			}
+			require.Equal(t, burst+intervals*test_case.limit, allowed)
+		})
+	}
+}
+
+// после паузы лимит восстанавливается не больше чем до burst
+func TestGCRA_Recovery(t *testing.T) {
+	clock := NewManualClock(start)
+	g := NewGCRA(10, time.Second, 0, clock)
+	for i := 0; i < 10; i++ {
+		res, _ := g.Allow(context.Background(), "key", 1)
+		require.True(t, res.Allowed)
+	}
+	clock.Advance(300 * time.Millisecond)
+	res, _ := g.Allow(context.Background(), "key", 1)
+	require.True(t, res.Allowed)
+	require.Equal(t, 2, res.Remaining)
+	require.Equal(t, 800*time.Millisecond, res.ResetAfter)
+	clock.Advance(time.Hour)
+	res, _ = g.Allow(context.Background(), "key", 1)
+	require.True(t, res.Allowed)
+	require.Equal(t, 9, res.Remaining)
+	require.Equal(t, 100*time.Millisecond, res.ResetAfter)
+}
+
+func TestGCRA_Keys(t *testing.T) {
+	clock := NewManualClock(start)
+	g := NewGCRA(1, time.Minute, 0, clock)
+	res, _ := g.Allow(context.Background(), "a", 1)
+	require.True(t, res.Allowed)
+	res, _ = g.Allow(context.Background(), "b", 1)
+	require.True(t, res.Allowed)
+	res, _ = g.Allow(context.Background(), "a", 1)
+	require.False(t, res.Allowed)
+}
+
+// интервал между запросами меньше наносекунды не округляется до нуля
+func TestGCRA_TinyEmission(t *testing.T) {
+	g := NewGCRA(2_000_000_000, time.Second, 0, NewManualClock(start))
+	res, err := g.Allow(context.Background(), "key", 1)
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+	require.Equal(t, 2_000_000_000-1, res.Remaining)
+}
+
+// запрос с весом cost расходует cost запросов
+func TestGCRA_Cost(t *testing.T) {
+	clock := NewManualClock(start)
+	g := NewGCRA(10, time.Second, 0, clock)
+	res, _ := g.Allow(context.Background(), "key", 7)
+	require.True(t, res.Allowed)
+	require.Equal(t, 3, res.Remaining)
+	res, _ = g.Allow(context.Background(), "key", 4)
+	require.False(t, res.Allowed)
+	require.Equal(t, 3, res.Remaining)
+	require.Equal(t, 100*time.Millisecond, res.RetryAfter)
+	res, _ = g.Allow(context.Background(), "key", 3)
+	require.True(t, res.Allowed)
+	require.Equal(t, 0, res.Remaining)
+}
+
+// ключи с восстановленным лимитом удаляются
+func TestGCRA_Sweep(t *testing.T) {
+	clock := NewManualClock(start)
+	g := NewGCRA(2, time.Second, 0, clock)
+	_, _ = g.Allow(context.Background(), "a", 1)
+	_, _ = g.Allow(context.Background(), "b", 2)
+	require.Len(t, g.tat, 2)
+	clock.Advance(time.Second)
+	_, _ = g.Allow(context.Background(), "c", 1)
+	require.Len(t, g.tat, 1)
+	require.Contains(t, g.tat, "c")
+}
+
+func TestGCRA_Inspect(t *testing.T) {
+	clock := NewManualClock(start)
+	g := NewGCRA(2, time.Minute, 0, clock)
+	ctx := context.Background()
+	_, err := g.Allow(ctx, "b", 2)
+	require.NoError(t, err)
+	_, err = g.Allow(ctx, "a", 1)
+	require.NoError(t, err)
+	keys, err := g.Keys(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []KeyState{
+		{Key: "a", Windows: []Window{{Interval: time.Minute, Limit: 2, Remaining: 1, ResetAfter: 30 * time.Second}}},
+		{Key: "b", Windows: []Window{{Interval: time.Minute, Limit: 2, Remaining: 0, ResetAfter: time.Minute}}},
+	}, keys)
+	// ключ с восстановленным лимитом не показывается
+	clock.Advance(30 * time.Second)
+	keys, err = g.Keys(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []KeyState{
+		{Key: "b", Windows: []Window{{Interval: time.Minute, Limit: 2, Remaining: 1, ResetAfter: 30 * time.Second}}},
+	}, keys)
+	// после сброса ключу снова доступен весь лимит
+	require.NoError(t, g.Reset(ctx, "b"))
+	keys, err = g.Keys(ctx)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+	res, err := g.Allow(ctx, "b", 2)
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+}
@@ -0,0 +1,153 @@
+package limiter
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+Состояние одного лимита ключа: Interval - за сколько восстанавливается Limit запросов
+(сутки для дневной квоты), Remaining и ResetAfter - как в Result.
+*/
+type Window struct {
+	Interval   time.Duration
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration
+}
+
+/*
+Состояние ключа: лимиты, которые он израсходовал хотя бы частично,
+и отказы, которые учитывает Rejections.
+*/
+type KeyState struct {
+	Key          string
+	Windows      []Window
+	Rejected     int
+	LastRejected time.Time
+}
+
+/*
+Ограничитель, состояние которого можно просмотреть и сбросить.
+Keys возвращает ключи, отсортированные по имени, без ключей с полностью восстановленным лимитом.
+Reset возвращает ключу весь лимит.
+*/
+type Inspector interface {
+	Keys(ctx context.Context) ([]KeyState, error)
+	Reset(ctx context.Context, key string) error
+}
+
+// объединение состояний одних и тех же ключей из разных ограничителей, результат отсортирован по ключу
+func mergeKeys(states []KeyState) []KeyState {
+	index := make(map[string]int, len(states))
+	merged := make([]KeyState, 0, len(states))
+	for _, state := range states {
+		i, ok := index[state.Key]
+		if !ok {
+			index[state.Key] = len(merged)
+			merged = append(merged, state)
+			continue
+		}
+		merged[i].Windows = append(merged[i].Windows, state.Windows...)
+		merged[i].Rejected += state.Rejected
+		if state.LastRejected.After(merged[i].LastRejected) {
+			merged[i].LastRejected = state.LastRejected
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Key < merged[j].Key })
+	return merged
+}
+
+/*
+Ограничитель limiter со счётчиками отклонённых запросов по ключам.
+Счётчик ключа удаляется, если его запросы не отклонялись дольше window,
+поэтому Rejected - количество отказов с начала последней серии отказов.
+*/
+type Rejections struct {
+	limiter Limiter
+	window  time.Duration
+	clock   Clock
+
+	mu        sync.Mutex
+	rejected  map[string]*rejectedKey
+	lastSweep time.Time
+}
+
+type rejectedKey struct {
+	count int
+	last  time.Time
+}
+
+func NewRejections(limiter Limiter, window time.Duration, clock Clock) *Rejections {
+	return &Rejections{
+		limiter:   limiter,
+		window:    window,
+		clock:     clock,
+		rejected:  make(map[string]*rejectedKey),
+		lastSweep: clock.Now(),
+	}
+}
+
+func (r *Rejections) Allow(ctx context.Context, key string, cost int) (Result, error) {
+	res, err := r.limiter.Allow(ctx, key, cost)
+	if err != nil || res.Allowed {
+		return res, err
+	}
+	now := r.clock.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sweep(now)
+	rejected, ok := r.rejected[key]
+	if !ok {
+		rejected = &rejectedKey{}
+		r.rejected[key] = rejected
+	}
+	rejected.count++
+	rejected.last = now
+	return res, nil
+}
+
+// удаление счётчиков ключей без отказов дольше window, не чаще раза в window
+func (r *Rejections) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < r.window {
+		return
+	}
+	for key, rejected := range r.rejected {
+		if now.Sub(rejected.last) > r.window {
+			delete(r.rejected, key)
+		}
+	}
+	r.lastSweep = now
+}
+
+// состояния ключей из limiter, если он Inspector, с количеством отказов
+func (r *Rejections) Keys(ctx context.Context) ([]KeyState, error) {
+	var states []KeyState
+	if inspector, ok := r.limiter.(Inspector); ok {
+		var err error
+		if states, err = inspector.Keys(ctx); err != nil {
+			return nil, err
+		}
+	}
+	now := r.clock.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, rejected := range r.rejected {
+		if now.Sub(rejected.last) <= r.window {
+			states = append(states, KeyState{Key: key, Rejected: rejected.count, LastRejected: rejected.last})
+		}
+	}
+	return mergeKeys(states), nil
+}
+
+func (r *Rejections) Reset(ctx context.Context, key string) error {
+	r.mu.Lock()
+	delete(r.rejected, key)
+	r.mu.Unlock()
+	if inspector, ok := r.limiter.(Inspector); ok {
+		return inspector.Reset(ctx, key)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRejections(t *testing.T) {
+	clock := NewManualClock(start)
+	r := NewRejections(NewGCRA(1, time.Minute, 0, clock), time.Hour, clock)
+	ctx := context.Background()
+	allow := func(key string) bool {
+		res, err := r.Allow(ctx, key, 1)
+		require.NoError(t, err)
+		return res.Allowed
+	}
+	require.True(t, allow("a"))
+	require.False(t, allow("a"))
+	clock.Advance(time.Second)
+	require.False(t, allow("a"))
+	require.True(t, allow("b"))
+	keys, err := r.Keys(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []KeyState{
+		{
+			Key:          "a",
+			Windows:      []Window{{Interval: time.Minute, Limit: 1, Remaining: 0, ResetAfter: 59 * time.Second}},
+			Rejected:     2,
+			LastRejected: start.Add(time.Second),
+		},
+		{
+			Key:     "b",
+			Windows: []Window{{Interval: time.Minute, Limit: 1, Remaining: 0, ResetAfter: time.Minute}},
+		},
+	}, keys)
+
+	// отказы показываются и после восстановления лимита, пока не пройдёт window
+	clock.Advance(time.Hour)
+	keys, err = r.Keys(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []KeyState{{Key: "a", Rejected: 2, LastRejected: start.Add(time.Second)}}, keys)
+	clock.Advance(time.Nanosecond)
+	keys, err = r.Keys(ctx)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+	// новая серия отказов считается заново
+	require.True(t, allow("a"))
+	require.False(t, allow("a"))
+	keys, err = r.Keys(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, keys[0].Rejected)
+
+	require.NoError(t, r.Reset(ctx, "a"))
+	keys, err = r.Keys(ctx)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+	require.True(t, allow("a"))
+}
@@ -0,0 +1,105 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ограничитель запросов: проверка запроса с весом cost для ключа key
+type Limiter interface {
+	Allow(ctx context.Context, key string, cost int) (Result, error)
+}
+
+/*
+Результат проверки лимита для ключа.
+Remaining - сколько запросов можно сделать сразу после этого,
+RetryAfter - через сколько отклонённый запрос будет пропущен,
+ResetAfter - через сколько лимит восстановится полностью.
+*/
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
+
+/*
+Параметры GCRA: emission - интервал между запросами при устойчивой скорости,
+tolerance - на сколько теоретическое время следующего запроса может опережать текущее.
+*/
+type rate struct {
+	emission  time.Duration
+	tolerance time.Duration
+	burst     int
+	interval  time.Duration
+}
+
+/*
+Скорость limit запросов за interval и burst запросов подряд (если burst не больше нуля, он равен limit).
+Интервал между запросами округляется вниз до precision, но не меньше precision:
+с нулевым интервалом ни остаток, ни ожидание не вычислить.
+*/
+func newRate(limit int, interval time.Duration, burst int, precision time.Duration) rate {
+	if burst <= 0 {
+		burst = limit
+	}
+	emission := max((interval / time.Duration(limit)).Truncate(precision), precision)
+	return rate{
+		emission:  emission,
+		tolerance: emission * time.Duration(burst),
+		burst:     burst,
+		interval:  interval,
+	}
+}
+
+// количество запросов, которые можно сделать сразу, если TAT опережает текущее время на ahead
+func (r rate) remaining(ahead time.Duration) int {
+	return int((r.tolerance - ahead) / r.emission)
+}
+
+// состояние лимита ключа, TAT которого опережает текущее время на ahead
+func (r rate) window(ahead time.Duration) Window {
+	return Window{
+		Interval:   r.interval,
+		Limit:      r.burst,
+		Remaining:  r.remaining(ahead),
+		ResetAfter: ahead,
+	}
+}
+
+// источник текущего времени, в тестах подменяется на ManualClock
+type Clock interface {
+	Now() time.Time
+}
+
+// системное время
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// время, которое меняется только вызовом Advance
+type ManualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// сдвиг времени на d
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
@@ -0,0 +1,53 @@
+package limiter
+
+import "context"
+
+/*
+Ограничитель, пропускающий запрос, только если его пропустили все ограничители.
+Они проверяются по порядку до первого отказа, поэтому отклонённый запрос расходует
+лимиты ограничителей, проверенных до отказавшего. Результат - отказ
+или пропуск с наименьшим остатком. Без ограничителей запрос всегда пропускается
+с нулевым Limit.
+*/
+type Multi []Limiter
+
+func (m Multi) Allow(ctx context.Context, key string, cost int) (Result, error) {
+	result := Result{Allowed: true}
+	for i, limiter := range m {
+		res, err := limiter.Allow(ctx, key, cost)
+		if err != nil || !res.Allowed {
+			return res, err
+		}
+		if i == 0 || res.Remaining < result.Remaining ||
+			(res.Remaining == result.Remaining && res.ResetAfter > result.ResetAfter) {
+			result = res
+		}
+	}
+	return result, nil
+}
+
+// состояния ключей из всех ограничителей, которые можно просмотреть
+func (m Multi) Keys(ctx context.Context) ([]KeyState, error) {
+	var states []KeyState
+	for _, limiter := range m {
+		if inspector, ok := limiter.(Inspector); ok {
+			keys, err := inspector.Keys(ctx)
+			if err != nil {
+				return nil, err
+			}
+			states = append(states, keys...)
+		}
+	}
+	return mergeKeys(states), nil
+}
+
+func (m Multi) Reset(ctx context.Context, key string) error {
+	for _, limiter := range m {
+		if inspector, ok := limiter.(Inspector); ok {
+			if err := inspector.Reset(ctx, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
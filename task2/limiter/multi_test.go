@@ -0,0 +1,102 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMulti(t *testing.T) {
+	clock := NewManualClock(start)
+	perSecond := NewGCRA(3, time.Second, 0, clock)
+	perMinute := NewGCRA(5, time.Minute, 0, clock)
+	m := Multi{perSecond, perMinute}
+	cases := []struct {
+		name      string
+		advance   time.Duration
+		allowed   bool
+		limit     int
+		remaining int
+	}{
+		{
+			name:      "Меньше остаток в секунду",
+			allowed:   true,
+			limit:     3,
+			remaining: 2,
+		},
+		{
+			name:      "Второй запрос",
+			allowed:   true,
+			limit:     3,
+			remaining: 1,
+		},
+		{
+			name:      "Исчерпан лимит в секунду",
+			allowed:   true,
+			limit:     3,
+			remaining: 0,
+		},
+		{
+			name:    "Отказ по лимиту в секунду не расходует лимит в минуту",
+			allowed: false,
+			limit:   3,
+		},
+		{
+			name:      "Меньше остаток в минуту",
+			advance:   time.Second,
+			allowed:   true,
+			limit:     5,
+			remaining: 1,
+		},
+		{
+			name:      "Исчерпан лимит в минуту",
+			allowed:   true,
+			limit:     5,
+			remaining: 0,
+		},
+		{
+			name:    "Отказ по лимиту в минуту",
+			allowed: false,
+			limit:   5,
+		},
+	}
+	// случаи выполняются последовательно, каждый следующий зависит от предыдущих
+	for _, test_case := range cases {
+		clock.Advance(test_case.advance)
+		res, err := m.Allow(context.Background(), "key", 1)
+		require.NoError(t, err, test_case.name)
+		require.Equal(t, test_case.allowed, res.Allowed, test_case.name)
+		require.Equal(t, test_case.limit, res.Limit, test_case.name)
+		require.Equal(t, test_case.remaining, res.Remaining, test_case.name)
+	}
+}
+
+func TestMulti_Empty(t *testing.T) {
+	res, err := Multi{}.Allow(context.Background(), "key", 1)
+	require.NoError(t, err)
+	require.Equal(t, Result{Allowed: true}, res)
+}
+
+// состояния одного ключа в разных ограничителях объединяются
+func TestMulti_Inspect(t *testing.T) {
+	clock := NewManualClock(start)
+	m := Multi{NewGCRA(3, time.Second, 0, clock), NewGCRA(5, time.Minute, 0, clock)}
+	ctx := context.Background()
+	_, err := m.Allow(ctx, "key", 1)
+	require.NoError(t, err)
+	keys, err := m.Keys(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []KeyState{{
+		Key: "key",
+		Windows: []Window{
+			{Interval: time.Second, Limit: 3, Remaining: 2, ResetAfter: time.Second / 3},
+			{Interval: time.Minute, Limit: 5, Remaining: 4, ResetAfter: 12 * time.Second},
+		},
+	}}, keys)
+	require.NoError(t, m.Reset(ctx, "key"))
+	keys, err = m.Keys(ctx)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+}
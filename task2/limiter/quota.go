@@ -0,0 +1,314 @@
+package limiter
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+/*
+Хранилище дневных квот в файле bbolt, чтобы израсходованные квоты сохранялись
+после перезапуска. Счётчики каждых суток (UTC) хранятся в отдельном разделе,
+разделы прошедших суток удаляются при начале новых.
+Счётчики текущих суток ведутся в памяти и записываются в файл раз в flushInterval
+и при закрытии, а не на каждый запрос: запись в bbolt ждёт fsync.
+При аварийном завершении теряются только запросы после последней записи.
+*/
+type QuotaStore struct {
+	db  *bolt.DB
+	log *slog.Logger
+
+	// flushMu не даёт сбросу счётчика попасть между снимком и записью в файл
+	flushMu sync.Mutex
+	mu      sync.Mutex
+	day     string
+	counts  map[string]int  // счётчики суток day, прочитанные из файла или изменённые
+	dirty   map[string]bool // счётчики, ещё не записанные в файл
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+/*
+Открытие или создание файла квот, файл может быть открыт только одним процессом.
+Если flushInterval больше нуля, счётчики записываются в файл в фоне с этим интервалом.
+*/
+func OpenQuotaStore(log *slog.Logger, path string, flushInterval time.Duration) (*QuotaStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	s := &QuotaStore{
+		db: db,
+		log: log.With(
+			slog.String("component", "limiter/quota"),
+		),
+		counts: make(map[string]int),
+		dirty:  make(map[string]bool),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if flushInterval > 0 {
+		go s.flushLoop(flushInterval)
+	} else {
+		close(s.done)
+	}
+	return s, nil
+}
+
+func (s *QuotaStore) flushLoop(interval time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Flush(); err != nil {
+				s.log.Error("Ошибка записи квот в файл.", slog.String("error", err.Error()))
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// остановка фоновой записи, запись несохранённых счётчиков и закрытие файла
+func (s *QuotaStore) Close() error {
+	close(s.stop)
+	<-s.done
+	err := s.Flush()
+	return errors.Join(err, s.db.Close())
+}
+
+// запись изменённых счётчиков текущих суток в файл
+func (s *QuotaStore) Flush() error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+	s.mu.Lock()
+	day := s.day
+	changed := make(map[string]int, len(s.dirty))
+	for key := range s.dirty {
+		changed[key] = s.counts[key]
+	}
+	clear(s.dirty)
+	s.mu.Unlock()
+	if len(changed) == 0 {
+		return nil
+	}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(day))
+		if bucket == nil {
+			if err := deleteDaysBefore(tx, day); err != nil {
+				return err
+			}
+			var err error
+			if bucket, err = tx.CreateBucket([]byte(day)); err != nil {
+				return err
+			}
+		}
+		for key, count := range changed {
+			if err := bucket.Put([]byte(key), binary.BigEndian.AppendUint64(nil, uint64(count))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		// счётчики будут записаны при следующей попытке, если сутки не сменились
+		s.mu.Lock()
+		if s.day == day {
+			for key := range changed {
+				s.dirty[key] = true
+			}
+		}
+		s.mu.Unlock()
+	}
+	return err
+}
+
+/*
+Увеличение счётчика key за сутки day на cost, если он не превысит limit.
+Возвращает значение счётчика после увеличения или без него, если квота исчерпана.
+Счётчики прошедших суток при смене суток отбрасываются.
+*/
+func (s *QuotaStore) add(day, key string, cost, limit int) (count int, ok bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if day != s.day {
+		s.day = day
+		s.counts = make(map[string]int)
+		s.dirty = make(map[string]bool)
+	}
+	count, cached := s.counts[key]
+	if !cached {
+		if count, err = s.load(day, key); err != nil {
+			return 0, false, err
+		}
+		s.counts[key] = count
+	}
+	if ok = count+cost <= limit; !ok {
+		return count, false, nil
+	}
+	count += cost
+	s.counts[key] = count
+	s.dirty[key] = true
+	return count, true, nil
+}
+
+// счётчик key за сутки day из файла
+func (s *QuotaStore) load(day, key string) (count int, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		if bucket := tx.Bucket([]byte(day)); bucket != nil {
+			if value := bucket.Get([]byte(key)); len(value) == 8 {
+				count = int(binary.BigEndian.Uint64(value))
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+// счётчики ключей с префиксом prefix за сутки day, ключи без префикса
+func (s *QuotaStore) keyCounts(day, prefix string) (keys []string, counts []int, err error) {
+	if err := s.Flush(); err != nil {
+		return nil, nil, err
+	}
+	err = s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(day))
+		if bucket == nil {
+			return nil
+		}
+		c := bucket.Cursor()
+		for key, value := c.Seek([]byte(prefix)); key != nil && bytes.HasPrefix(key, []byte(prefix)); key, value = c.Next() {
+			if len(value) == 8 {
+				keys = append(keys, string(key[len(prefix):]))
+				counts = append(counts, int(binary.BigEndian.Uint64(value)))
+			}
+		}
+		return nil
+	})
+	return keys, counts, err
+}
+
+// удаление счётчика key за сутки day
+func (s *QuotaStore) delete(day, key string) error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(day))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.Delete([]byte(key))
+	})
+	if err != nil {
+		return err
+	}
+	// счётчик удаляется из памяти после файла, иначе его старое значение могло бы снова прочитаться из файла
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.day == day {
+		delete(s.counts, key)
+		delete(s.dirty, key)
+	}
+	return nil
+}
+
+// удаление разделов суток раньше day, имена в формате ГГГГ-ММ-ДД упорядочены по дате
+func deleteDaysBefore(tx *bolt.Tx, day string) error {
+	var old [][]byte
+	err := tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+		if bytes.Compare(name, []byte(day)) < 0 {
+			old = append(old, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range old {
+		if err := tx.DeleteBucket(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+Квота limit запросов в сутки (UTC) для каждого ключа, хранится в store.
+Счётчики разных квот в одном хранилище различаются по name.
+*/
+type DailyQuota struct {
+	store *QuotaStore
+	name  string
+	limit int
+	clock Clock
+}
+
+func NewDailyQuota(store *QuotaStore, name string, limit int, clock Clock) *DailyQuota {
+	return &DailyQuota{
+		store: store,
+		name:  name,
+		limit: limit,
+		clock: clock,
+	}
+}
+
+// текущие сутки UTC и время до их окончания
+func (q *DailyQuota) today() (day string, reset time.Duration) {
+	now := q.clock.Now().UTC()
+	year, month, date := now.Date()
+	return now.Format(time.DateOnly), time.Date(year, month, date+1, 0, 0, 0, 0, time.UTC).Sub(now)
+}
+
+// проверка запроса с весом cost для ключа key, пропущенный запрос расходует квоту
+func (q *DailyQuota) Allow(_ context.Context, key string, cost int) (Result, error) {
+	day, reset := q.today()
+	count, ok, err := q.store.add(day, q.name+"|"+key, cost, q.limit)
+	if err != nil {
+		return Result{}, err
+	}
+	res := Result{
+		Allowed:    ok,
+		Limit:      q.limit,
+		Remaining:  q.limit - count,
+		ResetAfter: reset,
+	}
+	if !ok {
+		res.RetryAfter = reset
+	}
+	return res, nil
+}
+
+// ключи, израсходовавшие часть квоты за текущие сутки
+func (q *DailyQuota) Keys(_ context.Context) ([]KeyState, error) {
+	day, reset := q.today()
+	keys, counts, err := q.store.keyCounts(day, q.name+"|")
+	if err != nil {
+		return nil, err
+	}
+	states := make([]KeyState, 0, len(keys))
+	for i, key := range keys {
+		states = append(states, KeyState{
+			Key: key,
+			Windows: []Window{{
+				Interval:   24 * time.Hour,
+				Limit:      q.limit,
+				Remaining:  q.limit - counts[i],
+				ResetAfter: reset,
+			}},
+		})
+	}
+	return states, nil
+}
+
+func (q *DailyQuota) Reset(_ context.Context, key string) error {
+	day, _ := q.today()
+	return q.store.delete(day, q.name+"|"+key)
+}
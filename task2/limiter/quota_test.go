@@ -0,0 +1,145 @@
+package limiter
+
+import (
+	"FloatService/nulllogger"
+	"context"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+// хранилище квот без фоновой записи, счётчики записываются в файл при Flush и Close
+func openTestQuotaStore(t *testing.T, path string) *QuotaStore {
+	store, err := OpenQuotaStore(slog.New(&nulllogger.NullLogger{}), path, 0)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestDailyQuota(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, 1, 1, 18, 0, 0, 0, time.UTC))
+	q := NewDailyQuota(openTestQuotaStore(t, filepath.Join(t.TempDir(), "quotas.db")), "free", 5, clock)
+	res, err := q.Allow(context.Background(), "key", 3)
+	require.NoError(t, err)
+	require.Equal(t, Result{Allowed: true, Limit: 5, Remaining: 2, ResetAfter: 6 * time.Hour}, res)
+	res, err = q.Allow(context.Background(), "key", 3)
+	require.NoError(t, err)
+	require.Equal(t, Result{Limit: 5, Remaining: 2, RetryAfter: 6 * time.Hour, ResetAfter: 6 * time.Hour}, res)
+	res, err = q.Allow(context.Background(), "other", 3)
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+	res, err = q.Allow(context.Background(), "key", 2)
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+	require.Equal(t, 0, res.Remaining)
+	// квота восстанавливается в полночь UTC
+	clock.Advance(6*time.Hour - time.Nanosecond)
+	res, err = q.Allow(context.Background(), "key", 1)
+	require.NoError(t, err)
+	require.False(t, res.Allowed)
+	require.Equal(t, time.Nanosecond, res.RetryAfter)
+	clock.Advance(time.Nanosecond)
+	res, err = q.Allow(context.Background(), "key", 1)
+	require.NoError(t, err)
+	require.Equal(t, Result{Allowed: true, Limit: 5, Remaining: 4, ResetAfter: 24 * time.Hour}, res)
+}
+
+// израсходованная квота сохраняется после перезапуска, а счётчики прошедших суток удаляются
+func TestDailyQuota_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quotas.db")
+	clock := NewManualClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	store, err := OpenQuotaStore(slog.New(&nulllogger.NullLogger{}), path, 0)
+	require.NoError(t, err)
+	res, err := NewDailyQuota(store, "free", 2, clock).Allow(context.Background(), "key", 2)
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+	require.NoError(t, store.Close())
+
+	store = openTestQuotaStore(t, path)
+	q := NewDailyQuota(store, "free", 2, clock)
+	res, err = q.Allow(context.Background(), "key", 1)
+	require.NoError(t, err)
+	require.False(t, res.Allowed)
+	// у другой квоты в том же хранилище свои счётчики
+	res, err = NewDailyQuota(store, "standard", 2, clock).Allow(context.Background(), "key", 1)
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+
+	clock.Advance(24 * time.Hour)
+	res, err = q.Allow(context.Background(), "key", 1)
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+	require.NoError(t, store.Flush())
+	var days []string
+	require.NoError(t, store.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			days = append(days, string(name))
+			return nil
+		})
+	}))
+	require.Equal(t, []string{"2024-01-02"}, days)
+}
+
+func TestDailyQuota_Inspect(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, 1, 1, 18, 0, 0, 0, time.UTC))
+	store := openTestQuotaStore(t, filepath.Join(t.TempDir(), "quotas.db"))
+	free := NewDailyQuota(store, "free", 5, clock)
+	standard := NewDailyQuota(store, "standard", 10, clock)
+	ctx := context.Background()
+	_, err := free.Allow(ctx, "b", 2)
+	require.NoError(t, err)
+	_, err = free.Allow(ctx, "a", 5)
+	require.NoError(t, err)
+	_, err = standard.Allow(ctx, "c", 1)
+	require.NoError(t, err)
+	keys, err := free.Keys(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []KeyState{
+		{Key: "a", Windows: []Window{{Interval: 24 * time.Hour, Limit: 5, Remaining: 0, ResetAfter: 6 * time.Hour}}},
+		{Key: "b", Windows: []Window{{Interval: 24 * time.Hour, Limit: 5, Remaining: 3, ResetAfter: 6 * time.Hour}}},
+	}, keys)
+	// после сброса ключу снова доступна вся квота, счётчики другой квоты не меняются
+	require.NoError(t, free.Reset(ctx, "a"))
+	res, err := free.Allow(ctx, "a", 5)
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+	keys, err = standard.Keys(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	// счётчики прошедших суток не показываются
+	clock.Advance(6 * time.Hour)
+	keys, err = free.Keys(ctx)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+}
+
+// счётчики записываются в файл не на каждый запрос, а при Flush, в фоне и при закрытии
+func TestQuotaStore_Flush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quotas.db")
+	clock := NewManualClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	store := openTestQuotaStore(t, path)
+	q := NewDailyQuota(store, "free", 5, clock)
+	_, err := q.Allow(context.Background(), "key", 2)
+	require.NoError(t, err)
+	count, err := store.load("2024-01-01", "free|key")
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
+	require.NoError(t, store.Flush())
+	count, err = store.load("2024-01-01", "free|key")
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	background, err := OpenQuotaStore(slog.New(&nulllogger.NullLogger{}), filepath.Join(t.TempDir(), "quotas.db"), 10*time.Millisecond)
+	require.NoError(t, err)
+	defer background.Close()
+	_, err = NewDailyQuota(background, "free", 5, clock).Allow(context.Background(), "key", 3)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		count, err := background.load("2024-01-01", "free|key")
+		return err == nil && count == 3
+	}, time.Second, 10*time.Millisecond)
+}
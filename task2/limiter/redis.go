@@ -0,0 +1,113 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+/*
+Шаг GCRA для одного ключа, выполняется в Redis атомарно.
+Время передаётся в микросекундах, чтобы значения помещались в точность чисел Lua.
+Возвращает признак пропуска запроса, на сколько TAT опережает текущее время
+и через сколько отклонённый запрос будет пропущен. Ключ удаляется,
+когда лимит полностью восстановится.
+*/
+var gcraScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local emission = tonumber(ARGV[2])
+local tolerance = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+local tat = tonumber(redis.call("GET", KEYS[1])) or now
+if tat < now then
+	tat = now
+end
+local new_tat = tat + emission * cost
+local allow_at = new_tat - tolerance
+if now < allow_at then
+	return {0, tat - now, allow_at - now}
+end
+redis.call("SET", KEYS[1], string.format("%.0f", new_tat), "PX", math.ceil((new_tat - now) / 1000))
+return {1, new_tat - now, 0}
+`)
+
+/*
+Ограничитель по алгоритму GCRA с состоянием в Redis (или совместимом хранилище),
+общим для всех экземпляров сервиса. Текущее время берётся из clock,
+поэтому часы экземпляров должны быть синхронизированы.
+*/
+type Redis struct {
+	rate
+	client redis.Cmdable
+	prefix string
+	clock  Clock
+}
+
+/*
+Ограничитель со скоростью limit запросов за interval и burst запросами подряд (см. newRate),
+ключи в Redis получают префикс prefix.
+*/
+func NewRedis(client redis.Cmdable, prefix string, limit int, interval time.Duration, burst int, clock Clock) *Redis {
+	return &Redis{
+		rate:   newRate(limit, interval, burst, time.Microsecond),
+		client: client,
+		prefix: prefix,
+		clock:  clock,
+	}
+}
+
+// проверка запроса с весом cost для ключа key, пропущенный запрос расходует лимит
+func (r *Redis) Allow(ctx context.Context, key string, cost int) (Result, error) {
+	now := r.clock.Now().UnixMicro()
+	values, err := gcraScript.Run(ctx, r.client, []string{r.prefix + key},
+		now, r.emission.Microseconds(), r.tolerance.Microseconds(), cost,
+	).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	ahead := time.Duration(values[1]) * time.Microsecond
+	return Result{
+		Allowed:    values[0] == 1,
+		Limit:      r.burst,
+		Remaining:  r.remaining(ahead),
+		RetryAfter: time.Duration(values[2]) * time.Microsecond,
+		ResetAfter: ahead,
+	}, nil
+}
+
+// ключи с префиксом prefix, лимит которых ещё не восстановился полностью
+func (r *Redis) Keys(ctx context.Context) ([]KeyState, error) {
+	now := r.clock.Now().UnixMicro()
+	var states []KeyState
+	iter := r.client.Scan(ctx, 0, globEscaper.Replace(r.prefix)+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		tat, err := r.client.Get(ctx, iter.Val()).Int64()
+		// ключ удалён после восстановления лимита
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ahead := time.Duration(tat-now) * time.Microsecond; ahead > 0 {
+			states = append(states, KeyState{
+				Key:     strings.TrimPrefix(iter.Val(), r.prefix),
+				Windows: []Window{r.window(ahead)},
+			})
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return mergeKeys(states), nil
+}
+
+func (r *Redis) Reset(ctx context.Context, key string) error {
+	return r.client.Del(ctx, r.prefix+key).Err()
+}
+
+// экранирование специальных символов шаблона SCAN
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
@@ -0,0 +1,132 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+// Redis в памяти процесса и клиент к нему
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	return server, client
+}
+
+func TestRedis_Burst(t *testing.T) {
+	server, client := newTestRedis(t)
+	clock := NewManualClock(start)
+	r := NewRedis(client, "test:", 2, time.Minute, 5, clock)
+	for i := 0; i < 5; i++ {
+		res, err := r.Allow(context.Background(), "key", 1)
+		require.NoError(t, err)
+		require.True(t, res.Allowed, "запрос %d", i)
+		require.Equal(t, 5, res.Limit)
+		require.Equal(t, 4-i, res.Remaining)
+		require.Equal(t, time.Duration(i+1)*30*time.Second, res.ResetAfter)
+	}
+	res, err := r.Allow(context.Background(), "key", 1)
+	require.NoError(t, err)
+	require.False(t, res.Allowed)
+	require.Equal(t, 0, res.Remaining)
+	require.Equal(t, 30*time.Second, res.RetryAfter)
+	// ключ хранится, пока лимит не восстановится
+	require.Equal(t, 150*time.Second, server.TTL("test:key"))
+	clock.Advance(30*time.Second - time.Microsecond)
+	res, err = r.Allow(context.Background(), "key", 1)
+	require.NoError(t, err)
+	require.False(t, res.Allowed)
+	clock.Advance(time.Microsecond)
+	res, err = r.Allow(context.Background(), "key", 1)
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+}
+
+func TestRedis_SustainedRate(t *testing.T) {
+	_, client := newTestRedis(t)
+	clock := NewManualClock(start)
+	r := NewRedis(client, "test:", 10, time.Second, 0, clock)
+	allowed := 0
+	for elapsed := time.Duration(0); elapsed <= 5*time.Second; elapsed += 5 * time.Millisecond {
+		res, err := r.Allow(context.Background(), "key", 1)
+		require.NoError(t, err)
+		if res.Allowed {
+			allowed++
+		}
+		clock.Advance(5 * time.Millisecond)
+	}
+	require.Equal(t, 10+5*10, allowed)
+}
+
+// интервал между запросами меньше микросекунды не округляется до нуля
+func TestRedis_TinyEmission(t *testing.T) {
+	_, client := newTestRedis(t)
+	r := NewRedis(client, "test:", 2_000_000, time.Second, 0, NewManualClock(start))
+	res, err := r.Allow(context.Background(), "key", 1)
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+	require.Equal(t, 2_000_000-1, res.Remaining)
+}
+
+// экземпляры сервиса с общим хранилищем делят один лимит
+func TestRedis_Replicas(t *testing.T) {
+	_, client := newTestRedis(t)
+	clock := NewManualClock(start)
+	replicas := []*Redis{
+		NewRedis(client, "test:", 3, time.Minute, 0, clock),
+		NewRedis(client, "test:", 3, time.Minute, 0, clock),
+	}
+	allowed := 0
+	for i := 0; i < 6; i++ {
+		res, err := replicas[i%2].Allow(context.Background(), "key", 1)
+		require.NoError(t, err)
+		if res.Allowed {
+			allowed++
+		}
+	}
+	require.Equal(t, 3, allowed)
+	res, err := replicas[0].Allow(context.Background(), "other", 1)
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+}
+
+func TestRedis_Unavailable(t *testing.T) {
+	server, client := newTestRedis(t)
+	server.Close()
+	r := NewRedis(client, "test:", 1, time.Minute, 0, NewManualClock(start))
+	_, err := r.Allow(context.Background(), "key", 1)
+	require.Error(t, err)
+}
+
+func TestRedis_Inspect(t *testing.T) {
+	server, client := newTestRedis(t)
+	clock := NewManualClock(start)
+	ctx := context.Background()
+	// символы шаблона в префиксе не расширяют поиск на чужие ключи
+	r := NewRedis(client, "test[1]:", 2, time.Minute, 0, clock)
+	other := NewRedis(client, "test1:", 2, time.Minute, 0, clock)
+	for _, key := range []string{"b", "a", "b"} {
+		_, err := r.Allow(ctx, key, 1)
+		require.NoError(t, err)
+	}
+	_, err := other.Allow(ctx, "c", 1)
+	require.NoError(t, err)
+	keys, err := r.Keys(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []KeyState{
+		{Key: "a", Windows: []Window{{Interval: time.Minute, Limit: 2, Remaining: 1, ResetAfter: 30 * time.Second}}},
+		{Key: "b", Windows: []Window{{Interval: time.Minute, Limit: 2, Remaining: 0, ResetAfter: time.Minute}}},
+	}, keys)
+	require.NoError(t, r.Reset(ctx, "b"))
+	require.False(t, server.Exists("test[1]:b"))
+	require.True(t, server.Exists("test1:c"))
+	keys, err = r.Keys(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	require.Equal(t, "a", keys[0].Key)
+}
@@ -1,23 +1,39 @@
 package main
 
 import (
+	"FloatService/codec"
 	"FloatService/config"
+	"FloatService/expression"
 	"FloatService/floatcalculation"
+	"FloatService/handlers/handlecalculatev2"
+	"FloatService/handlers/handleevaluation"
 	"FloatService/handlers/handlefloatcalculation"
+	"FloatService/handlers/handlehealth"
+	"FloatService/handlers/handleratelimit"
+	"FloatService/i18n"
+	"FloatService/limiter"
+	"FloatService/middleware/adminauth"
+	"FloatService/middleware/concurrency"
+	"FloatService/middleware/deprecation"
+	"FloatService/middleware/ipfilter"
+	"FloatService/middleware/legacystatus"
 	mwLogger "FloatService/middleware/logger"
+	"FloatService/middleware/ratelimit"
+	"FloatService/openapi"
 	"FloatService/response"
 	"context"
-	"encoding/json"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/httprate"
+	"github.com/redis/go-redis/v9"
 )
 
 const (
@@ -26,70 +42,290 @@ const (
 	envProd  = "prod"
 )
 
+// сколько после истечения stop_timeout ждать ответов на прерванные запросы
+const cancelTimeout = time.Second
+
+// отказы ключу забываются, если его запросы не отклонялись дольше этого времени
+const rejectionsWindow = time.Hour
+
 func main() {
 	if len(os.Args) != 2 {
 		fmt.Printf("Запускать: %s <файл конфигурации>\n", os.Args[0])
 		os.Exit(1)
 	}
 	cfg := config.MustLoad(os.Args[1])
-	log, logfile := setupLogger(cfg.Env)
+	log, logfile := setupLogger(cfg.Env, cfg.LogLanguage)
 	if logfile != nil {
 		defer logfile.Close()
 	}
 	log.Info("Запуск FloatService", slog.String("env", cfg.Env))
 	log.Debug("Логгирование запущено на уровне DEBUG.")
-	router := chi.NewRouter()
-	// добавление к каждому запросу ID, чтобы потом отслеживать, что пошло не так
-	router.Use(middleware.RequestID)
-	// логгирование запросов
-	router.Use(mwLogger.New(log))
-	// восстановление в случае паники у обработчика
-	router.Use(middleware.Recoverer)
-	//добавление ограничения на количество запросов
-	router.Use(httprate.Limit(
-		cfg.Limit, cfg.Interval,
-		httprate.WithLimitHandler(func(w http.ResponseWriter, r *http.Request) {
-			log.Warn("Достигнут лимит запросов.")
-			// Собственный http ответ с ошибкой 402 и json ответом из файла конфигурации
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(402)
-			resp, _ := json.Marshal(response.Error(cfg.Msg))
-			w.Write(append(resp, byte('\n')))
-		})))
-	// добавляем обработчик
-	router.Get("/", handlefloatcalculation.New(log, &floatcalculation.FloatCalculator{}))
-	log.Info("Запускаем сервер.", slog.String("address", cfg.Address))
+	ln, err := net.Listen("tcp", cfg.Address)
+	if err != nil {
+		log.Error("Ошибка сервера.", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	if err := run(log, cfg, os.Args[1], ln); err != nil {
+		log.Error("Ошибка открытия хранилища квот.", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+}
+
+/*
+Запуск сервера на ln и его остановка по SIGTERM или SIGINT.
+Файл конфигурации cfgPath перечитывается по SIGHUP.
+Ошибка возвращается, только если сервер не удалось запустить.
+*/
+func run(log *slog.Logger, cfg *config.Config, cfgPath string, ln net.Listener) error {
+	limiters, quotaStore, err := newRateLimiters(log, cfg.RateLimit)
+	if err != nil {
+		ln.Close()
+		return err
+	}
+	if quotaStore != nil {
+		// несохранённые счётчики квот записываются в файл при остановке
+		defer func() {
+			if err := quotaStore.Close(); err != nil {
+				log.Error("Ошибка записи квот в файл.", slog.String("error", err.Error()))
+			}
+		}()
+	}
+	// адреса прокси уже проверены при загрузке конфигурации
+	trusted, _ := cfg.TrustedPrefixes()
+	ipFilter := ipfilter.New(log, cfg.IPFilter, trusted)
+	readiness := &handlehealth.Readiness{}
+	router := newRouter(log, cfg, limiters, ipFilter, readiness)
+	// списки адресов перечитываются из файла конфигурации по SIGHUP
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	defer func() {
+		signal.Stop(reload)
+		close(reload)
+	}()
+	go func() {
+		for range reload {
+			newCfg, err := config.Load(cfgPath)
+			if err == nil {
+				err = ipFilter.Update(newCfg.IPFilter)
+			}
+			if err != nil {
+				log.Error("Ошибка перечитывания файла конфигурации.", slog.String("error", err.Error()))
+			}
+		}
+	}()
+	log.Info("Запускаем сервер.", slog.String("address", ln.Addr().String()))
 	// обработка прерываний
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(done)
+	// контекст всех запросов отменяется, если они не завершились за stop_timeout, чтобы прервать долгие вычисления
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
 	srv := &http.Server{
-		Addr:         cfg.Address,
 		Handler:      router,
 		ReadTimeout:  cfg.HTTPServer.Timeout,
 		WriteTimeout: cfg.HTTPServer.Timeout,
 		IdleTimeout:  cfg.HTTPServer.IdleTimeout,
+		BaseContext:  func(net.Listener) context.Context { return baseCtx },
 	}
 	// выносим запуск сервера в отдельную Go рутину
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
 			log.Error("Ошибка сервера.", slog.String("error", err.Error()))
 		}
 	}()
 	log.Info("Сервер запущен")
 	<-done
 	log.Info("Остановка сервера.")
+	// /readyz сразу начинает отвечать 503, а новые запросы принимаются ещё shutdown_delay,
+	// пока балансировщик не перестанет их направлять; повторный сигнал останавливает сервер сразу
+	readiness.Stop()
+	if cfg.ShutdownDelay > 0 {
+		log.Info("Ожидание перед остановкой сервера.", slog.Duration("delay", cfg.ShutdownDelay))
+		select {
+		case <-time.After(cfg.ShutdownDelay):
+		case <-done:
+		}
+	}
 	// сервер остановится через timepout времени, если есть открытые подключения, иначе мгновенно
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.StopTimeout)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Error("Ошибка остановки сервера", slog.String("error", err.Error()))
-		return
+		// незавершённые вычисления прерываются, и их клиенты успевают получить ответ 503
+		cancelRequests()
+		ctx, cancel := context.WithTimeout(context.Background(), cancelTimeout)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+		return nil
 	}
 	log.Info("Сервер остановлен.")
+	return nil
+}
+
+/*
+Маршрутизатор со всеми middleware и обработчиками.
+Проверки живости и готовности для оркестратора не проходят через middleware API:
+они не ограничиваются лимитом запросов и фильтром адресов и не пишутся в лог.
+*/
+func newRouter(log *slog.Logger, cfg *config.Config, limiters ratelimit.Limiters, ipFilter *ipfilter.Filter, readiness *handlehealth.Readiness) *chi.Mux {
+	// спецификация описывает и маршруты API, и проверки
+	doc := newAPIDocument(cfg)
+	router := chi.NewRouter()
+	router.Group(func(r chi.Router) {
+		r.Use(i18n.New(cfg.DefaultLanguage))
+		r.Get("/healthz", handlehealth.New())
+		r.Get("/readyz", handlehealth.NewReady(readiness))
+	})
+	router.Mount("/", newAPIRouter(log, cfg, limiters, ipFilter, doc))
+	if undocumented := doc.Undocumented(router); len(undocumented) > 0 {
+		log.Warn("Маршруты не описаны в спецификации OpenAPI.", slog.Any("routes", undocumented))
+	}
+	return router
+}
+
+// маршрутизатор API со всеми middleware, doc отдаётся по /openapi.json
+func newAPIRouter(log *slog.Logger, cfg *config.Config, limiters ratelimit.Limiters, ipFilter *ipfilter.Filter, doc *openapi.Document) *chi.Mux {
+	router := chi.NewRouter()
+	// выбор языка ответа по заголовку Accept-Language
+	router.Use(i18n.New(cfg.DefaultLanguage))
+	// добавление к каждому запросу ID, чтобы потом отслеживать, что пошло не так
+	router.Use(middleware.RequestID)
+	// логгирование запросов
+	router.Use(mwLogger.New(log))
+	// восстановление в случае паники у обработчика
+	router.Use(middleware.Recoverer)
+	// прежние HTTP статусы ошибок для клиентов, ещё не перешедших на новые
+	if cfg.LegacyStatus {
+		router.Use(legacystatus.New(log))
+	}
+	// ошибки в формате application/problem+json для всех запросов, а не только запросивших его в Accept
+	if cfg.ProblemJSON {
+		router.Use(response.ProblemJSONByDefault)
+	}
+	// отказ запросам из запрещённых подсетей и снятие лимита с разрешённых
+	router.Use(ipFilter.Middleware)
+	// ограничение размера тела запроса и строгая проверка полей
+	router.Use(codec.New(cfg.MaxBodySize, cfg.StrictDecoding))
+	//добавление ограничения на количество запросов
+	router.Use(ratelimit.New(log, cfg.RateLimit, limiters))
+	// добавляем обработчики, у каждой версии API свой набор маршрутов и типов запросов и ответов
+	calculator := &floatcalculation.FloatCalculator{
+		MaxExponent: cfg.Calculation.MaxExponent,
+		MaxDigits:   cfg.Calculation.MaxDigits,
+	}
+	evaluator := &expression.Evaluator{
+		MaxLength:         cfg.Evaluation.MaxLength,
+		MaxDepth:          cfg.Evaluation.MaxDepth,
+		MaxOperations:     cfg.Evaluation.MaxOperations,
+		MaxPrecision:      cfg.Evaluation.MaxPrecision,
+		DivisionPrecision: cfg.Evaluation.DivisionPrecision,
+		MaxExponent:       cfg.Evaluation.MaxExponent,
+		MaxDigits:         cfg.Evaluation.MaxDigits,
+	}
+	calculate := handlefloatcalculation.New(log, calculator, cfg.ComputeTimeout)
+	evaluate := handleevaluation.New(log, evaluator)
+	// ограничение одновременных вычислений, общее для всех версий API
+	inFlight := concurrency.New(log, cfg.Concurrency)
+	router.Route("/v1", func(r chi.Router) {
+		r.Use(inFlight.Middleware)
+		r.Use(deprecation.New(log, "v1", cfg.APIVersions["v1"]))
+		r.Post("/calculate", calculate)
+		r.Get("/calculate", handlefloatcalculation.NewQuery(log, calculator, cfg.ComputeTimeout))
+		r.Post("/evaluate", evaluate)
+	})
+	router.Route("/v2", func(r chi.Router) {
+		r.Use(inFlight.Middleware)
+		r.Use(deprecation.New(log, "v2", cfg.APIVersions["v2"]))
+		r.Post("/calculate", handlecalculatev2.New(log, calculator, cfg.ComputeTimeout))
+	})
+	// прежние маршруты без версии, оставлены для совместимости
+	router.With(inFlight.Middleware, deprecation.New(log, "legacy", config.APIVersion{Deprecated: true, Successor: "/v1/calculate"})).Get("/", calculate)
+	router.With(inFlight.Middleware, deprecation.New(log, "legacy", config.APIVersion{Deprecated: true, Successor: "/v1/evaluate"})).Post("/evaluate", evaluate)
+	// состояние очереди и лимитов клиентов доступно только с токеном администратора
+	if cfg.AdminToken != "" {
+		// счётчики ограничения одновременных вычислений
+		router.With(adminauth.New(log, cfg.AdminToken)).Get("/metrics/concurrency", inFlight.StatsHandler())
+		// просмотр и сброс лимитов клиентов
+		router.Route("/admin", func(r chi.Router) {
+			r.Use(adminauth.New(log, cfg.AdminToken))
+			r.Get("/ratelimit/keys", handleratelimit.New(log, limiters))
+			r.Delete("/ratelimit/keys", handleratelimit.NewReset(log, limiters))
+		})
+	}
+	// спецификация OpenAPI и страница документации
+	router.Get("/openapi.json", doc.Handler())
+	router.Get("/docs", openapi.DocsHandler())
+	return router
+}
+
+/*
+Ограничители запросов по тарифам, без тарифов - один ограничитель на limit запросов за interval.
+Лимиты в секунду и минуту хранятся в памяти процесса или, если задан адрес Redis,
+в Redis, общем для всех экземпляров сервиса, с локальным лимитом на время его недоступности.
+Дневные квоты считаются в памяти и записываются в файл cfg.QuotaStore, который открывается,
+только если у какого-либо тарифа есть дневная квота; открытое хранилище нужно закрыть.
+Ограничители каждого тарифа считают отклонённые запросы за последний час (см. limiter.Rejections).
+В режимах без подсчёта запросов ограничители не нужны.
+*/
+func newRateLimiters(log *slog.Logger, cfg config.RateLimit) (ratelimit.Limiters, *limiter.QuotaStore, error) {
+	if cfg.Mode == config.ModeDisabled || cfg.Mode == config.ModeDenyAll {
+		return ratelimit.Limiters{}, nil, nil
+	}
+	var client *redis.Client
+	if cfg.Redis.Address != "" {
+		client = redis.NewClient(&redis.Options{
+			Addr:         cfg.Redis.Address,
+			Password:     cfg.Redis.Password,
+			DB:           cfg.Redis.DB,
+			DialTimeout:  cfg.Redis.Timeout,
+			ReadTimeout:  cfg.Redis.Timeout,
+			WriteTimeout: cfg.Redis.Timeout,
+			MaxRetries:   -1,
+		})
+	}
+	window := func(prefix string, limit int, interval time.Duration, burst int) limiter.Limiter {
+		local := limiter.NewGCRA(limit, interval, burst, limiter.SystemClock{})
+		if client == nil {
+			return local
+		}
+		shared := limiter.NewRedis(client, cfg.Redis.Prefix+prefix, limit, interval, burst, limiter.SystemClock{})
+		return limiter.NewFallback(log, shared, local, cfg.Redis.RetryInterval, limiter.SystemClock{})
+	}
+	if len(cfg.Plans) == 0 {
+		return ratelimit.Limiters{"": withRejections(window("", cfg.Limit, cfg.Interval, cfg.Burst))}, nil, nil
+	}
+	var store *limiter.QuotaStore
+	limiters := make(ratelimit.Limiters, len(cfg.Plans))
+	for name, plan := range cfg.Plans {
+		var planLimiters limiter.Multi
+		if plan.PerSecond > 0 {
+			planLimiters = append(planLimiters, window(name+":second:", plan.PerSecond, time.Second, 0))
+		}
+		if plan.PerMinute > 0 {
+			planLimiters = append(planLimiters, window(name+":minute:", plan.PerMinute, time.Minute, 0))
+		}
+		if plan.PerDay > 0 {
+			if store == nil {
+				var err error
+				if store, err = limiter.OpenQuotaStore(log, cfg.QuotaStore, cfg.QuotaFlushInterval); err != nil {
+					return nil, nil, err
+				}
+			}
+			planLimiters = append(planLimiters, limiter.NewDailyQuota(store, name, plan.PerDay, limiter.SystemClock{}))
+		}
+		limiters[name] = withRejections(planLimiters)
+	}
+	return limiters, store, nil
+}
+
+// счётчики отказов для просмотра через /admin/ratelimit/keys
+func withRejections(l limiter.Limiter) limiter.Limiter {
+	return limiter.NewRejections(l, rejectionsWindow, limiter.SystemClock{})
 }
 
-// настройка логгирования
-func setupLogger(env string) (log *slog.Logger, logfile *os.File) {
+// настройка логгирования, сообщения переводятся на язык lang
+func setupLogger(env string, lang string) (log *slog.Logger, logfile *os.File) {
 	switch env {
 	case envLocal:
 		logfile, err := os.OpenFile(
@@ -123,5 +359,6 @@ func setupLogger(env string) (log *slog.Logger, logfile *os.File) {
 			),
 		)
 	}
+	log = slog.New(i18n.NewLogHandler(log.Handler(), lang))
 	return
 }
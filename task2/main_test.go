@@ -0,0 +1,119 @@
+package main
+
+import (
+	"FloatService/config"
+	"FloatService/handlers/handlehealth"
+	"FloatService/middleware/ipfilter"
+	"FloatService/nulllogger"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// все маршруты сервиса должны быть описаны в спецификации OpenAPI
+func TestAPIDocument(t *testing.T) {
+	cfg := &config.Config{
+		Env: "dev",
+		APIVersions: map[string]config.APIVersion{
+			"v1": {Deprecated: true},
+		},
+	}
+	cfg.AdminToken = "secret"
+	cfg.Limit = 100
+	cfg.Interval = time.Minute
+	log := slog.New(&nulllogger.NullLogger{})
+	limiters, _, err := newRateLimiters(log, cfg.RateLimit)
+	require.NoError(t, err)
+	router := newRouter(log, cfg, limiters, ipfilter.New(log, cfg.IPFilter, nil), &handlehealth.Readiness{})
+	doc := newAPIDocument(cfg)
+	require.Empty(t, doc.Undocumented(router))
+	require.True(t, doc.Paths["/v1/calculate"]["post"].Deprecated)
+	require.False(t, doc.Paths["/v2/calculate"]["post"].Deprecated)
+	require.True(t, doc.Paths["/"]["get"].Deprecated)
+	require.Contains(t, doc.Paths["/admin/ratelimit/keys"], "delete")
+	require.NotContains(t, doc.Paths["/readyz"]["get"].Responses, "429")
+}
+
+// счётчики очереди вычислений доступны только с токеном администратора
+func TestConcurrencyMetrics_AdminOnly(t *testing.T) {
+	cases := []struct {
+		name   string
+		token  string
+		header string
+		status int
+	}{
+		{name: "Без токена в конфигурации", status: http.StatusNotFound},
+		{name: "Без заголовка", token: "secret", status: http.StatusUnauthorized},
+		{name: "Неверный токен", token: "secret", header: "Bearer wrong", status: http.StatusUnauthorized},
+		{name: "С токеном", token: "secret", header: "Bearer secret", status: http.StatusOK},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := &config.Config{Env: "dev"}
+			cfg.AdminToken = test_case.token
+			cfg.Limit = 100
+			cfg.Interval = time.Minute
+			log := slog.New(&nulllogger.NullLogger{})
+			limiters, _, err := newRateLimiters(log, cfg.RateLimit)
+			require.NoError(t, err)
+			router := newRouter(log, cfg, limiters, ipfilter.New(log, cfg.IPFilter, nil), &handlehealth.Readiness{})
+			req := httptest.NewRequest(http.MethodGet, "/metrics/concurrency", nil)
+			if test_case.header != "" {
+				req.Header.Set("Authorization", test_case.header)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			require.Equal(t, test_case.status, rr.Code)
+		})
+	}
+}
+
+// после SIGTERM /readyz отвечает 503, пока сервер ещё принимает соединения
+func TestRun_ReadinessOnShutdown(t *testing.T) {
+	cfg := &config.Config{Env: "dev"}
+	cfg.Limit = 100
+	cfg.Interval = time.Minute
+	cfg.StopTimeout = time.Second
+	cfg.ShutdownDelay = 500 * time.Millisecond
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	url := "http://" + ln.Addr().String() + "/readyz"
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- run(slog.New(&nulllogger.NullLogger{}), cfg, "", ln)
+	}()
+	// обработчик сигналов подключается до начала приёма соединений
+	require.Eventually(t, func() bool {
+		return readyzStatus(url) == http.StatusOK
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+	require.Eventually(t, func() bool {
+		return readyzStatus(url) == http.StatusServiceUnavailable
+	}, cfg.ShutdownDelay, 10*time.Millisecond)
+	select {
+	case err := <-stopped:
+		require.NoError(t, err)
+	case <-time.After(cfg.ShutdownDelay + cfg.StopTimeout + time.Second):
+		t.Fatal("сервер не остановился")
+	}
+	_, err = net.Dial("tcp", ln.Addr().String())
+	require.Error(t, err)
+}
+
+// статус ответа или 0, если соединение не установлено
+func readyzStatus(url string) int {
+	resp, err := http.Get(url)
+	if err != nil {
+		return 0
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
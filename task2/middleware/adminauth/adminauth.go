@@ -0,0 +1,43 @@
+package adminauth
+
+import (
+	"FloatService/i18n"
+	"FloatService/response"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+/*
+Доступ только с токеном администратора в заголовке "Authorization: Bearer <token>".
+Остальные запросы получают статус 401. Токен сравнивается за постоянное время,
+чтобы его нельзя было подобрать по времени ответа.
+*/
+func New(log *slog.Logger, token string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/adminauth"),
+		)
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			scheme, credentials, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+			if !strings.EqualFold(scheme, "Bearer") ||
+				subtle.ConstantTimeCompare([]byte(strings.TrimSpace(credentials)), []byte(token)) != 1 {
+				log.Warn("Запрос без действительного токена администратора.",
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+					slog.String("path", r.URL.Path),
+				)
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				msg := i18n.T(i18n.FromContext(r.Context()), i18n.Unauthorized)
+				response.RenderError(w, r, http.StatusUnauthorized, response.Error(response.CodeUnauthorized, msg))
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
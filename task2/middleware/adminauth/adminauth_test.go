@@ -0,0 +1,73 @@
+package adminauth
+
+import (
+	"FloatService/nulllogger"
+	"FloatService/response"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminAuth(t *testing.T) {
+	cases := []struct {
+		name          string
+		authorization string
+		status        int
+	}{
+		{
+			name:          "Верный токен",
+			authorization: "Bearer secret",
+			status:        http.StatusOK,
+		},
+		{
+			name:          "Схема в другом регистре",
+			authorization: "bearer secret",
+			status:        http.StatusOK,
+		},
+		{
+			name:   "Нет заголовка",
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:          "Неверный токен",
+			authorization: "Bearer secret2",
+			status:        http.StatusUnauthorized,
+		},
+		{
+			name:          "Другая схема",
+			authorization: "Basic secret",
+			status:        http.StatusUnauthorized,
+		},
+		{
+			name:          "Пустой токен",
+			authorization: "Bearer ",
+			status:        http.StatusUnauthorized,
+		},
+	}
+	handler := New(slog.New(&nulllogger.NullLogger{}), "secret")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(http.MethodGet, "/admin/ratelimit/keys", nil)
+			if test_case.authorization != "" {
+				req.Header.Set("Authorization", test_case.authorization)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			require.Equal(t, test_case.status, rr.Code)
+			if test_case.status == http.StatusUnauthorized {
+				require.Equal(t, "Bearer", rr.Header().Get("WWW-Authenticate"))
+				var resp response.Response
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+				require.Equal(t, response.Error(response.CodeUnauthorized, "Требуется токен администратора."), resp)
+			}
+		})
+	}
+}
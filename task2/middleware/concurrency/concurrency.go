@@ -0,0 +1,214 @@
+package concurrency
+
+import (
+	"FloatService/config"
+	"FloatService/i18n"
+	"FloatService/response"
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+// счётчики ограничителя для мониторинга, время ожидания в секундах
+type Stats struct {
+	MaxInFlight       int     `json:"max_in_flight"`
+	QueueSize         int     `json:"queue_size"`
+	InFlight          int     `json:"in_flight"`
+	Queued            int     `json:"queued"`
+	Admitted          uint64  `json:"admitted"`
+	AdmittedAfterWait uint64  `json:"admitted_after_wait"`
+	WaitSeconds       float64 `json:"wait_seconds_total"`
+	MaxWaitSeconds    float64 `json:"max_wait_seconds"`
+	RejectedQueueFull uint64  `json:"rejected_queue_full"`
+	RejectedTimeout   uint64  `json:"rejected_timeout"`
+	Canceled          uint64  `json:"canceled"`
+}
+
+/*
+Ограничение одновременных вычислений (см. config.Concurrency).
+Запрос, которому не хватило места, ждёт в очереди, а если очередь заполнена
+или время ожидания истекло, получает статус 503 с заголовком Retry-After.
+Запрос, клиент которого ушёл во время ожидания, снимается с очереди без ответа.
+Место освобождается, когда обработчик вернул управление и отпущены все удержания Retain.
+*/
+type Limiter struct {
+	log   *slog.Logger
+	cfg   config.Concurrency
+	slots chan struct{}
+
+	mu       sync.Mutex
+	queued   int
+	stats    Stats
+	waitTime time.Duration
+	maxWait  time.Duration
+}
+
+func New(log *slog.Logger, cfg config.Concurrency) *Limiter {
+	log = log.With(
+		slog.String("component", "middleware/concurrency"),
+	)
+	l := &Limiter{
+		log: log,
+		cfg: cfg,
+	}
+	if cfg.MaxInFlight > 0 {
+		l.slots = make(chan struct{}, cfg.MaxInFlight)
+	}
+	return l
+}
+
+// middleware ограничения, без MaxInFlight запросы передаются дальше без изменений
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	if l.slots == nil {
+		return next
+	}
+
+	l.log.Info("concurrency limit middleware enabled",
+		slog.Int("max_in_flight", l.cfg.MaxInFlight),
+		slog.Int("queue_size", l.cfg.QueueSize),
+		slog.String("queue_timeout", l.cfg.QueueTimeout.String()),
+	)
+
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if !l.acquire(w, r) {
+			return
+		}
+		s := &slot{free: func() { <-l.slots }}
+		s.refs.Store(1)
+		defer s.release()
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), slotCtxKey{}, s)))
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+type slotCtxKey struct{}
+
+// место, занятое запросом, refs - сколько раз его ещё нужно отпустить
+type slot struct {
+	refs atomic.Int32
+	free func()
+}
+
+func (s *slot) release() {
+	if s.refs.Add(-1) == 0 {
+		s.free()
+	}
+}
+
+/*
+Удержание места запроса с контекстом ctx до вызова release, даже если обработчик
+уже отправил ответ: вычисления, которые нельзя прервать, продолжаются в фоне
+после ответа по истечении времени, и место остаётся занятым, пока они не закончатся.
+Вызывается до возврата из обработчика. Без ограничителя возвращает функцию, которая ничего не делает.
+*/
+func Retain(ctx context.Context) (release func()) {
+	s, ok := ctx.Value(slotCtxKey{}).(*slot)
+	if !ok {
+		return func() {}
+	}
+	s.refs.Add(1)
+	var once sync.Once
+	return func() { once.Do(s.release) }
+}
+
+// занятие места для запроса, если места нет - отправляет ответ 503 и возвращает false
+func (l *Limiter) acquire(w http.ResponseWriter, r *http.Request) bool {
+	select {
+	case l.slots <- struct{}{}:
+		l.mu.Lock()
+		l.stats.Admitted++
+		l.mu.Unlock()
+		return true
+	default:
+	}
+
+	l.mu.Lock()
+	if l.queued >= l.cfg.QueueSize {
+		l.stats.RejectedQueueFull++
+		l.mu.Unlock()
+		l.log.Warn("Очередь вычислений заполнена, запрос отклонён.",
+			slog.Int("in_flight", len(l.slots)),
+			slog.Int("queued", l.cfg.QueueSize),
+		)
+		l.reject(w, r)
+		return false
+	}
+	l.queued++
+	queued := l.queued
+	l.mu.Unlock()
+
+	start := time.Now()
+	timer := time.NewTimer(l.cfg.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		waited := time.Since(start)
+		l.mu.Lock()
+		l.queued--
+		l.stats.Admitted++
+		l.stats.AdmittedAfterWait++
+		l.waitTime += waited
+		l.maxWait = max(l.maxWait, waited)
+		l.mu.Unlock()
+		l.log.Debug("Запрос дождался своей очереди вычислений.",
+			slog.Int("queued", queued),
+			slog.String("waited", waited.String()),
+		)
+		return true
+	case <-timer.C:
+		l.mu.Lock()
+		l.queued--
+		l.stats.RejectedTimeout++
+		l.waitTime += l.cfg.QueueTimeout
+		l.maxWait = max(l.maxWait, l.cfg.QueueTimeout)
+		l.mu.Unlock()
+		l.log.Warn("Превышено время ожидания в очереди вычислений, запрос отклонён.",
+			slog.Int("queued", queued),
+			slog.String("waited", l.cfg.QueueTimeout.String()),
+		)
+		l.reject(w, r)
+		return false
+	case <-r.Context().Done():
+		l.mu.Lock()
+		l.queued--
+		l.stats.Canceled++
+		l.mu.Unlock()
+		return false
+	}
+}
+
+func (l *Limiter) reject(w http.ResponseWriter, r *http.Request) {
+	retryAfter := int(math.Ceil(l.cfg.RetryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+	msg := i18n.T(i18n.FromContext(r.Context()), i18n.Overloaded)
+	response.RenderError(w, r, http.StatusServiceUnavailable, response.Error(response.CodeOverloaded, msg))
+}
+
+// текущие значения счётчиков
+func (l *Limiter) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := l.stats
+	stats.MaxInFlight = l.cfg.MaxInFlight
+	stats.QueueSize = l.cfg.QueueSize
+	stats.InFlight = len(l.slots)
+	stats.Queued = l.queued
+	stats.WaitSeconds = l.waitTime.Seconds()
+	stats.MaxWaitSeconds = l.maxWait.Seconds()
+	return stats
+}
+
+// обработчик, отдающий счётчики в json
+func (l *Limiter) StatsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, l.Stats())
+	}
+}
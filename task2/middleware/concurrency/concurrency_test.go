@@ -0,0 +1,216 @@
+package concurrency
+
+import (
+	"FloatService/config"
+	"FloatService/nulllogger"
+	"FloatService/response"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+/*
+Ограничитель с обработчиком, который выполняется, пока тест не закроет release.
+Обработчик пишет в started при начале выполнения.
+*/
+func newTestLimiter(t *testing.T, cfg config.Concurrency) (l *Limiter, handler http.Handler, started, release chan struct{}) {
+	l = New(slog.New(&nulllogger.NullLogger{}), cfg)
+	started = make(chan struct{}, 10)
+	release = make(chan struct{})
+	t.Cleanup(func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+	})
+	handler = l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	return l, handler, started, release
+}
+
+// запрос в отдельной Go рутине, ответ приходит в канал
+func serve(handler http.Handler, r *http.Request) chan *httptest.ResponseRecorder {
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, r)
+		done <- rr
+	}()
+	return done
+}
+
+func TestConcurrency(t *testing.T) {
+	cases := []struct {
+		name     string
+		cfg      config.Concurrency
+		status   int
+		expected Stats
+	}{
+		{
+			name:   "Без очереди",
+			cfg:    config.Concurrency{MaxInFlight: 1, QueueTimeout: time.Second, RetryAfter: 1500 * time.Millisecond},
+			status: http.StatusServiceUnavailable,
+			expected: Stats{
+				MaxInFlight:       1,
+				InFlight:          1,
+				Admitted:          1,
+				RejectedQueueFull: 1,
+			},
+		},
+		{
+			name:   "Превышено время ожидания в очереди",
+			cfg:    config.Concurrency{MaxInFlight: 1, QueueSize: 1, QueueTimeout: 10 * time.Millisecond, RetryAfter: 1500 * time.Millisecond},
+			status: http.StatusServiceUnavailable,
+			expected: Stats{
+				MaxInFlight:     1,
+				QueueSize:       1,
+				InFlight:        1,
+				Admitted:        1,
+				RejectedTimeout: 1,
+				WaitSeconds:     0.01,
+				MaxWaitSeconds:  0.01,
+			},
+		},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			l, handler, started, _ := newTestLimiter(t, test_case.cfg)
+			serve(handler, httptest.NewRequest(http.MethodPost, "/v1/calculate", nil))
+			<-started
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/calculate", nil))
+			require.Equal(t, test_case.status, rr.Code)
+			require.Equal(t, "2", rr.Header().Get("Retry-After"))
+			var resp response.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.Equal(t, response.CodeOverloaded, resp.Code)
+			require.Equal(t, "Сервер перегружен, повторите запрос позже.", resp.Error)
+			require.Equal(t, test_case.expected, l.Stats())
+		})
+	}
+}
+
+// запрос из очереди выполняется, когда освобождается место
+func TestConcurrency_Queue(t *testing.T) {
+	l, handler, started, release := newTestLimiter(t, config.Concurrency{MaxInFlight: 1, QueueSize: 1, QueueTimeout: time.Minute, RetryAfter: time.Second})
+	first := serve(handler, httptest.NewRequest(http.MethodPost, "/v1/calculate", nil))
+	<-started
+	second := serve(handler, httptest.NewRequest(http.MethodPost, "/v1/calculate", nil))
+	require.Eventually(t, func() bool { return l.Stats().Queued == 1 }, time.Second, time.Millisecond)
+	// очередь заполнена
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/calculate", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+
+	close(release)
+	require.Equal(t, http.StatusOK, (<-first).Code)
+	require.Equal(t, http.StatusOK, (<-second).Code)
+	stats := l.Stats()
+	require.Equal(t, 0, stats.InFlight)
+	require.Equal(t, 0, stats.Queued)
+	require.Equal(t, uint64(2), stats.Admitted)
+	require.Equal(t, uint64(1), stats.AdmittedAfterWait)
+	require.Equal(t, uint64(1), stats.RejectedQueueFull)
+	require.Positive(t, stats.WaitSeconds)
+	require.Equal(t, stats.WaitSeconds, stats.MaxWaitSeconds)
+}
+
+// запрос, клиент которого ушёл, снимается с очереди без ответа
+func TestConcurrency_Canceled(t *testing.T) {
+	l, handler, started, _ := newTestLimiter(t, config.Concurrency{MaxInFlight: 1, QueueSize: 1, QueueTimeout: time.Minute, RetryAfter: time.Second})
+	serve(handler, httptest.NewRequest(http.MethodPost, "/v1/calculate", nil))
+	<-started
+	ctx, cancel := context.WithCancel(context.Background())
+	waiting := serve(handler, httptest.NewRequest(http.MethodPost, "/v1/calculate", nil).WithContext(ctx))
+	require.Eventually(t, func() bool { return l.Stats().Queued == 1 }, time.Second, time.Millisecond)
+	cancel()
+	rr := <-waiting
+	require.Empty(t, rr.Body.String())
+	require.Empty(t, rr.Header().Get("Retry-After"))
+	stats := l.Stats()
+	require.Equal(t, 0, stats.Queued)
+	require.Equal(t, uint64(1), stats.Canceled)
+}
+
+// место остаётся занятым после ответа, пока не отпущены все удержания
+func TestConcurrency_Retain(t *testing.T) {
+	l := New(slog.New(&nulllogger.NullLogger{}), config.Concurrency{MaxInFlight: 1, QueueTimeout: time.Second, RetryAfter: time.Second})
+	var release func()
+	handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		release = Retain(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/calculate", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, 1, l.Stats().InFlight)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/calculate", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+
+	first := release
+	first()
+	require.Equal(t, 0, l.Stats().InFlight)
+	// повторный вызов release не освобождает чужое место
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/calculate", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	first()
+	require.Equal(t, 1, l.Stats().InFlight)
+	release()
+	require.Equal(t, 0, l.Stats().InFlight)
+}
+
+// без ограничителя удержание ничего не делает
+func TestRetain_NoLimiter(t *testing.T) {
+	Retain(context.Background())()
+}
+
+// без max_in_flight запросы не ограничиваются
+func TestConcurrency_Disabled(t *testing.T) {
+	l, handler, started, release := newTestLimiter(t, config.Concurrency{QueueTimeout: time.Second, RetryAfter: time.Second})
+	var responses []chan *httptest.ResponseRecorder
+	for i := 0; i < 3; i++ {
+		responses = append(responses, serve(handler, httptest.NewRequest(http.MethodPost, "/v1/calculate", nil)))
+		<-started
+	}
+	close(release)
+	for _, done := range responses {
+		require.Equal(t, http.StatusOK, (<-done).Code)
+	}
+	require.Equal(t, Stats{}, l.Stats())
+}
+
+func TestStatsHandler(t *testing.T) {
+	l, handler, started, _ := newTestLimiter(t, config.Concurrency{MaxInFlight: 2, QueueSize: 4, QueueTimeout: time.Second, RetryAfter: time.Second})
+	serve(handler, httptest.NewRequest(http.MethodPost, "/v1/calculate", nil))
+	<-started
+	rr := httptest.NewRecorder()
+	l.StatsHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics/concurrency", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{
+		"max_in_flight": 2,
+		"queue_size": 4,
+		"in_flight": 1,
+		"queued": 0,
+		"admitted": 1,
+		"admitted_after_wait": 0,
+		"wait_seconds_total": 0,
+		"max_wait_seconds": 0,
+		"rejected_queue_full": 0,
+		"rejected_timeout": 0,
+		"canceled": 0
+	}`, rr.Body.String())
+}
@@ -0,0 +1,45 @@
+package deprecation
+
+import (
+	"FloatService/config"
+	"log/slog"
+	"net/http"
+)
+
+/*
+Применение политики версии API version.
+Для устаревшей версии в каждый ответ добавляется заголовок Deprecation,
+Sunset с датой отключения и Link на версию-замену, если они заданы,
+а каждый вызов пишется в лог с уровнем Warn.
+Для действующей версии запросы передаются дальше без изменений.
+*/
+func New(log *slog.Logger, version string, cfg config.APIVersion) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !cfg.Deprecated {
+			return next
+		}
+
+		log := log.With(
+			slog.String("component", "middleware/deprecation"),
+			slog.String("version", version),
+		)
+
+		log.Info("deprecation middleware enabled")
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			attrs := []any{slog.String("path", r.URL.Path)}
+			w.Header().Set("Deprecation", "true")
+			if !cfg.Sunset.IsZero() {
+				w.Header().Set("Sunset", cfg.Sunset.UTC().Format(http.TimeFormat))
+				attrs = append(attrs, slog.Time("sunset", cfg.Sunset))
+			}
+			if cfg.Successor != "" {
+				w.Header().Add("Link", "<"+cfg.Successor+`>; rel="successor-version"`)
+			}
+			log.Warn("Вызвана устаревшая версия API.", attrs...)
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
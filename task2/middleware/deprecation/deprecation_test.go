@@ -0,0 +1,63 @@
+package deprecation
+
+import (
+	"FloatService/config"
+	"FloatService/nulllogger"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeprecation(t *testing.T) {
+	cases := []struct {
+		name        string
+		cfg         config.APIVersion
+		deprecation string
+		sunset      string
+		link        string
+	}{
+		{
+			name: "Действующая версия",
+		},
+		{
+			name:        "Устаревшая версия",
+			cfg:         config.APIVersion{Deprecated: true},
+			deprecation: "true",
+		},
+		{
+			name: "Дата отключения и замена",
+			cfg: config.APIVersion{
+				Deprecated: true,
+				Sunset:     time.Date(2027, time.January, 1, 3, 0, 0, 0, time.FixedZone("MSK", 3*60*60)),
+				Successor:  "/v2",
+			},
+			deprecation: "true",
+			sunset:      "Fri, 01 Jan 2027 00:00:00 GMT",
+			link:        `</v2>; rel="successor-version"`,
+		},
+		{
+			name:   "Дата отключения у действующей версии не отправляется",
+			cfg:    config.APIVersion{Sunset: time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC)},
+			sunset: "",
+		},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			handler := New(slog.New(&nulllogger.NullLogger{}), "v1", test_case.cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/calculate", nil))
+			require.Equal(t, http.StatusOK, rr.Code)
+			require.Equal(t, test_case.deprecation, rr.Header().Get("Deprecation"))
+			require.Equal(t, test_case.sunset, rr.Header().Get("Sunset"))
+			require.Equal(t, test_case.link, rr.Header().Get("Link"))
+		})
+	}
+}
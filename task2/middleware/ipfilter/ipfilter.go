@@ -0,0 +1,113 @@
+package ipfilter
+
+import (
+	"FloatService/config"
+	"FloatService/i18n"
+	mwLogger "FloatService/middleware/logger"
+	"FloatService/middleware/ratelimit"
+	"FloatService/response"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"sync/atomic"
+)
+
+// правило списка адресов, в лог пишется в виде "deny 203.0.113.0/24"
+type rule struct {
+	prefix netip.Prefix
+	deny   bool
+}
+
+func (r rule) String() string {
+	if r.deny {
+		return "deny " + r.prefix.String()
+	}
+	return "allow " + r.prefix.String()
+}
+
+/*
+Фильтр запросов по спискам адресов клиентов (см. config.IPFilter).
+Запросы из списка deny отклоняются со статусом 403 до любой обработки,
+а запросы из списка allow не ограничиваются лимитом запросов (см. ratelimit.Exempt).
+Сработавшее правило добавляется к записи mwLogger о завершении запроса.
+Списки можно заменить через Update, не останавливая сервер.
+*/
+type Filter struct {
+	log     *slog.Logger
+	trusted []netip.Prefix
+	rules   atomic.Pointer[[]rule]
+}
+
+// фильтр со списками cfg, адрес клиента определяется с учётом доверенных прокси trusted
+func New(log *slog.Logger, cfg config.IPFilter, trusted []netip.Prefix) *Filter {
+	log = log.With(
+		slog.String("component", "middleware/ipfilter"),
+	)
+	f := &Filter{
+		log:     log,
+		trusted: trusted,
+	}
+	f.rules.Store(&[]rule{})
+	if err := f.Update(cfg); err != nil {
+		log.Error("Ошибка в списках адресов.", slog.String("error", err.Error()))
+	}
+	return f
+}
+
+// замена списков, при ошибке действуют прежние списки
+func (f *Filter) Update(cfg config.IPFilter) error {
+	allow, deny, err := cfg.Prefixes()
+	if err != nil {
+		return err
+	}
+	rules := make([]rule, 0, len(allow)+len(deny))
+	for _, prefix := range allow {
+		rules = append(rules, rule{prefix: prefix})
+	}
+	for _, prefix := range deny {
+		rules = append(rules, rule{prefix: prefix, deny: true})
+	}
+	f.rules.Store(&rules)
+	f.log.Info("Списки адресов обновлены.",
+		slog.Any("allow", cfg.Allow),
+		slog.Any("deny", cfg.Deny),
+	)
+	return nil
+}
+
+// самое узкое правило, в которое входит addr, при равных подсетях - deny
+func (f *Filter) match(addr netip.Addr) (rule, bool) {
+	var matched rule
+	found := false
+	for _, r := range *f.rules.Load() {
+		if !r.prefix.Contains(addr) {
+			continue
+		}
+		if !found || r.prefix.Bits() > matched.prefix.Bits() ||
+			(r.prefix.Bits() == matched.prefix.Bits() && r.deny) {
+			matched = r
+			found = true
+		}
+	}
+	return matched, found
+}
+
+func (f *Filter) Middleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		addr := ratelimit.ClientAddr(r, f.trusted)
+		matched, ok := f.match(addr)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		mwLogger.AddAttrs(r, slog.String("ip_rule", matched.String()))
+		if matched.deny {
+			msg := i18n.T(i18n.FromContext(r.Context()), i18n.Forbidden)
+			response.RenderError(w, r, http.StatusForbidden, response.Error(response.CodeForbidden, msg))
+			return
+		}
+		next.ServeHTTP(w, ratelimit.Exempt(r))
+	}
+
+	return http.HandlerFunc(fn)
+}
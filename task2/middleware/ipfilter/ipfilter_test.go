@@ -0,0 +1,161 @@
+package ipfilter
+
+import (
+	"FloatService/config"
+	mwLogger "FloatService/middleware/logger"
+	"FloatService/middleware/ratelimit"
+	"FloatService/nulllogger"
+	"FloatService/response"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+/*
+Фильтр перед лимитом в режиме отказа всем запросам, поэтому запросы
+из списка allow получают 200, остальные пропущенные фильтром - 429.
+Записи mwLogger пишутся в buf.
+*/
+func newTestHandler(f *Filter, buf *bytes.Buffer) http.Handler {
+	rateLimit := ratelimit.New(slog.New(&nulllogger.NullLogger{}), config.RateLimit{Mode: config.ModeDenyAll, Interval: time.Minute}, nil)
+	return mwLogger.New(slog.New(slog.NewJSONHandler(buf, nil)))(f.Middleware(rateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))))
+}
+
+// запрос от remoteAddr, статус ответа и правило из записи mwLogger
+func serve(t *testing.T, f *Filter, remoteAddr string, header http.Header) (int, any) {
+	var buf bytes.Buffer
+	handler := newTestHandler(f, &buf)
+	buf.Reset()
+	req := httptest.NewRequest(http.MethodPost, "/v1/calculate", nil)
+	req.RemoteAddr = remoteAddr
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	if rr.Code == http.StatusForbidden {
+		var resp response.Response
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		require.Equal(t, response.Error(response.CodeForbidden, "Доступ запрещён."), resp)
+	}
+	return rr.Code, entry["ip_rule"]
+}
+
+func TestFilter(t *testing.T) {
+	cfg := config.IPFilter{
+		Allow: []string{"10.0.0.0/8", "2001:db8::/32", "203.0.113.7"},
+		Deny:  []string{"10.1.0.0/16", "203.0.113.0/24", "198.51.100.0/24", "192.0.2.0/24"},
+	}
+	trusted := []netip.Prefix{netip.MustParsePrefix("192.0.2.1/32")}
+	f := New(slog.New(&nulllogger.NullLogger{}), cfg, trusted)
+	cases := []struct {
+		name       string
+		remoteAddr string
+		header     http.Header
+		status     int
+		rule       any
+	}{
+		{
+			name:       "Адрес не из списков",
+			remoteAddr: "8.8.8.8:1234",
+			status:     http.StatusTooManyRequests,
+		},
+		{
+			name:       "Разрешённая подсеть",
+			remoteAddr: "10.2.3.4:1234",
+			status:     http.StatusOK,
+			rule:       "allow 10.0.0.0/8",
+		},
+		{
+			name:       "Запрещённая подсеть внутри разрешённой",
+			remoteAddr: "10.1.2.3:1234",
+			status:     http.StatusForbidden,
+			rule:       "deny 10.1.0.0/16",
+		},
+		{
+			name:       "Разрешённый адрес внутри запрещённой подсети",
+			remoteAddr: "203.0.113.7:1234",
+			status:     http.StatusOK,
+			rule:       "allow 203.0.113.7/32",
+		},
+		{
+			name:       "Запрещённая подсеть",
+			remoteAddr: "198.51.100.10:1234",
+			status:     http.StatusForbidden,
+			rule:       "deny 198.51.100.0/24",
+		},
+		{
+			name:       "IPv6",
+			remoteAddr: "[2001:db8::1]:1234",
+			status:     http.StatusOK,
+			rule:       "allow 2001:db8::/32",
+		},
+		{
+			name:       "IPv4 в IPv6",
+			remoteAddr: "[::ffff:198.51.100.10]:1234",
+			status:     http.StatusForbidden,
+			rule:       "deny 198.51.100.0/24",
+		},
+		{
+			name:       "Клиент за доверенным прокси",
+			remoteAddr: "192.0.2.1:1234",
+			header:     http.Header{"X-Forwarded-For": {"10.2.3.4"}},
+			status:     http.StatusOK,
+			rule:       "allow 10.0.0.0/8",
+		},
+		{
+			name:       "Подмена адреса без доверенного прокси",
+			remoteAddr: "198.51.100.10:1234",
+			header:     http.Header{"X-Forwarded-For": {"10.2.3.4"}},
+			status:     http.StatusForbidden,
+			rule:       "deny 198.51.100.0/24",
+		},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			status, rule := serve(t, f, test_case.remoteAddr, test_case.header)
+			require.Equal(t, test_case.status, status)
+			require.Equal(t, test_case.rule, rule)
+		})
+	}
+}
+
+// при одинаковых подсетях в обоих списках действует deny
+func TestFilter_SamePrefix(t *testing.T) {
+	f := New(slog.New(&nulllogger.NullLogger{}), config.IPFilter{Allow: []string{"10.0.0.0/8"}, Deny: []string{"10.0.0.0/8"}}, nil)
+	status, rule := serve(t, f, "10.0.0.1:1234", nil)
+	require.Equal(t, http.StatusForbidden, status)
+	require.Equal(t, "deny 10.0.0.0/8", rule)
+}
+
+// списки заменяются без остановки, некорректные списки не применяются
+func TestFilter_Update(t *testing.T) {
+	f := New(slog.New(&nulllogger.NullLogger{}), config.IPFilter{}, nil)
+	status, _ := serve(t, f, "198.51.100.10:1234", nil)
+	require.Equal(t, http.StatusTooManyRequests, status)
+
+	require.NoError(t, f.Update(config.IPFilter{Deny: []string{"198.51.100.0/24"}}))
+	status, _ = serve(t, f, "198.51.100.10:1234", nil)
+	require.Equal(t, http.StatusForbidden, status)
+
+	require.Error(t, f.Update(config.IPFilter{Allow: []string{"198.51.100.0/24"}, Deny: []string{"198.51.100.0/33"}}))
+	status, _ = serve(t, f, "198.51.100.10:1234", nil)
+	require.Equal(t, http.StatusForbidden, status)
+
+	require.NoError(t, f.Update(config.IPFilter{}))
+	status, _ = serve(t, f, "198.51.100.10:1234", nil)
+	require.Equal(t, http.StatusTooManyRequests, status)
+}
@@ -0,0 +1,46 @@
+package legacystatus
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+/*
+Режим совместимости для клиентов, ещё не перешедших на новые HTTP статусы:
+ошибки некорректного запроса (400) и ошибки вычислений (422) отдаются со статусом 200,
+а превышение лимита запросов (429) - со статусом 402.
+*/
+func New(log *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/legacystatus"),
+		)
+
+		log.Info("legacy status codes middleware enabled")
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(&legacyWriter{ResponseWriter: w}, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+type legacyWriter struct {
+	http.ResponseWriter
+}
+
+func (w *legacyWriter) WriteHeader(code int) {
+	w.ResponseWriter.WriteHeader(Code(code))
+}
+
+// статус, который сервис отдавал до перехода на новые коды
+func Code(code int) int {
+	switch code {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return http.StatusOK
+	case http.StatusTooManyRequests:
+		return http.StatusPaymentRequired
+	}
+	return code
+}
@@ -0,0 +1,37 @@
+package legacystatus
+
+import (
+	"FloatService/nulllogger"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLegacyStatus(t *testing.T) {
+	cases := []struct {
+		name   string
+		status int
+		legacy int
+	}{
+		{name: "Успех", status: http.StatusOK, legacy: http.StatusOK},
+		{name: "Некорректный запрос", status: http.StatusBadRequest, legacy: http.StatusOK},
+		{name: "Ошибка вычислений", status: http.StatusUnprocessableEntity, legacy: http.StatusOK},
+		{name: "Лимит запросов", status: http.StatusTooManyRequests, legacy: http.StatusPaymentRequired},
+		{name: "Прерванные вычисления", status: http.StatusServiceUnavailable, legacy: http.StatusServiceUnavailable},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			handler := New(slog.New(&nulllogger.NullLogger{}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test_case.status)
+			}))
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+			require.Equal(t, test_case.legacy, rr.Code)
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"context"
 	"net/http"
 	"time"
 
@@ -26,19 +27,31 @@ func New(log *slog.Logger) func(next http.Handler) http.Handler {
 				slog.String("request_id", middleware.GetReqID(r.Context())),
 			)
 			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			var attrs []any
 
 			t1 := time.Now()
 			defer func() {
-				entry.Info("request completed",
+				entry.Info("request completed", append([]any{
 					slog.Int("status", ww.Status()),
 					slog.Int("bytes", ww.BytesWritten()),
 					slog.String("duration", time.Since(t1).String()),
-				)
+				}, attrs...)...)
 			}()
 
-			next.ServeHTTP(ww, r)
+			next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), attrsCtxKey{}, &attrs)))
 		}
 
 		return http.HandlerFunc(fn)
 	}
 }
+
+type attrsCtxKey struct{}
+
+// добавление атрибутов к записи о завершении запроса, без middleware ничего не делает
+func AddAttrs(r *http.Request, attrs ...slog.Attr) {
+	if added, ok := r.Context().Value(attrsCtxKey{}).(*[]any); ok {
+		for _, attr := range attrs {
+			*added = append(*added, attr)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// атрибуты, добавленные обработчиком, попадают в запись о завершении запроса
+func TestAddAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&buf, nil))
+	handler := New(log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AddAttrs(r, slog.String("ip_rule", "deny 10.0.0.0/8"))
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	buf.Reset()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	require.Equal(t, "request completed", entry["msg"])
+	require.Equal(t, float64(http.StatusForbidden), entry["status"])
+	require.Equal(t, "deny 10.0.0.0/8", entry["ip_rule"])
+}
+
+func TestAddAttrs_WithoutMiddleware(t *testing.T) {
+	require.NotPanics(t, func() {
+		AddAttrs(httptest.NewRequest(http.MethodGet, "/", nil), slog.String("ip_rule", "allow 10.0.0.0/8"))
+	})
+}
@@ -0,0 +1,35 @@
+package ratelimit
+
+import (
+	"FloatService/limiter"
+	"context"
+	"errors"
+)
+
+var ErrUnknownPlan = errors.New("неизвестный тариф")
+
+// состояния ключей по тарифам, тарифы, ограничители которых нельзя просмотреть, пропускаются
+func (l Limiters) Keys(ctx context.Context) (map[string][]limiter.KeyState, error) {
+	keys := make(map[string][]limiter.KeyState, len(l))
+	for plan, planLimiter := range l {
+		inspector, ok := planLimiter.(limiter.Inspector)
+		if !ok {
+			continue
+		}
+		states, err := inspector.Keys(ctx)
+		if err != nil {
+			return nil, err
+		}
+		keys[plan] = states
+	}
+	return keys, nil
+}
+
+// сброс лимита ключа key тарифа plan
+func (l Limiters) Reset(ctx context.Context, plan, key string) error {
+	inspector, ok := l[plan].(limiter.Inspector)
+	if !ok {
+		return ErrUnknownPlan
+	}
+	return inspector.Reset(ctx, key)
+}
@@ -0,0 +1,37 @@
+package ratelimit
+
+import (
+	"FloatService/limiter"
+	"FloatService/middleware/ratelimit/mocks"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiters_Inspect(t *testing.T) {
+	clock := limiter.NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	ctx := context.Background()
+	limiters := Limiters{
+		"free":     limiter.NewGCRA(2, time.Minute, 0, clock),
+		"internal": limiter.Multi{},
+		// ограничитель без просмотра состояния
+		"mock": mocks.NewLimiterInt(t),
+	}
+	_, err := limiters["free"].Allow(ctx, "ip=192.0.2.1", 1)
+	require.NoError(t, err)
+	keys, err := limiters.Keys(ctx)
+	require.NoError(t, err)
+	require.Equal(t, map[string][]limiter.KeyState{
+		"free":     {{Key: "ip=192.0.2.1", Windows: []limiter.Window{{Interval: time.Minute, Limit: 2, Remaining: 1, ResetAfter: 30 * time.Second}}}},
+		"internal": {},
+	}, keys)
+
+	require.NoError(t, limiters.Reset(ctx, "free", "ip=192.0.2.1"))
+	keys, err = limiters.Keys(ctx)
+	require.NoError(t, err)
+	require.Empty(t, keys["free"])
+	require.ErrorIs(t, limiters.Reset(ctx, "standard", "ip=192.0.2.1"), ErrUnknownPlan)
+	require.ErrorIs(t, limiters.Reset(ctx, "mock", "ip=192.0.2.1"), ErrUnknownPlan)
+}
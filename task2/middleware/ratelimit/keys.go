@@ -0,0 +1,179 @@
+package ratelimit
+
+import (
+	"FloatService/config"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// функция, возвращающая ключ клиента для запроса
+type KeyFunc func(r *http.Request) string
+
+/*
+Функции ключа лимита по стратегиям cfg.KeyBy: запросы с одинаковым ключом делят один лимит.
+Значение заголовка клиент может менять на каждый запрос, поэтому стратегия api_key
+учитывает только ключи из cfg.KnownAPIKeys и cfg.APIKeys, а стратегия header:<имя>
+дополняет значение заголовка IP клиента и только сужает лимит по IP (см. requestKeys).
+Клиент без известного ключа или без заголовка получает ключ по IP,
+чтобы анонимные клиенты не делили один общий лимит.
+*/
+func KeyFuncs(cfg config.RateLimit, trusted []netip.Prefix) []KeyFunc {
+	keyFuncs := make([]KeyFunc, 0, len(cfg.KeyBy))
+	for _, key := range cfg.KeyBy {
+		switch {
+		case key == config.KeyByIP:
+			keyFuncs = append(keyFuncs, func(r *http.Request) string {
+				return "ip=" + ClientIP(r, trusted)
+			})
+		case key == config.KeyByAPIKey:
+			keyFuncs = append(keyFuncs, keyByAPIKey(cfg, trusted))
+		case strings.HasPrefix(key, config.KeyByHeaderPrefix):
+			keyFuncs = append(keyFuncs, keyByHeader(strings.TrimPrefix(key, config.KeyByHeaderPrefix), trusted))
+		}
+	}
+	return keyFuncs
+}
+
+func keyByAPIKey(cfg config.RateLimit, trusted []netip.Prefix) KeyFunc {
+	known := make(map[string]bool, len(cfg.KnownAPIKeys)+len(cfg.APIKeys))
+	for _, key := range cfg.KnownAPIKeys {
+		known[key] = true
+	}
+	for key := range cfg.APIKeys {
+		known[key] = true
+	}
+	return func(r *http.Request) string {
+		if apiKey := r.Header.Get(cfg.APIKeyHeader); known[apiKey] {
+			return apiKeyID(apiKey)
+		}
+		return "ip=" + ClientIP(r, trusted)
+	}
+}
+
+func keyByHeader(header string, trusted []netip.Prefix) KeyFunc {
+	header = http.CanonicalHeaderKey(header)
+	return func(r *http.Request) string {
+		key := "ip=" + ClientIP(r, trusted)
+		if value := r.Header.Get(header); value != "" {
+			key += "," + header + "=" + value
+		}
+		return key
+	}
+}
+
+/*
+Ключ лимита клиента с API ключом. Сам API ключ - секрет, поэтому в ключ лимита,
+а с ним в имена ключей Redis, файл квот, /admin/ratelimit/keys и логи, попадает только
+начало его хэша SHA-256.
+*/
+func apiKeyID(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return "api_key=" + hex.EncodeToString(sum[:8])
+}
+
+// функции ключа по тем же стратегиям, в которых header:<имя> заменены на ip
+func ipKeyFuncs(cfg config.RateLimit, trusted []netip.Prefix) []KeyFunc {
+	keyBy := make([]string, 0, len(cfg.KeyBy))
+	for _, key := range cfg.KeyBy {
+		if strings.HasPrefix(key, config.KeyByHeaderPrefix) {
+			key = config.KeyByIP
+		}
+		keyBy = append(keyBy, key)
+	}
+	cfg.KeyBy = keyBy
+	return KeyFuncs(cfg, trusted)
+}
+
+/*
+Ключи лимита, с которых списывается запрос: ключ по стратегиям keyFuncs, а если
+в него попали значения заголовков стратегий header:<имя>, ещё и ключ по ipKeyFuncs без них.
+Меняя значение заголовка, клиент получает новый лимит заголовка, но не новый лимит по IP.
+*/
+func requestKeys(r *http.Request, keyFuncs, ipKeyFuncs []KeyFunc) []string {
+	key := requestKey(r, keyFuncs)
+	if ipKey := requestKey(r, ipKeyFuncs); ipKey != key {
+		return []string{key, ipKey}
+	}
+	return []string{key}
+}
+
+// ключ лимита из ключей всех стратегий, без стратегий ключ у всех запросов один
+func requestKey(r *http.Request, keyFuncs []KeyFunc) string {
+	keys := make([]string, len(keyFuncs))
+	for i, keyFunc := range keyFuncs {
+		keys[i] = keyFunc(r)
+	}
+	return strings.Join(keys, "|")
+}
+
+/*
+IP клиента для лимита (см. ClientAddr), адреса IPv6 сокращаются до подсети /64,
+которую обычно получает один клиент. Если адрес не разобран, возвращается RemoteAddr.
+*/
+func ClientIP(r *http.Request, trusted []netip.Prefix) string {
+	client := ClientAddr(r, trusted)
+	if !client.IsValid() {
+		return r.RemoteAddr
+	}
+	if client.Is6() {
+		return netip.PrefixFrom(client, 64).Masked().String()
+	}
+	return client.String()
+}
+
+/*
+Адрес клиента. Если запрос пришёл от доверенного прокси, адрес берётся из X-Forwarded-For:
+цепочка просматривается справа налево до первого адреса не из trusted,
+так как левые адреса клиент может подставить сам. Без X-Forwarded-For используется X-Real-IP.
+Если RemoteAddr не удалось разобрать, возвращается пустой адрес.
+*/
+func ClientAddr(r *http.Request, trusted []netip.Prefix) netip.Addr {
+	remote := parseAddr(r.RemoteAddr)
+	if !remote.IsValid() {
+		return remote
+	}
+	client := remote
+	if isTrusted(remote, trusted) {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			hops := strings.Split(strings.Join(forwarded, ","), ",")
+			for i := len(hops) - 1; i >= 0; i-- {
+				hop := parseAddr(strings.TrimSpace(hops[i]))
+				if !hop.IsValid() {
+					break
+				}
+				client = hop
+				if !isTrusted(hop, trusted) {
+					break
+				}
+			}
+		} else if realIP := parseAddr(r.Header.Get("X-Real-IP")); realIP.IsValid() {
+			client = realIP
+		}
+	}
+	return client
+}
+
+// разбор адреса с портом или без него
+func parseAddr(s string) netip.Addr {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap().WithZone("")
+}
+
+func isTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,254 @@
+package ratelimit
+
+import (
+	"FloatService/config"
+	"FloatService/limiter"
+	"FloatService/nulllogger"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.168.1.1/32"),
+	}
+	cases := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		realIP     string
+		ip         string
+	}{
+		{
+			name:       "Без прокси",
+			remoteAddr: "203.0.113.5:1234",
+			ip:         "203.0.113.5",
+		},
+		{
+			name:       "X-Forwarded-For от недоверенного адреса игнорируется",
+			remoteAddr: "203.0.113.5:1234",
+			forwarded:  []string{"198.51.100.1"},
+			ip:         "203.0.113.5",
+		},
+		{
+			name:       "Цепочка доверенных прокси",
+			remoteAddr: "10.0.0.2:1234",
+			forwarded:  []string{"1.1.1.1, 198.51.100.1, 192.168.1.1"},
+			ip:         "198.51.100.1",
+		},
+		{
+			name:       "Несколько заголовков X-Forwarded-For",
+			remoteAddr: "10.0.0.2:1234",
+			forwarded:  []string{"198.51.100.1", "10.0.0.3"},
+			ip:         "198.51.100.1",
+		},
+		{
+			name:       "Все адреса доверенные",
+			remoteAddr: "10.0.0.2:1234",
+			forwarded:  []string{"10.0.0.4, 10.0.0.3"},
+			ip:         "10.0.0.4",
+		},
+		{
+			name:       "Некорректный адрес в цепочке",
+			remoteAddr: "10.0.0.2:1234",
+			forwarded:  []string{"198.51.100.1, unknown, 10.0.0.3"},
+			ip:         "10.0.0.3",
+		},
+		{
+			name:       "X-Real-IP от доверенного прокси",
+			remoteAddr: "192.168.1.1:1234",
+			realIP:     "198.51.100.7",
+			ip:         "198.51.100.7",
+		},
+		{
+			name:       "X-Real-IP от недоверенного адреса игнорируется",
+			remoteAddr: "192.168.1.2:1234",
+			realIP:     "198.51.100.7",
+			ip:         "192.168.1.2",
+		},
+		{
+			name:       "IPv6 сокращается до /64",
+			remoteAddr: "[2001:db8:1:2:3:4:5:6]:1234",
+			ip:         "2001:db8:1:2::/64",
+		},
+		{
+			name:       "IPv4 в IPv6",
+			remoteAddr: "[::ffff:203.0.113.5]:1234",
+			ip:         "203.0.113.5",
+		},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = test_case.remoteAddr
+			for _, forwarded := range test_case.forwarded {
+				req.Header.Add("X-Forwarded-For", forwarded)
+			}
+			if test_case.realIP != "" {
+				req.Header.Set("X-Real-IP", test_case.realIP)
+			}
+			require.Equal(t, test_case.ip, ClientIP(req, trusted))
+		})
+	}
+}
+
+// у каждого клиента свой лимит
+func TestRateLimit_Keys(t *testing.T) {
+	cases := []struct {
+		name     string
+		keyBy    []string
+		requests [][2]string // IP и API ключ
+		statuses []int
+	}{
+		{
+			name:     "По IP",
+			keyBy:    []string{config.KeyByIP},
+			requests: [][2]string{{"203.0.113.1", ""}, {"203.0.113.2", ""}, {"203.0.113.1", "a"}},
+			statuses: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:     "По API ключу",
+			keyBy:    []string{config.KeyByAPIKey},
+			requests: [][2]string{{"203.0.113.1", "a"}, {"203.0.113.2", "b"}, {"203.0.113.3", "a"}},
+			statuses: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:     "Без API ключа лимит по IP",
+			keyBy:    []string{config.KeyByAPIKey},
+			requests: [][2]string{{"203.0.113.1", ""}, {"203.0.113.2", ""}, {"203.0.113.1", ""}},
+			statuses: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:     "Неизвестный API ключ не даёт нового лимита",
+			keyBy:    []string{config.KeyByAPIKey},
+			requests: [][2]string{{"203.0.113.1", "x"}, {"203.0.113.1", "y"}},
+			statuses: []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:     "По произвольному заголовку",
+			keyBy:    []string{config.KeyByHeaderPrefix + "x-api-key"},
+			requests: [][2]string{{"203.0.113.1", "a"}, {"203.0.113.2", "a"}, {"203.0.113.3", "b"}},
+			statuses: []int{http.StatusOK, http.StatusOK, http.StatusOK},
+		},
+		{
+			name:     "Смена значения заголовка не даёт нового лимита по IP",
+			keyBy:    []string{config.KeyByHeaderPrefix + "x-api-key"},
+			requests: [][2]string{{"203.0.113.1", "a"}, {"203.0.113.1", "b"}, {"203.0.113.1", ""}},
+			statuses: []int{http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests},
+		},
+		{
+			name:     "По IP и API ключу",
+			keyBy:    []string{config.KeyByIP, config.KeyByAPIKey},
+			requests: [][2]string{{"203.0.113.1", "a"}, {"203.0.113.1", "b"}, {"203.0.113.2", "a"}, {"203.0.113.1", "a"}},
+			statuses: []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:     "Общий лимит",
+			requests: [][2]string{{"203.0.113.1", "a"}, {"203.0.113.2", "b"}},
+			statuses: []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := config.RateLimit{
+				Limit:        1,
+				Interval:     time.Minute,
+				KeyBy:        test_case.keyBy,
+				APIKeyHeader: "X-API-Key",
+				KnownAPIKeys: []string{"a", "b"},
+			}
+			rateLimiter := limiter.NewGCRA(cfg.Limit, cfg.Interval, 0, limiter.SystemClock{})
+			handler := New(slog.New(&nulllogger.NullLogger{}), cfg, Limiters{"": rateLimiter})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			for i, request := range test_case.requests {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.RemoteAddr = request[0] + ":1234"
+				if request[1] != "" {
+					req.Header.Set("X-API-Key", request[1])
+				}
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, req)
+				require.Equal(t, test_case.statuses[i], rr.Code, "запрос %d", i)
+			}
+		})
+	}
+}
+
+// в ключ лимита попадает хэш API ключа, а не сам ключ
+func TestKeyFuncs_APIKeyHash(t *testing.T) {
+	cfg := config.RateLimit{
+		KeyBy:        []string{config.KeyByAPIKey},
+		APIKeyHeader: "X-API-Key",
+		KnownAPIKeys: []string{"secret"},
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-API-Key", "secret")
+	key := requestKey(req, KeyFuncs(cfg, nil))
+	require.Equal(t, "api_key=2bb80d537b1da3e3", key)
+	require.NotContains(t, key, "secret")
+	cfg.Plans = map[string]config.Plan{"free": {}, "pro": {}}
+	cfg.DefaultPlan = "free"
+	cfg.APIKeys = map[string]string{"secret": "pro"}
+	plan, keys := clientPlan(req, cfg, KeyFuncs(cfg, nil), ipKeyFuncs(cfg, nil))
+	require.Equal(t, "pro", plan)
+	require.Equal(t, []string{"api_key=2bb80d537b1da3e3"}, keys)
+}
+
+// запрос с заголовком списывается и с лимита по IP, который клиент получил бы без заголовка
+func TestRequestKeys(t *testing.T) {
+	cases := []struct {
+		name   string
+		keyBy  []string
+		header string
+		keys   []string
+	}{
+		{
+			name:   "С заголовком",
+			keyBy:  []string{config.KeyByHeaderPrefix + "x-user"},
+			header: "alice",
+			keys:   []string{"ip=203.0.113.1,X-User=alice", "ip=203.0.113.1"},
+		},
+		{
+			name:  "Без заголовка",
+			keyBy: []string{config.KeyByHeaderPrefix + "x-user"},
+			keys:  []string{"ip=203.0.113.1"},
+		},
+		{
+			name:   "Заголовок вместе с другими стратегиями",
+			keyBy:  []string{config.KeyByIP, config.KeyByHeaderPrefix + "x-user"},
+			header: "alice",
+			keys:   []string{"ip=203.0.113.1|ip=203.0.113.1,X-User=alice", "ip=203.0.113.1|ip=203.0.113.1"},
+		},
+		{
+			name:   "Без стратегии заголовка",
+			keyBy:  []string{config.KeyByIP},
+			header: "alice",
+			keys:   []string{"ip=203.0.113.1"},
+		},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := config.RateLimit{KeyBy: test_case.keyBy}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "203.0.113.1:1234"
+			if test_case.header != "" {
+				req.Header.Set("X-User", test_case.header)
+			}
+			require.Equal(t, test_case.keys, requestKeys(req, KeyFuncs(cfg, nil), ipKeyFuncs(cfg, nil)))
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package ratelimit
+
+import (
+	"FloatService/config"
+	"FloatService/i18n"
+	"FloatService/limiter"
+	"FloatService/response"
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=LimiterInt
+type LimiterInt interface {
+	Allow(ctx context.Context, key string, cost int) (limiter.Result, error)
+}
+
+// ограничители по тарифам, без тарифов в конфигурации используется ограничитель с пустым именем
+type Limiters map[string]LimiterInt
+
+/*
+Ограничение количества запросов отдельно для каждого ключа клиента (см. KeyFuncs),
+без стратегий ключа в cfg.KeyBy лимит общий для всех клиентов.
+Лимит проверяется ограничителем тарифа клиента (см. clientPlan),
+а запрос расходует столько запросов, сколько стоит его маршрут в cfg.RouteCosts,
+и вес вычислений, который обработчик списывает через ChargeCalculation.
+В каждый ответ с ограниченным тарифом добавляются заголовки RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, RateLimit-Cost
+(и X-RateLimit-* с временем сброса в unix секундах для прежних клиентов),
+а при превышении лимита - Retry-After и статус 429 с json ответом.
+Сообщение берётся из файла конфигурации, а если оно там не задано,
+переводится на язык клиента. Если ограничитель вернул ошибку, запрос пропускается.
+В режиме config.ModeDisabled запросы не ограничиваются, а в режиме config.ModeDenyAll
+все запросы отклоняются с Retry-After через cfg.Interval. Запросы, отмеченные Exempt, не ограничиваются.
+*/
+func New(log *slog.Logger, cfg config.RateLimit, limiters Limiters) func(next http.Handler) http.Handler {
+	log = log.With(
+		slog.String("component", "middleware/ratelimit"),
+	)
+	switch cfg.Mode {
+	case config.ModeDisabled:
+		log.Info("rate limit middleware disabled")
+		return func(next http.Handler) http.Handler {
+			return next
+		}
+	case config.ModeDenyAll:
+		log.Info("rate limit middleware denies all requests")
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if isExempt(r) {
+					next.ServeHTTP(w, r)
+					return
+				}
+				log.Warn("Достигнут лимит запросов.")
+				deny(w, r, cfg, cfg.Interval)
+			})
+		}
+	}
+	trusted, err := cfg.TrustedPrefixes()
+	if err != nil {
+		log.Error("Некорректный список доверенных прокси.", slog.String("error", err.Error()))
+	}
+	keyFuncs := KeyFuncs(cfg, trusted)
+	ipKeyFuncs := ipKeyFuncs(cfg, trusted)
+	log.Info("rate limit middleware enabled",
+		slog.Int("limit", cfg.Limit),
+		slog.String("interval", cfg.Interval.String()),
+		slog.Int("burst", cfg.Burst),
+		slog.Any("key_by", cfg.KeyBy),
+		slog.Any("trusted_proxies", cfg.TrustedProxies),
+		slog.String("redis", cfg.Redis.Address),
+		slog.Int("plans", len(cfg.Plans)),
+		slog.Any("route_costs", cfg.RouteCosts),
+		slog.Any("calculation_cost", cfg.CalculationCost),
+	)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isExempt(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			plan, keys := clientPlan(r, cfg, keyFuncs, ipKeyFuncs)
+			rateLimiter, ok := limiters[plan]
+			if !ok {
+				log.Error("Ошибка проверки лимита запросов.", slog.String("error", "нет ограничителя тарифа "+plan))
+				next.ServeHTTP(w, r)
+				return
+			}
+			c := &charger{
+				log:     log.With(slog.String("plan", plan)),
+				cfg:     cfg,
+				limiter: rateLimiter,
+				keys:    keys,
+			}
+			if !c.charge(w, r, cfg.RouteCost(r.Method, r.URL.Path)) {
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), chargerCtxKey{}, c)))
+		})
+	}
+}
+
+type exemptCtxKey struct{}
+
+// запрос, который не ограничивается лимитом ни в каком режиме, например от внутреннего клиента
+func Exempt(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), exemptCtxKey{}, true))
+}
+
+func isExempt(r *http.Request) bool {
+	exempt, _ := r.Context().Value(exemptCtxKey{}).(bool)
+	return exempt
+}
+
+type chargerCtxKey struct{}
+
+// списание запросов с лимитов одного клиента по ключам keys, cost - сколько уже списано за текущий запрос
+type charger struct {
+	log     *slog.Logger
+	cfg     config.RateLimit
+	limiter LimiterInt
+	keys    []string
+	cost    int
+}
+
+// списание cost запросов, при превышении лимита отправляет ответ 429 и возвращает false
+func (c *charger) charge(w http.ResponseWriter, r *http.Request, cost int) bool {
+	res, err := c.allow(r.Context(), cost)
+	if err != nil {
+		c.log.Error("Ошибка проверки лимита запросов.", slog.String("error", err.Error()))
+		return true
+	}
+	if res.Allowed {
+		c.cost += cost
+	}
+	setRateLimitHeaders(w.Header(), res, c.cost)
+	if res.Allowed {
+		return true
+	}
+	c.log.Warn("Достигнут лимит запросов.")
+	deny(w, r, c.cfg, res.RetryAfter)
+	return false
+}
+
+/*
+Списание cost запросов с лимитов всех ключей по очереди, как в limiter.Multi:
+до первого отказа, а при пропуске возвращается ответ с наименьшим остатком.
+*/
+func (c *charger) allow(ctx context.Context, cost int) (limiter.Result, error) {
+	result := limiter.Result{Allowed: true}
+	for i, key := range c.keys {
+		res, err := c.limiter.Allow(ctx, key, cost)
+		if err != nil || !res.Allowed {
+			return res, err
+		}
+		if i == 0 || res.Remaining < result.Remaining ||
+			(res.Remaining == result.Remaining && res.ResetAfter > result.ResetAfter) {
+			result = res
+		}
+	}
+	return result, nil
+}
+
+// ответ 429 с заголовком Retry-After, через сколько секунд повторить запрос
+func deny(w http.ResponseWriter, r *http.Request, cfg config.RateLimit, retryAfter time.Duration) {
+	msg := cfg.Msg
+	if msg == "" {
+		msg = i18n.T(i18n.FromContext(r.Context()), i18n.RateLimited)
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(max(ceilSeconds(retryAfter), 1)))
+	response.RenderError(w, r, http.StatusTooManyRequests, response.Error(response.CodeRateLimited, msg))
+}
+
+/*
+Списание веса запроса вычислений с точностью precision и операндами из digits цифр
+(см. config.CalculationCost) сверх стоимости маршрута, списанной middleware.
+Обновляет заголовки RateLimit-*, а если лимита не хватает, отправляет ответ 429 и возвращает false;
+стоимость маршрута при этом остаётся списанной. Без middleware ничего не списывается.
+*/
+func ChargeCalculation(w http.ResponseWriter, r *http.Request, precision int32, digits int) bool {
+	c, ok := r.Context().Value(chargerCtxKey{}).(*charger)
+	if !ok {
+		return true
+	}
+	extra := c.cfg.Extra(precision, digits)
+	if extra == 0 {
+		return true
+	}
+	return c.charge(w, r, extra)
+}
+
+/*
+Тариф клиента и ключи его лимита. Клиент с API ключом из cfg.APIKeys получает тариф ключа
+и лимит по ключу, остальные - тариф по умолчанию и ключи по стратегиям cfg.KeyBy (см. requestKeys).
+*/
+func clientPlan(r *http.Request, cfg config.RateLimit, keyFuncs, ipKeyFuncs []KeyFunc) (plan string, keys []string) {
+	if len(cfg.Plans) == 0 {
+		return "", requestKeys(r, keyFuncs, ipKeyFuncs)
+	}
+	if apiKey := r.Header.Get(cfg.APIKeyHeader); apiKey != "" {
+		if plan, ok := cfg.APIKeys[apiKey]; ok {
+			return plan, []string{apiKeyID(apiKey)}
+		}
+	}
+	return cfg.DefaultPlan, requestKeys(r, keyFuncs, ipKeyFuncs)
+}
+
+/*
+заголовки с состоянием лимита клиента и RateLimit-Cost со стоимостью,
+списанной за текущий запрос; у тарифа без ограничений их нет
+*/
+func setRateLimitHeaders(h http.Header, res limiter.Result, cost int) {
+	if res.Limit == 0 {
+		return
+	}
+	h.Set("RateLimit-Cost", strconv.Itoa(cost))
+	limit := strconv.Itoa(res.Limit)
+	remaining := strconv.Itoa(res.Remaining)
+	reset := ceilSeconds(res.ResetAfter)
+	h.Set("RateLimit-Limit", limit)
+	h.Set("RateLimit-Remaining", remaining)
+	h.Set("RateLimit-Reset", strconv.Itoa(reset))
+	h.Set("X-RateLimit-Limit", limit)
+	h.Set("X-RateLimit-Remaining", remaining)
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(res.ResetAfter).Unix(), 10))
+}
+
+// длительность в секундах с округлением вверх
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,361 @@
+package ratelimit
+
+import (
+	"FloatService/config"
+	"FloatService/i18n"
+	"FloatService/limiter"
+	"FloatService/middleware/ratelimit/mocks"
+	"FloatService/nulllogger"
+	"FloatService/response"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	cfg := config.RateLimit{
+		Limit:    2,
+		Interval: time.Minute,
+		Msg:      "Тест.",
+	}
+	clock := limiter.NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	rateLimiter := limiter.NewGCRA(cfg.Limit, cfg.Interval, 0, clock)
+	handler := New(slog.New(&nulllogger.NullLogger{}), cfg, Limiters{"": rateLimiter})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for i := 0; i < cfg.Limit; i++ {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Equal(t, "2", rr.Header().Get("RateLimit-Limit"))
+		require.Equal(t, strconv.Itoa(cfg.Limit-i-1), rr.Header().Get("RateLimit-Remaining"))
+		require.Equal(t, strconv.Itoa(30*(i+1)), rr.Header().Get("RateLimit-Reset"))
+		require.Equal(t, "2", rr.Header().Get("X-RateLimit-Limit"))
+		require.NotEmpty(t, rr.Header().Get("X-RateLimit-Reset"))
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+	require.Equal(t, "0", rr.Header().Get("RateLimit-Remaining"))
+	require.Equal(t, "30", rr.Header().Get("Retry-After"))
+	var resp response.Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Equal(t, response.Error(response.CodeRateLimited, "Тест."), resp)
+	// через Retry-After запрос снова пропускается
+	clock.Advance(30 * time.Second)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "0", rr.Header().Get("RateLimit-Remaining"))
+}
+
+func TestRateLimit_Modes(t *testing.T) {
+	cases := []struct {
+		name       string
+		mode       string
+		statuses   []int
+		retryAfter string
+		limit      string
+	}{
+		{
+			name:       "Лимит включён",
+			mode:       config.ModeEnabled,
+			statuses:   []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+			retryAfter: "30",
+			limit:      "2",
+		},
+		{
+			name:     "Лимит отключён",
+			mode:     config.ModeDisabled,
+			statuses: []int{http.StatusOK, http.StatusOK, http.StatusOK},
+		},
+		{
+			name:       "Отказ всем запросам",
+			mode:       config.ModeDenyAll,
+			statuses:   []int{http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusTooManyRequests},
+			retryAfter: "60",
+		},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := config.RateLimit{
+				Mode:     test_case.mode,
+				Limit:    2,
+				Interval: time.Minute,
+				Msg:      "Тест.",
+			}
+			clock := limiter.NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			limiters := Limiters{"": limiter.NewGCRA(cfg.Limit, cfg.Interval, 0, clock)}
+			handler := New(slog.New(&nulllogger.NullLogger{}), cfg, limiters)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			var rr *httptest.ResponseRecorder
+			for i, status := range test_case.statuses {
+				rr = httptest.NewRecorder()
+				handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+				require.Equal(t, status, rr.Code, "запрос %d", i)
+			}
+			require.Equal(t, test_case.retryAfter, rr.Header().Get("Retry-After"))
+			require.Equal(t, test_case.limit, rr.Header().Get("RateLimit-Limit"))
+			if rr.Code == http.StatusTooManyRequests {
+				var resp response.Response
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+				require.Equal(t, response.Error(response.CodeRateLimited, "Тест."), resp)
+			}
+		})
+	}
+}
+
+// отмеченные запросы не ограничиваются и не расходуют лимит
+func TestRateLimit_Exempt(t *testing.T) {
+	for _, mode := range []string{config.ModeEnabled, config.ModeDenyAll} {
+		cfg := config.RateLimit{
+			Mode:     mode,
+			Limit:    1,
+			Interval: time.Minute,
+		}
+		limiters := Limiters{"": limiter.NewGCRA(cfg.Limit, cfg.Interval, 0, limiter.SystemClock{})}
+		handler := New(slog.New(&nulllogger.NullLogger{}), cfg, limiters)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		for i := 0; i < 3; i++ {
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, Exempt(httptest.NewRequest(http.MethodGet, "/", nil)))
+			require.Equal(t, http.StatusOK, rr.Code, mode)
+			require.Empty(t, rr.Header().Get("RateLimit-Limit"), mode)
+		}
+	}
+}
+
+// при ошибке ограничителя запрос пропускается
+func TestRateLimit_LimiterError(t *testing.T) {
+	cfg := config.RateLimit{
+		Limit:    1,
+		Interval: time.Minute,
+	}
+	rateLimiter := mocks.NewLimiterInt(t)
+	rateLimiter.On("Allow", mock.Anything, "", 1).Return(limiter.Result{}, errors.New("недоступен")).Once()
+	handler := New(slog.New(&nulllogger.NullLogger{}), cfg, Limiters{"": rateLimiter})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Empty(t, rr.Header().Get("RateLimit-Limit"))
+}
+
+// без сообщения в конфигурации оно переводится на язык клиента
+func TestRateLimit_Localized(t *testing.T) {
+	cfg := config.RateLimit{
+		Limit:    1,
+		Interval: time.Minute,
+	}
+	rateLimiter := limiter.NewGCRA(cfg.Limit, cfg.Interval, 0, limiter.SystemClock{})
+	handler := i18n.New(i18n.RU)(New(slog.New(&nulllogger.NullLogger{}), cfg, Limiters{"": rateLimiter})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+	cases := []struct {
+		name           string
+		acceptLanguage string
+		msg            string
+	}{
+		{
+			name: "Язык по умолчанию",
+			msg:  "Слишком много запросов.",
+		},
+		{
+			name:           "Английский",
+			acceptLanguage: "en-US,en;q=0.9",
+			msg:            "Too many requests.",
+		},
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	for _, test_case := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Language", test_case.acceptLanguage)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusTooManyRequests, rr.Code, test_case.name)
+		var resp response.Response
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		require.Equal(t, test_case.msg, resp.Error, test_case.name)
+	}
+}
+
+// лимит по тарифу API ключа и стоимости маршрута
+func TestRateLimit_Plans(t *testing.T) {
+	cfg := config.RateLimit{
+		KeyBy:        []string{config.KeyByIP},
+		APIKeyHeader: "X-API-Key",
+		Plans: map[string]config.Plan{
+			"free":     {PerSecond: 1},
+			"standard": {PerSecond: 3},
+			"internal": {},
+		},
+		DefaultPlan: "free",
+		APIKeys:     map[string]string{"std": "standard", "int": "internal"},
+		RouteCosts:  map[string]int{"POST /v2/calculate": 2},
+	}
+	type request struct {
+		method    string
+		path      string
+		apiKey    string
+		status    int
+		remaining string
+	}
+	cases := []struct {
+		name     string
+		requests []request
+	}{
+		{
+			name: "Без ключа тариф по умолчанию",
+			requests: []request{
+				{method: http.MethodPost, path: "/v1/calculate", status: http.StatusOK, remaining: "0"},
+				{method: http.MethodPost, path: "/v1/calculate", status: http.StatusTooManyRequests, remaining: "0"},
+			},
+		},
+		{
+			name: "Неизвестный ключ получает тариф по умолчанию",
+			requests: []request{
+				{method: http.MethodPost, path: "/v1/calculate", apiKey: "unknown", status: http.StatusOK, remaining: "0"},
+				{method: http.MethodPost, path: "/v1/calculate", apiKey: "unknown", status: http.StatusTooManyRequests, remaining: "0"},
+			},
+		},
+		{
+			name: "Тариф ключа",
+			requests: []request{
+				{method: http.MethodPost, path: "/v1/calculate", apiKey: "std", status: http.StatusOK, remaining: "2"},
+				{method: http.MethodPost, path: "/v1/calculate", apiKey: "std", status: http.StatusOK, remaining: "1"},
+				{method: http.MethodPost, path: "/v1/calculate", apiKey: "std", status: http.StatusOK, remaining: "0"},
+				{method: http.MethodPost, path: "/v1/calculate", apiKey: "std", status: http.StatusTooManyRequests, remaining: "0"},
+			},
+		},
+		{
+			name: "Стоимость маршрута",
+			requests: []request{
+				{method: http.MethodPost, path: "/v2/calculate", apiKey: "std", status: http.StatusOK, remaining: "1"},
+				{method: http.MethodPost, path: "/v2/calculate", apiKey: "std", status: http.StatusTooManyRequests, remaining: "1"},
+				{method: http.MethodGet, path: "/v2/calculate", apiKey: "std", status: http.StatusOK, remaining: "0"},
+			},
+		},
+		{
+			name: "Тариф без ограничений",
+			requests: []request{
+				{method: http.MethodPost, path: "/v2/calculate", apiKey: "int", status: http.StatusOK},
+				{method: http.MethodPost, path: "/v2/calculate", apiKey: "int", status: http.StatusOK},
+				{method: http.MethodPost, path: "/v2/calculate", apiKey: "int", status: http.StatusOK},
+			},
+		},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			clock := limiter.NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			limiters := Limiters{
+				"free":     limiter.NewGCRA(1, time.Second, 0, clock),
+				"standard": limiter.NewGCRA(3, time.Second, 0, clock),
+				"internal": limiter.Multi{},
+			}
+			handler := New(slog.New(&nulllogger.NullLogger{}), cfg, limiters)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			for i, request := range test_case.requests {
+				req := httptest.NewRequest(request.method, request.path, nil)
+				if request.apiKey != "" {
+					req.Header.Set("X-API-Key", request.apiKey)
+				}
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, req)
+				require.Equal(t, request.status, rr.Code, "запрос %d", i)
+				require.Equal(t, request.remaining, rr.Header().Get("RateLimit-Remaining"), "запрос %d", i)
+			}
+		})
+	}
+}
+
+// вес вычислений списывается сверх стоимости маршрута
+func TestChargeCalculation(t *testing.T) {
+	cfg := config.RateLimit{
+		Limit:           10,
+		Interval:        time.Minute,
+		CalculationCost: config.CalculationCost{PrecisionStep: 100, DigitsStep: 1000, MaxExtra: 8},
+	}
+	type request struct {
+		precision int32
+		digits    int
+		status    int
+		cost      string
+		remaining string
+	}
+	cases := []struct {
+		name     string
+		requests []request
+	}{
+		{
+			name: "Обычный запрос",
+			requests: []request{
+				{precision: 5, digits: 10, status: http.StatusOK, cost: "1", remaining: "9"},
+			},
+		},
+		{
+			name: "Высокая точность и большие операнды",
+			requests: []request{
+				{precision: 500, digits: 2000, status: http.StatusOK, cost: "8", remaining: "2"},
+			},
+		},
+		{
+			name: "Вес не больше max_extra",
+			requests: []request{
+				{precision: 1000, digits: 5000, status: http.StatusOK, cost: "9", remaining: "1"},
+			},
+		},
+		{
+			name: "Не хватает лимита на вес",
+			requests: []request{
+				{precision: 700, status: http.StatusOK, cost: "8", remaining: "2"},
+				{precision: 200, status: http.StatusTooManyRequests, cost: "1", remaining: "1"},
+				{precision: 0, status: http.StatusOK, cost: "1", remaining: "0"},
+			},
+		},
+	}
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			clock := limiter.NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			rateLimiter := limiter.NewGCRA(cfg.Limit, cfg.Interval, 0, clock)
+			for i, request := range test_case.requests {
+				handler := New(slog.New(&nulllogger.NullLogger{}), cfg, Limiters{"": rateLimiter})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if ChargeCalculation(w, r, request.precision, request.digits) {
+						w.WriteHeader(http.StatusOK)
+					}
+				}))
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/calculate", nil))
+				require.Equal(t, request.status, rr.Code, "запрос %d", i)
+				require.Equal(t, request.cost, rr.Header().Get("RateLimit-Cost"), "запрос %d", i)
+				require.Equal(t, request.remaining, rr.Header().Get("RateLimit-Remaining"), "запрос %d", i)
+			}
+		})
+	}
+}
+
+// без middleware вес не списывается
+func TestChargeCalculation_WithoutMiddleware(t *testing.T) {
+	rr := httptest.NewRecorder()
+	require.True(t, ChargeCalculation(rr, httptest.NewRequest(http.MethodPost, "/v1/calculate", nil), 1000, 5000))
+	require.Empty(t, rr.Header())
+}
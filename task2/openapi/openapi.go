@@ -0,0 +1,239 @@
+package openapi
+
+import (
+	"FloatService/codec"
+	"FloatService/response"
+	"embed"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// спецификация OpenAPI 3
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+type Operation struct {
+	Summary     string               `json:"summary,omitempty"`
+	Deprecated  bool                 `json:"deprecated,omitempty"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+/*
+Описание маршрута, по которому строится операция:
+Body - тип JSON тела запроса, Query - тип, скалярные поля которого
+передаются в строке запроса, Results - типы успешного ответа (несколько - oneOf),
+Errors - HTTP статусы ошибок, кроме 429, который добавляется ко всем операциям,
+не отмеченным Unlimited.
+*/
+type Route struct {
+	Method     string
+	Path       string
+	Summary    string
+	Deprecated bool
+	Body       any
+	Query      any
+	Results    []any
+	Errors     []int
+	Unlimited  bool
+}
+
+func New(info Info) *Document {
+	return &Document{
+		OpenAPI:    "3.0.3",
+		Info:       info,
+		Paths:      map[string]map[string]*Operation{},
+		Components: Components{Schemas: map[string]*Schema{}},
+	}
+}
+
+// добавление операции, схемы строятся по Go типам из route
+func (d *Document) Add(route Route) {
+	statuses := append([]int{}, route.Errors...)
+	operation := &Operation{
+		Summary:    route.Summary,
+		Deprecated: route.Deprecated,
+		Responses:  map[string]*Response{},
+	}
+	if route.Body != nil {
+		operation.RequestBody = &RequestBody{
+			Required: true,
+			Content:  content(d.schemaOf(reflect.TypeOf(route.Body)), codec.RequestMediaTypes),
+		}
+		statuses = append(statuses, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType)
+	}
+	if route.Query != nil {
+		operation.Parameters = d.queryParameters(reflect.TypeOf(route.Query))
+	}
+	if len(route.Results) > 0 {
+		var schema *Schema
+		if len(route.Results) == 1 {
+			schema = d.schemaOf(reflect.TypeOf(route.Results[0]))
+		} else {
+			schema = &Schema{}
+			for _, result := range route.Results {
+				schema.OneOf = append(schema.OneOf, d.schemaOf(reflect.TypeOf(result)))
+			}
+		}
+		operation.Responses["200"] = &Response{
+			Description: http.StatusText(http.StatusOK),
+			Content:     content(schema, codec.MediaTypes),
+		}
+	} else {
+		operation.Responses["200"] = &Response{Description: http.StatusText(http.StatusOK)}
+	}
+	if !route.Unlimited {
+		statuses = append(statuses, http.StatusTooManyRequests)
+	}
+	for _, status := range statuses {
+		operation.Responses[strconv.Itoa(status)] = d.errorResponse(status)
+	}
+	method := strings.ToLower(route.Method)
+	if d.Paths[route.Path] == nil {
+		d.Paths[route.Path] = map[string]*Operation{}
+	}
+	d.Paths[route.Path][method] = operation
+}
+
+// одна и та же схема во всех форматах mediaTypes
+func content(schema *Schema, mediaTypes []string) map[string]MediaType {
+	result := make(map[string]MediaType, len(mediaTypes))
+	for _, mediaType := range mediaTypes {
+		result[mediaType] = MediaType{Schema: schema}
+	}
+	return result
+}
+
+// ошибка в обычном формате или в формате application/problem+json
+func (d *Document) errorResponse(status int) *Response {
+	resp := &Response{
+		Description: http.StatusText(status),
+		Content:     content(d.schemaOf(reflect.TypeOf(response.Response{})), codec.MediaTypes),
+	}
+	resp.Content[response.ProblemContentType] = MediaType{Schema: d.schemaOf(reflect.TypeOf(response.Problem{}))}
+	if status == http.StatusTooManyRequests {
+		integer := &Schema{Type: "integer"}
+		resp.Headers = map[string]Header{
+			"Retry-After":         {Description: "секунд до сброса лимита", Schema: integer},
+			"RateLimit-Limit":     {Description: "лимит запросов за интервал", Schema: integer},
+			"RateLimit-Remaining": {Description: "оставшееся количество запросов", Schema: integer},
+			"RateLimit-Reset":     {Description: "секунд до сброса лимита", Schema: integer},
+		}
+	}
+	return resp
+}
+
+// параметры строки запроса из скалярных полей структуры
+func (d *Document) queryParameters(t reflect.Type) []Parameter {
+	schema := d.structSchema(t)
+	var parameters []Parameter
+	for name, property := range schema.Properties {
+		if property.Type == "" || property.Type == "array" || property.Type == "object" {
+			continue
+		}
+		parameters = append(parameters, Parameter{
+			Name:     name,
+			In:       "query",
+			Required: contains(schema.Required, name),
+			Schema:   property,
+		})
+	}
+	sort.Slice(parameters, func(i, j int) bool { return parameters[i].Name < parameters[j].Name })
+	return parameters
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// маршруты router, не описанные в спецификации, в виде "METHOD /path"
+func (d *Document) Undocumented(router chi.Routes) []string {
+	var undocumented []string
+	chi.Walk(router, func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		if _, ok := d.Paths[route][strings.ToLower(method)]; !ok {
+			undocumented = append(undocumented, method+" "+route)
+		}
+		return nil
+	})
+	return undocumented
+}
+
+// обработчик, отдающий спецификацию в формате JSON
+func (d *Document) Handler() http.HandlerFunc {
+	body, err := json.Marshal(d)
+	if err != nil {
+		panic(err)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}
+}
+
+//go:embed docs.html
+var docs embed.FS
+
+// страница документации, отрисовывающая спецификацию без внешних зависимостей
+func DocsHandler() http.HandlerFunc {
+	page, err := docs.ReadFile("docs.html")
+	if err != nil {
+		panic(err)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	}
+}
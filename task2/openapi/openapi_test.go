@@ -0,0 +1,136 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+type testOperand struct {
+	Factors []decimal.Decimal `json:"factors" validate:"required,min=1,max=64,dive"`
+}
+
+type testEmbedded struct {
+	Status string `json:"status"`
+}
+
+type testRequest struct {
+	Values    []testOperand              `json:"values" validate:"required,min=2"`
+	Precision *int32                     `json:"precision" validate:"required,min=0,max=100"`
+	Mode      string                     `json:"mode" validate:"omitempty,oneof=places significant"`
+	Name      string                     `json:"name" validate:"max=10"`
+	Variables map[string]decimal.Decimal `json:"variables"`
+	Hidden    string                     `json:"-"`
+	hidden    string
+}
+
+type testResponse struct {
+	testEmbedded
+	Result    decimal.Decimal `json:"result"`
+	UpdatedAt *time.Time      `json:"updated_at,omitempty"`
+}
+
+func TestSchema(t *testing.T) {
+	doc := New(Info{Title: "Тест", Version: "1"})
+	doc.Add(Route{
+		Method:  http.MethodPost,
+		Path:    "/calculate",
+		Body:    testRequest{},
+		Results: []any{testResponse{}},
+		Errors:  []int{http.StatusBadRequest},
+	})
+	operation := doc.Paths["/calculate"]["post"]
+	require.NotNil(t, operation)
+	require.Equal(t, "#/components/schemas/openapi.testRequest", operation.RequestBody.Content["application/json"].Schema.Ref)
+	require.Equal(t, "#/components/schemas/openapi.testResponse", operation.Responses["200"].Content["application/json"].Schema.Ref)
+	require.Contains(t, operation.Responses, "400")
+	require.Contains(t, operation.Responses, "413")
+	require.Contains(t, operation.Responses, "415")
+	require.Contains(t, operation.Responses, "429")
+	require.Contains(t, operation.Responses["429"].Headers, "Retry-After")
+	require.Contains(t, operation.Responses["400"].Content, "application/problem+json")
+
+	request := doc.Components.Schemas["openapi.testRequest"]
+	require.ElementsMatch(t, []string{"values", "precision"}, request.Required)
+	require.ElementsMatch(t, []string{"values", "precision", "mode", "name", "variables"}, keys(request.Properties))
+	require.Equal(t, 2, *request.Properties["values"].MinItems)
+	require.Equal(t, "#/components/schemas/openapi.testOperand", request.Properties["values"].Items.Ref)
+	require.Equal(t, "integer", request.Properties["precision"].Type)
+	require.Equal(t, 0.0, *request.Properties["precision"].Minimum)
+	require.Equal(t, 100.0, *request.Properties["precision"].Maximum)
+	require.Equal(t, []string{"places", "significant"}, request.Properties["mode"].Enum)
+	require.Equal(t, 10, *request.Properties["name"].MaxLength)
+	require.Equal(t, "decimal", request.Properties["variables"].AdditionalProperties.Format)
+
+	// правила после dive относятся к элементам массива
+	operand := doc.Components.Schemas["openapi.testOperand"]
+	require.Equal(t, 1, *operand.Properties["factors"].MinItems)
+	require.Equal(t, "decimal", operand.Properties["factors"].Items.Format)
+
+	// поля встроенной структуры поднимаются наверх
+	response := doc.Components.Schemas["openapi.testResponse"]
+	require.ElementsMatch(t, []string{"status", "result", "updated_at"}, keys(response.Properties))
+	require.Equal(t, "date-time", response.Properties["updated_at"].Format)
+}
+
+// маршруты без лимита запросов не описывают ответ 429
+func TestUnlimited(t *testing.T) {
+	doc := New(Info{Title: "Тест", Version: "1"})
+	doc.Add(Route{Method: http.MethodGet, Path: "/readyz", Errors: []int{http.StatusServiceUnavailable}, Unlimited: true})
+	responses := doc.Paths["/readyz"]["get"].Responses
+	require.ElementsMatch(t, []string{"200", "503"}, keys(responses))
+}
+
+func TestQueryParameters(t *testing.T) {
+	doc := New(Info{Title: "Тест", Version: "1"})
+	doc.Add(Route{Method: http.MethodGet, Path: "/calculate", Query: testRequest{}})
+	var names []string
+	for _, parameter := range doc.Paths["/calculate"]["get"].Parameters {
+		require.Equal(t, "query", parameter.In)
+		require.Equal(t, parameter.Name == "precision", parameter.Required)
+		names = append(names, parameter.Name)
+	}
+	// массивы и объекты в строке запроса не передаются
+	require.Equal(t, []string{"mode", "name", "precision"}, names)
+}
+
+func TestUndocumented(t *testing.T) {
+	doc := New(Info{Title: "Тест", Version: "1"})
+	doc.Add(Route{Method: http.MethodPost, Path: "/v1/calculate"})
+	router := chi.NewRouter()
+	router.Route("/v1", func(r chi.Router) {
+		r.Post("/calculate", func(w http.ResponseWriter, r *http.Request) {})
+		r.Get("/calculate", func(w http.ResponseWriter, r *http.Request) {})
+	})
+	require.Equal(t, []string{"GET /v1/calculate"}, doc.Undocumented(router))
+}
+
+func TestHandlers(t *testing.T) {
+	doc := New(Info{Title: "Тест", Version: "1"})
+	doc.Add(Route{Method: http.MethodGet, Path: "/openapi.json"})
+	rr := httptest.NewRecorder()
+	doc.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &decoded))
+	require.Equal(t, "3.0.3", decoded["openapi"])
+
+	rr = httptest.NewRecorder()
+	DocsHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	require.Equal(t, "text/html; charset=utf-8", rr.Header().Get("Content-Type"))
+	require.Contains(t, rr.Body.String(), "openapi.json")
+}
+
+func keys[V any](m map[string]V) []string {
+	result := make([]string, 0, len(m))
+	for key := range m {
+		result = append(result, key)
+	}
+	return result
+}
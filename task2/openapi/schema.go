@@ -0,0 +1,181 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// JSON Schema в подмножестве, используемом OpenAPI 3
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Example              any                `json:"example,omitempty"`
+}
+
+var (
+	decimalType = reflect.TypeOf(decimal.Decimal{})
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+/*
+Схема типа t. Именованные структуры попадают в components/schemas
+под именем <пакет>.<тип>, а на их место ставится ссылка.
+*/
+func (d *Document) schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == decimalType {
+		return &Schema{
+			Type:        "string",
+			Format:      "decimal",
+			Description: "десятичное число, рекомендуется передавать строкой",
+			Example:     "1.5",
+		}
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: d.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.structSchema(t)
+		}
+		name := schemaName(t)
+		if _, ok := d.Components.Schemas[name]; !ok {
+			// заглушка на случай рекурсивных типов
+			d.Components.Schemas[name] = &Schema{}
+			d.Components.Schemas[name] = d.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	return &Schema{}
+}
+
+func schemaName(t reflect.Type) string {
+	pkg := t.PkgPath()
+	return pkg[strings.LastIndex(pkg, "/")+1:] + "." + t.Name()
+}
+
+// схема структуры по тегам json и validate, поля встроенных структур поднимаются наверх
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		// как и в encoding/json, поля встроенной структуры поднимаются, даже если её тип неэкспортируемый
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+			embedded := d.structSchema(field.Type)
+			for name, property := range embedded.Properties {
+				schema.Properties[name] = property
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		name := jsonName(field)
+		if name == "-" {
+			continue
+		}
+		property := d.schemaOf(field.Type)
+		if applyRules(property, field.Type, field.Tag.Get("validate")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = property
+	}
+	return schema
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+/*
+Перенос правил валидатора в схему: min/max, oneof.
+Правила после dive относятся к элементам и пропускаются.
+Возвращает true, если поле обязательное.
+*/
+func applyRules(schema *Schema, t reflect.Type, tag string) (required bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "dive":
+			return
+		case "required":
+			required = true
+		case "oneof":
+			schema.Enum = strings.Fields(param)
+		case "min", "max":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				continue
+			}
+			setBound(schema, t, name == "min", n)
+		}
+	}
+	return
+}
+
+func setBound(schema *Schema, t reflect.Type, lower bool, n int) {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if lower {
+			schema.MinItems = &n
+		} else {
+			schema.MaxItems = &n
+		}
+	case reflect.String:
+		if lower {
+			schema.MinLength = &n
+		} else {
+			schema.MaxLength = &n
+		}
+	default:
+		f := float64(n)
+		if lower {
+			schema.Minimum = &f
+		} else {
+			schema.Maximum = &f
+		}
+	}
+}
@@ -1,9 +1,29 @@
 package response
 
+import (
+	"FloatService/codec"
+	"FloatService/i18n"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
 // храние общих параметров для ответа любого обработчика
 type Response struct {
-	Status string `json:"status"`
-	Error  string `json:"error,omitempty"`
+	Status string       `json:"status" xml:"status"`
+	Error  string       `json:"error,omitempty" xml:"error,omitempty"`
+	Code   string       `json:"code,omitempty" xml:"code,omitempty"`
+	Fields []FieldError `json:"fields,omitempty" xml:"fields,omitempty"`
+}
+
+// поле запроса, не прошедшее валидацию, нарушенное им правило и пояснение на языке клиента
+type FieldError struct {
+	Field   string `json:"field" xml:"field"`
+	Rule    string `json:"rule" xml:"rule"`
+	Param   string `json:"param,omitempty" xml:"param,omitempty"`
+	Message string `json:"message,omitempty" xml:"message,omitempty"`
 }
 
 const (
@@ -11,15 +31,137 @@ const (
 	StatusError = "Error"
 )
 
+// стабильные коды ошибок, на которые могут опираться клиенты
+const (
+	CodeInvalidJSON          = "INVALID_JSON"
+	CodeInvalidQuery         = "INVALID_QUERY"
+	CodeInvalidBody          = "INVALID_BODY"
+	CodeUnsupportedMediaType = "UNSUPPORTED_MEDIA_TYPE"
+	CodeBodyTooLarge         = "BODY_TOO_LARGE"
+	CodeUnknownField         = "UNKNOWN_FIELD"
+	CodeDuplicateField       = "DUPLICATE_FIELD"
+	CodeValidationFailed     = "VALIDATION_FAILED"
+	CodeDivisionByZero       = "DIVISION_BY_ZERO"
+	CodeInvalidPrecision     = "INVALID_PRECISION"
+	CodeNumberTooLarge       = "NUMBER_TOO_LARGE"
+	CodeCalculationError     = "CALCULATION_ERROR"
+	CodeSyntaxError          = "SYNTAX_ERROR"
+	CodeUnknownVariable      = "UNKNOWN_VARIABLE"
+	CodeExpressionTooComplex = "EXPRESSION_TOO_COMPLEX"
+	CodeComputeTimeout       = "COMPUTE_TIMEOUT"
+	CodeCanceled             = "CANCELED"
+	CodeRateLimited          = "RATE_LIMITED"
+	CodeOverloaded           = "OVERLOADED"
+	CodeForbidden            = "FORBIDDEN"
+	CodeUnauthorized         = "UNAUTHORIZED"
+	CodeUnknownPlan          = "UNKNOWN_PLAN"
+	CodeInternalError        = "INTERNAL_ERROR"
+	CodeShuttingDown         = "SHUTTING_DOWN"
+)
+
+const ProblemContentType = "application/problem+json"
+
 func OK() Response {
 	return Response{
 		Status: StatusOK,
 	}
 }
 
-func Error(msg string) Response {
+func Error(code, msg string) Response {
 	return Response{
 		Status: StatusError,
 		Error:  msg,
+		Code:   code,
+	}
+}
+
+func ValidationError(msg string, fields []FieldError) Response {
+	resp := Error(CodeValidationFailed, msg)
+	resp.Fields = fields
+	return resp
+}
+
+// ошибка в формате RFC 7807 (application/problem+json)
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code,omitempty"`
+	Fields   []FieldError `json:"fields,omitempty"`
+}
+
+func NewProblem(r *http.Request, status int, resp Response) Problem {
+	problemType := "about:blank"
+	if resp.Code != "" {
+		problemType = "urn:floatservice:error:" + resp.Code
+	}
+	return Problem{
+		Type:     problemType,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   resp.Error,
+		Instance: r.URL.Path,
+		Code:     resp.Code,
+		Fields:   resp.Fields,
+	}
+}
+
+type problemCtxKey struct{}
+
+// middleware, включающий ответы об ошибках в формате application/problem+json для всех запросов
+func ProblemJSONByDefault(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), problemCtxKey{}, true)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+/*
+Отправка ошибки со статусом status.
+В формате application/problem+json, если клиент запросил его в заголовке Accept
+или он включён для всех запросов, иначе в обычном формате Response
+в формате, выбранном по заголовку Accept.
+*/
+func RenderError(w http.ResponseWriter, r *http.Request, status int, resp Response) {
+	problem, _ := r.Context().Value(problemCtxKey{}).(bool)
+	if problem || strings.Contains(r.Header.Get("Accept"), ProblemContentType) {
+		body, _ := json.Marshal(NewProblem(r, status, resp))
+		w.Header().Set("Content-Type", ProblemContentType)
+		w.WriteHeader(status)
+		w.Write(append(body, byte('\n')))
+		return
+	}
+	codec.Render(w, r, status, resp)
+}
+
+/*
+Ответ на ошибку декодирования тела запроса:
+415 для неподдерживаемого Content-Type, 413 для слишком большого тела,
+400 с кодом UNKNOWN_FIELD или DUPLICATE_FIELD и путём к полю в fields для полей, отклонённых в строгом режиме,
+иначе 400 с кодом INVALID_JSON для JSON и INVALID_BODY для остальных форматов.
+*/
+func RenderDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	lang := i18n.FromContext(r.Context())
+	var tooLarge *http.MaxBytesError
+	var field *codec.FieldError
+	switch {
+	case errors.As(err, &tooLarge):
+		RenderError(w, r, http.StatusRequestEntityTooLarge, Error(CodeBodyTooLarge, i18n.T(lang, i18n.BodyTooLarge, tooLarge.Limit)))
+	case errors.As(err, &field):
+		code := CodeUnknownField
+		if field.Rule == codec.RuleDuplicate {
+			code = CodeDuplicateField
+		}
+		resp := Error(code, field.Localize(lang))
+		resp.Fields = []FieldError{{Field: field.Field, Rule: field.Rule, Message: resp.Error}}
+		RenderError(w, r, http.StatusBadRequest, resp)
+	case errors.Is(err, codec.ErrUnsupportedMediaType):
+		RenderError(w, r, http.StatusUnsupportedMediaType, Error(CodeUnsupportedMediaType, i18n.T(lang, i18n.UnsupportedMediaType)))
+	case codec.RequestType(r) == codec.JSON:
+		RenderError(w, r, http.StatusBadRequest, Error(CodeInvalidJSON, i18n.T(lang, i18n.DecodeError)))
+	default:
+		RenderError(w, r, http.StatusBadRequest, Error(CodeInvalidBody, i18n.T(lang, i18n.DecodeError)))
 	}
 }
@@ -5,7 +5,9 @@ import (
 	"FloatService/response"
 	"encoding/json"
 	"log"
+	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -28,77 +30,118 @@ func TestFloatService_HanlderResponses(t *testing.T) {
 		name     string
 		request  string
 		response string
+		status   int
 	}{
 		{
 			name:     "Успех",
 			request:  `{"X1":"1", "X2":"2", "X3":"3","Y1":"1","Y2":"2","Y3":"3","E":5}`,
 			response: `{"status":"OK","X":"1.5","Y":"1.5","IsEqual":"T"}`,
+			status:   http.StatusOK,
 		},
 
 		{
 			name:     "Деление на нуль",
 			request:  `{"X1":"1", "X2":"0", "X3":"3","Y1":"1","Y2":"2","Y3":"3","E":5}`,
-			response: `{"status": "Error", "error": "деление на нуль"}`,
+			response: `{"status": "Error", "error": "Некорректный запрос", "code": "VALIDATION_FAILED", "fields": [{"field": "X2", "rule": "nonzero", "message": "Значение не должно быть равно нулю."}]}`,
+			status:   http.StatusBadRequest,
+		},
+
+		{
+			name:     "Слишком большая точность",
+			request:  `{"X1":"1", "X2":"2", "X3":"3","Y1":"1","Y2":"2","Y3":"3","E":1001}`,
+			response: `{"status": "Error", "error": "Некорректный запрос", "code": "VALIDATION_FAILED", "fields": [{"field": "E", "rule": "max", "param": "1000", "message": "Значение должно быть не больше 1000."}]}`,
+			status:   http.StatusBadRequest,
 		},
 
 		{
 			name:     "Некорректный запрос",
 			request:  "}{",
-			response: `{"status":"Error","error":"Ошибка декодирования запроса."}`,
+			response: `{"status":"Error","error":"Ошибка декодирования запроса.","code":"INVALID_JSON"}`,
+			status:   http.StatusBadRequest,
 		},
 
 		{
 			name:     "Ошибка валидации: нет X1",
 			request:  `{"X2":"2", "X3":"3","Y1":"1","Y2":"2","Y3":"3","E":5}`,
-			response: `{"status": "Error", "error": "Некорректный запрос"}`,
+			response: `{"status": "Error", "error": "Некорректный запрос", "code": "VALIDATION_FAILED", "fields": [{"field": "X1", "rule": "required_without", "param": "Values", "message": "Поле обязательно, если не указано Values."}]}`,
+			status:   http.StatusBadRequest,
 		},
 
 		{
 			name:     "Ошибка валидации: нет X2",
 			request:  `{"X1":"1", "X3":"3","Y1":"1","Y2":"2","Y3":"3","E":5}`,
-			response: `{"status": "Error", "error": "Некорректный запрос"}`,
+			response: `{"status": "Error", "error": "Некорректный запрос", "code": "VALIDATION_FAILED", "fields": [{"field": "X2", "rule": "required_without", "param": "Values", "message": "Поле обязательно, если не указано Values."}]}`,
+			status:   http.StatusBadRequest,
 		},
 
 		{
 			name:     "Ошибка валидации: нет X3",
 			request:  `{"X1":"1", "X2":"2","Y1":"1","Y2":"2","Y3":"3","E":5}`,
-			response: `{"status": "Error", "error": "Некорректный запрос"}`,
+			response: `{"status": "Error", "error": "Некорректный запрос", "code": "VALIDATION_FAILED", "fields": [{"field": "X3", "rule": "required_without", "param": "Values", "message": "Поле обязательно, если не указано Values."}]}`,
+			status:   http.StatusBadRequest,
 		},
 
 		{
 			name:     "Ошибка валидации: нет Y1",
 			request:  `{"X1":"1", "X2":"2", "X3":"3","Y2":"2","Y3":"3","E":5}`,
-			response: `{"status": "Error", "error": "Некорректный запрос"}`,
+			response: `{"status": "Error", "error": "Некорректный запрос", "code": "VALIDATION_FAILED", "fields": [{"field": "Y1", "rule": "required_without", "param": "Values", "message": "Поле обязательно, если не указано Values."}]}`,
+			status:   http.StatusBadRequest,
 		},
 
 		{
 			name:     "Ошибка валидации: нет Y2",
 			request:  `{"X1":"1", "X2":"2", "X3":"3","Y1":"1","Y3":"3","E":5}`,
-			response: `{"status": "Error", "error": "Некорректный запрос"}`,
+			response: `{"status": "Error", "error": "Некорректный запрос", "code": "VALIDATION_FAILED", "fields": [{"field": "Y2", "rule": "required_without", "param": "Values", "message": "Поле обязательно, если не указано Values."}]}`,
+			status:   http.StatusBadRequest,
 		},
 
 		{
 			name:     "Ошибка валидации: нет Y3",
 			request:  `{"X1":"1", "X2":"2", "X3":"3","Y1":"1","Y2":"2","E":5}`,
-			response: `{"status": "Error", "error": "Некорректный запрос"}`,
+			response: `{"status": "Error", "error": "Некорректный запрос", "code": "VALIDATION_FAILED", "fields": [{"field": "Y3", "rule": "required_without", "param": "Values", "message": "Поле обязательно, если не указано Values."}]}`,
+			status:   http.StatusBadRequest,
 		},
 
 		{
 			name:     "Ошибка валидации: нет E",
 			request:  `{"X1":"1", "X2":"2", "X3":"3","Y1":"1","Y2":"2","Y3":"3"}`,
-			response: `{"status": "Error", "error": "Некорректный запрос"}`,
+			response: `{"status": "Error", "error": "Некорректный запрос", "code": "VALIDATION_FAILED", "fields": [{"field": "E", "rule": "required", "message": "Поле обязательно."}]}`,
+			status:   http.StatusBadRequest,
 		},
 
 		{
 			name:     "Отправка целых",
 			request:  `{"X1":1, "X2":2, "X3":3,"Y1":1,"Y2":2,"Y3":3,"E":5}`,
 			response: `{"status":"OK","X":"1.5","Y":"1.5","IsEqual":"T"}`,
+			status:   http.StatusOK,
 		},
 
 		{
 			name:     "Отправка чисел с плавающей запятой",
 			request:  `{"X1":1.0, "X2":2.0, "X3":3.0,"Y1":1.0,"Y2":2.0,"Y3":3.0,"E":5}`,
 			response: `{"status":"OK","X":"1.5","Y":"1.5","IsEqual":"T"}`,
+			status:   http.StatusOK,
+		},
+
+		{
+			name:     "Произвольное количество значений",
+			request:  `{"Values":[{"Factors":["1","3"],"Divisors":["2"]},{"Factors":["3"],"Divisors":["2"]},{"Factors":["2"]}],"E":5}`,
+			response: `{"status":"OK","Values":["1.5","1.5","2"],"IsEqual":"F","Groups":[[0,1],[2]]}`,
+			status:   http.StatusOK,
+		},
+
+		{
+			name:     "Значащие цифры",
+			request:  `{"X1":"6.02e23", "X2":"3", "X3":"1","Y1":"2.0071e23","Y2":"1","Y3":"1","E":3,"precision_mode":"significant"}`,
+			response: `{"status":"OK","X":"201000000000000000000000","Y":"201000000000000000000000","IsEqual":"T"}`,
+			status:   http.StatusOK,
+		},
+
+		{
+			name:     "Точный результат",
+			request:  `{"X1":"1", "X2":"3", "X3":"1","Y1":"0.3334","Y2":"1","Y3":"1","E":2,"detail":true}`,
+			response: `{"status":"OK","X":"0.33","Y":"0.33","IsEqual":"T","XDetail":{"Numerator":"1","Denominator":"3","Rounded":true,"RoundingError":"-1/300"},"YDetail":{"Numerator":"1667","Denominator":"5000","Rounded":true,"RoundingError":"-17/5000"}}`,
+			status:   http.StatusOK,
 		},
 	}
 
@@ -116,7 +159,7 @@ func TestFloatService_HanlderResponses(t *testing.T) {
 			e.GET("/").
 				WithText(test_case.request).
 				Expect().
-				Status(200).
+				Status(test_case.status).
 				JSON().Object().IsEqual(response)
 		})
 	}
@@ -292,7 +335,7 @@ func TestFloatService_Сalculations(t *testing.T) {
 			E: 3,
 			X: decimal.Zero, Y: decimal.Zero,
 			IsEqual: "F",
-			Err:     "деление на нуль",
+			Err:     "Значение не должно быть равно нулю.",
 		},
 		{
 			name: "Y2 равен нулю",
@@ -301,7 +344,7 @@ func TestFloatService_Сalculations(t *testing.T) {
 			E: 3,
 			X: decimal.Zero, Y: decimal.Zero,
 			IsEqual: "F",
-			Err:     "деление на нуль",
+			Err:     "Значение не должно быть равно нулю.",
 		},
 		{
 			name: "X3 равен нулю",
@@ -326,7 +369,7 @@ func TestFloatService_Сalculations(t *testing.T) {
 			E: 3,
 			X: decimal.Zero, Y: decimal.Zero,
 			IsEqual: "F",
-			Err:     "деление на нуль",
+			Err:     "Значение не должно быть равно нулю.",
 		},
 		{
 			name: "X3 и Y3 равны нулю",
@@ -343,7 +386,7 @@ func TestFloatService_Сalculations(t *testing.T) {
 			E: 3,
 			X: decimal.Zero, Y: decimal.Zero,
 			IsEqual: "F",
-			Err:     "деление на нуль",
+			Err:     "Значение не должно быть равно нулю.",
 		},
 		{
 			name: "Все Y параметры равны нулю",
@@ -352,7 +395,7 @@ func TestFloatService_Сalculations(t *testing.T) {
 			E: 3,
 			X: decimal.Zero, Y: decimal.Zero,
 			IsEqual: "F",
-			Err:     "деление на нуль",
+			Err:     "Значение не должно быть равно нулю.",
 		},
 		{
 			name: "Все X и Y параметры равны нулю",
@@ -361,7 +404,7 @@ func TestFloatService_Сalculations(t *testing.T) {
 			E: 3,
 			X: decimal.Zero, Y: decimal.Zero,
 			IsEqual: "F",
-			Err:     "деление на нуль",
+			Err:     "Значение не должно быть равно нулю.",
 		},
 		{
 			name: "X1 и X3 равны нулю",
@@ -390,6 +433,7 @@ func TestFloatService_Сalculations(t *testing.T) {
 				Host:   host,
 			}
 			var resp interface{}
+			status := http.StatusOK
 			if test_case.Err == "" {
 				resp = handlefloatcalculation.Response{
 					Response: response.OK(),
@@ -398,7 +442,16 @@ func TestFloatService_Сalculations(t *testing.T) {
 					IsEqual:  test_case.IsEqual,
 				}
 			} else {
-				resp = response.Error(test_case.Err)
+				// нулевые делители отклоняются при валидации
+				var fields []response.FieldError
+				if test_case.X2.IsZero() {
+					fields = append(fields, response.FieldError{Field: "X2", Rule: "nonzero", Message: test_case.Err})
+				}
+				if test_case.Y2.IsZero() {
+					fields = append(fields, response.FieldError{Field: "Y2", Rule: "nonzero", Message: test_case.Err})
+				}
+				resp = response.ValidationError("Некорректный запрос", fields)
+				status = http.StatusBadRequest
 			}
 			e := httpexpect.Default(t, u.String())
 			e.GET("/").
@@ -408,12 +461,198 @@ func TestFloatService_Сalculations(t *testing.T) {
 					E: &test_case.E,
 				}).
 				Expect().
-				Status(200).
+				Status(status).
 				JSON().Object().IsEqual(resp)
 		})
 	}
 }
 
+// проверяем вычисление произвольных выражений
+func TestFloatService_Evaluate(t *testing.T) {
+	// спим, чтобы не упереться в лимит из-за предыдущих тестов
+	time.Sleep(interval)
+	cases := []struct {
+		name           string
+		request        string
+		response       string
+		status         int
+		acceptLanguage string
+	}{
+		{
+			name:     "Успех",
+			request:  `{"expression":"a / b * c + round(d, 4)","variables":{"a":"1","b":"2","c":"3","d":"1.234567"}}`,
+			response: `{"status":"OK","result":"2.7346"}`,
+			status:   http.StatusOK,
+		},
+
+		{
+			name:     "Деление на нуль",
+			request:  `{"expression":"a / (b - 2)","variables":{"a":"1","b":"2"}}`,
+			response: `{"status":"Error","error":"деление на нуль","code":"DIVISION_BY_ZERO"}`,
+			status:   http.StatusUnprocessableEntity,
+		},
+
+		{
+			name:     "Неизвестная переменная",
+			request:  `{"expression":"a + z","variables":{"a":"1"}}`,
+			response: `{"status":"Error","error":"неизвестная переменная \"z\"","code":"UNKNOWN_VARIABLE"}`,
+			status:   http.StatusUnprocessableEntity,
+		},
+
+		{
+			name:           "Деление на нуль на английском",
+			request:        `{"expression":"a / (b - 2)","variables":{"a":"1","b":"2"}}`,
+			response:       `{"status":"Error","error":"division by zero","code":"DIVISION_BY_ZERO"}`,
+			status:         http.StatusUnprocessableEntity,
+			acceptLanguage: "en-US,en;q=0.9",
+		},
+
+		{
+			name:           "Синтаксическая ошибка на английском",
+			request:        `{"expression":"a ^ b","variables":{"a":"1","b":"2"}}`,
+			response:       `{"status":"Error","error":"syntax error at position 2: unexpected character '^'","code":"SYNTAX_ERROR"}`,
+			status:         http.StatusBadRequest,
+			acceptLanguage: "en",
+		},
+
+		{
+			name:           "Неподдерживаемый язык",
+			request:        `{"expression":"a / (b - 2)","variables":{"a":"1","b":"2"}}`,
+			response:       `{"status":"Error","error":"деление на нуль","code":"DIVISION_BY_ZERO"}`,
+			status:         http.StatusUnprocessableEntity,
+			acceptLanguage: "de",
+		},
+	}
+
+	for _, test_case := range cases {
+		test_case := test_case
+		t.Run(test_case.name, func(t *testing.T) {
+			t.Parallel()
+			u := url.URL{
+				Scheme: "http",
+				Host:   host,
+			}
+			var response map[string]interface{}
+			json.Unmarshal([]byte(test_case.response), &response)
+			e := httpexpect.Default(t, u.String())
+			req := e.POST("/v1/evaluate").WithText(test_case.request)
+			if test_case.acceptLanguage != "" {
+				req = req.WithHeader("Accept-Language", test_case.acceptLanguage)
+			}
+			req.Expect().
+				Status(test_case.status).
+				JSON().Object().IsEqual(response)
+		})
+	}
+}
+
+// проверяем маршруты версий API и устаревшие маршруты без версии
+func TestFloatService_Routes(t *testing.T) {
+	// спим, чтобы не упереться в лимит из-за предыдущих тестов
+	time.Sleep(interval)
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	const request = `{"X1":"1", "X2":"2", "X3":"3","Y1":"1","Y2":"2","Y3":"3","E":5}`
+	var expected map[string]interface{}
+	json.Unmarshal([]byte(`{"status":"OK","X":"1.5","Y":"1.5","IsEqual":"T"}`), &expected)
+	e := httpexpect.Default(t, u.String())
+	resp := e.POST("/v1/calculate").WithText(request).Expect()
+	resp.Status(http.StatusOK).JSON().Object().IsEqual(expected)
+	resp.Header("Deprecation").IsEmpty()
+	resp = e.GET("/v1/calculate").
+		WithQuery("X1", "1").WithQuery("X2", "2").WithQuery("X3", "3").
+		WithQuery("Y1", "1").WithQuery("Y2", "2").WithQuery("Y3", "3").
+		WithQuery("E", 5).
+		Expect()
+	resp.Status(http.StatusOK).JSON().Object().IsEqual(expected)
+	resp.Header("Deprecation").IsEmpty()
+	e.GET("/v1/calculate").WithQuery("X1", "abc").WithQuery("E", 5).
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().Value("code").IsEqual(response.CodeInvalidQuery)
+	var expectedV2 map[string]interface{}
+	json.Unmarshal([]byte(`{"status":"OK","values":["1.5","1.5"],"is_equal":true,"groups":[[0,1]]}`), &expectedV2)
+	resp = e.POST("/v2/calculate").
+		WithText(`{"values":[{"factors":["1","3"],"divisors":["2"]},{"factors":["3"],"divisors":["2"]}],"precision":5}`).
+		Expect()
+	resp.Status(http.StatusOK).JSON().Object().IsEqual(expectedV2)
+	resp.Header("Deprecation").IsEmpty()
+	resp = e.GET("/").WithText(request).Expect()
+	resp.Status(http.StatusOK).JSON().Object().IsEqual(expected)
+	resp.Header("Deprecation").IsEqual("true")
+	resp.Header("Link").IsEqual(`</v1/calculate>; rel="successor-version"`)
+	resp = e.POST("/evaluate").WithText(`{"expression":"1 + 2"}`).Expect()
+	resp.Status(http.StatusOK).JSON().Object().Value("result").IsEqual("3")
+	resp.Header("Deprecation").IsEqual("true")
+	resp.Header("Link").IsEqual(`</v1/evaluate>; rel="successor-version"`)
+}
+
+// проверяем спецификацию OpenAPI и страницу документации
+func TestFloatService_OpenAPI(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+	spec := e.GET("/openapi.json").Expect().Status(http.StatusOK).JSON().Object()
+	spec.Value("openapi").IsEqual("3.0.3")
+	paths := spec.Value("paths").Object()
+	for _, path := range []string{"/v1/calculate", "/v1/evaluate", "/v2/calculate", "/", "/evaluate"} {
+		paths.ContainsKey(path)
+	}
+	paths.Value("/v1/calculate").Object().Value("post").Object().
+		Value("responses").Object().
+		Value("429").Object().
+		Value("headers").Object().ContainsKey("Retry-After")
+	spec.Value("components").Object().Value("schemas").Object().
+		Value("handlefloatcalculation.Request").Object().
+		Value("required").Array().ContainsOnly("E")
+	e.GET("/docs").Expect().
+		Status(http.StatusOK).
+		ContentType("text/html").
+		Body().Contains("openapi.json")
+}
+
+// проверяем декодирование запросов по Content-Type и кодирование ответов по Accept
+func TestFloatService_Formats(t *testing.T) {
+	// спим, чтобы не упереться в лимит из-за предыдущих тестов
+	time.Sleep(interval)
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	var expected map[string]interface{}
+	json.Unmarshal([]byte(`{"status":"OK","X":"1.5","Y":"1.5","IsEqual":"T"}`), &expected)
+	e := httpexpect.Default(t, u.String())
+	e.POST("/v1/calculate").
+		WithHeader("Content-Type", "application/x-www-form-urlencoded").
+		WithText("X1=1&X2=2&X3=3&Y1=1&Y2=2&Y3=3&E=5").
+		Expect().
+		Status(http.StatusOK).JSON().Object().IsEqual(expected)
+	e.POST("/v1/calculate").
+		WithHeader("Content-Type", "application/xml").
+		WithHeader("Accept", "application/xml").
+		WithText("<Request><X1>0.1234567890123456789</X1><X2>1</X2><X3>1</X3><Y1>1</Y1><Y2>2</Y2><Y3>3</Y3><E>19</E></Request>").
+		Expect().
+		Status(http.StatusOK).
+		ContentType("application/xml").
+		Body().Contains("<Response><status>OK</status><X>0.1234567890123456789</X><Y>1.5</Y><IsEqual>F</IsEqual></Response>")
+	e.POST("/v1/calculate").
+		WithHeader("Content-Type", "application/octet-stream").
+		WithText("X1").
+		Expect().
+		Status(http.StatusUnsupportedMediaType).
+		JSON().Object().Value("code").IsEqual(response.CodeUnsupportedMediaType)
+	// тело больше max_body_size из файла конфигурации
+	e.POST("/v1/calculate").
+		WithText(`{"X1":"` + strings.Repeat("1", 1<<20) + `"}`).
+		Expect().
+		Status(http.StatusRequestEntityTooLarge).
+		JSON().Object().Value("code").IsEqual(response.CodeBodyTooLarge)
+}
+
 func DecimalFromString(str string) decimal.Decimal {
 	num, err := decimal.NewFromString(str)
 	if err != nil {
@@ -437,19 +676,21 @@ func TestFloatService_RateLimit(t *testing.T) {
 		E: &E,
 	}
 	e := httpexpect.Default(t, u.String())
-	// запросы в пределах лимита должны получить статус 200
+	// запросы в пределах лимита не должны получить статус 429
 	statusCode := 0
 	var numRequests int
 	for numRequests = 0; numRequests < limit+clearance; numRequests++ {
 		resp := e.GET("/").WithJSON(request).Expect()
 		statusCode = resp.Raw().StatusCode
-		if statusCode == 402 {
-			resp.JSON().IsEqual(response.Error(limitRateMsg))
+		if statusCode == http.StatusTooManyRequests {
+			resp.Header("Retry-After").NotEmpty()
+			resp.Header("RateLimit-Remaining").IsEqual("0")
+			resp.JSON().IsEqual(response.Error(response.CodeRateLimited, limitRateMsg))
 			break
 		}
 	}
-	require.Equal(t, 402, statusCode)
-	log.Printf("Лимит: %d, интервал: %v, количество посланных запросов до статуса 402: %d", limit, interval, numRequests)
+	require.Equal(t, http.StatusTooManyRequests, statusCode)
+	log.Printf("Лимит: %d, интервал: %v, количество посланных запросов до статуса 429: %d", limit, interval, numRequests)
 	require.LessOrEqual(t, absInt(limit-numRequests), clearance)
 }
 
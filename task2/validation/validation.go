@@ -0,0 +1,122 @@
+package validation
+
+import (
+	"FloatService/i18n"
+	"FloatService/response"
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/shopspring/decimal"
+)
+
+/*
+Общий валидатор, созданный при запуске со всеми дополнительными правилами.
+validator.Validate безопасен для параллельного использования и кэширует
+разобранные теги структур, поэтому его не следует создавать на каждый запрос.
+*/
+var shared = New()
+
+// общий валидатор для обработчиков
+func Default() *validator.Validate {
+	return shared
+}
+
+/*
+создание валидатора, в ошибках которого поля называются так же, как в json.
+Дополнительное правило nonzero запрещает нулевые значения,
+отсутствующее значение при этом проверяется правилом required.
+*/
+func New() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		switch name {
+		case "-":
+			return ""
+		case "":
+			return field.Name
+		}
+		return name
+	})
+	v.RegisterValidation("nonzero", nonZero)
+	return v
+}
+
+// ненулевое значение, decimal.Decimal сравнивается по числу, а не по внутреннему представлению
+func nonZero(fl validator.FieldLevel) bool {
+	field := fl.Field()
+	// незаполненное поле
+	if field.IsZero() {
+		return true
+	}
+	if value, ok := field.Interface().(decimal.Decimal); ok {
+		return !value.IsZero()
+	}
+	return true
+}
+
+// поля, не прошедшие валидацию, нарушенные ими правила и пояснения на языке lang
+func Fields(lang string, err error) []response.FieldError {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return nil
+	}
+	fields := make([]response.FieldError, 0, len(validationErrors))
+	for _, fieldError := range validationErrors {
+		fields = append(fields, response.FieldError{
+			Field:   FieldPath(fieldError),
+			Rule:    fieldError.Tag(),
+			Param:   fieldError.Param(),
+			Message: Explain(lang, fieldError),
+		})
+	}
+	return fields
+}
+
+// пояснение к нарушенному правилу на языке lang
+func Explain(lang string, fieldError validator.FieldError) string {
+	param := fieldError.Param()
+	// у min и max для строк и массивов ограничивается длина, а не значение
+	kind := fieldError.Kind()
+	isList := kind == reflect.Slice || kind == reflect.Array || kind == reflect.Map
+	switch fieldError.Tag() {
+	case "required":
+		return i18n.T(lang, i18n.RuleRequired)
+	case "required_without":
+		return i18n.T(lang, i18n.RuleRequiredWithout, param)
+	case "excluded_with":
+		return i18n.T(lang, i18n.RuleExcludedWith, param)
+	case "min", "gte":
+		switch {
+		case isList:
+			return i18n.T(lang, i18n.RuleMinItems, param)
+		case kind == reflect.String:
+			return i18n.T(lang, i18n.RuleMinLength, param)
+		}
+		return i18n.T(lang, i18n.RuleMin, param)
+	case "max", "lte":
+		switch {
+		case isList:
+			return i18n.T(lang, i18n.RuleMaxItems, param)
+		case kind == reflect.String:
+			return i18n.T(lang, i18n.RuleMaxLength, param)
+		}
+		return i18n.T(lang, i18n.RuleMax, param)
+	case "oneof":
+		return i18n.T(lang, i18n.RuleOneOf, strings.Join(strings.Fields(param), ", "))
+	case "nonzero":
+		return i18n.T(lang, i18n.RuleNonZero)
+	}
+	return i18n.T(lang, i18n.RuleOther, fieldError.Tag())
+}
+
+// путь к полю без имени корневой структуры: Request.Values[0].Factors -> Values[0].Factors
+func FieldPath(fieldError validator.FieldError) string {
+	namespace := fieldError.Namespace()
+	if i := strings.IndexByte(namespace, '.'); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
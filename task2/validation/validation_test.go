@@ -0,0 +1,23 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+type testRequest struct {
+	X decimal.Decimal `json:"X2" validate:"nonzero"`
+}
+
+func TestDefault(t *testing.T) {
+	require.Same(t, Default(), Default())
+	req := testRequest{X: decimal.New(2, 0)}
+	require.NoError(t, Default().Struct(req))
+	req.X = decimal.RequireFromString("0.00")
+	err := Default().Struct(req)
+	require.Error(t, err)
+	require.Equal(t, "X2", Fields("ru", err)[0].Field)
+	require.Equal(t, "Value must not be zero.", Fields("en", err)[0].Message)
+}